@@ -7,10 +7,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // quiet hours and digests use the timezones of users
 
 	"github.com/tatucosmin/hotel-system/config"
+	"github.com/tatucosmin/hotel-system/mail"
 	"github.com/tatucosmin/hotel-system/server"
 	"github.com/tatucosmin/hotel-system/store"
+	"github.com/tatucosmin/hotel-system/workers"
 )
 
 func main() {
@@ -33,14 +36,43 @@ func run() error {
 	if err != nil {
 		return err
 	}
-	store := store.New(db)
+
+	events, err := store.NewPgEventBus(cfg, db)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	limiter, err := store.NewRateLimiter(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	store := store.New(db, cfg.Location)
 
 	jsonHandler := slog.NewJSONHandler(os.Stdout, nil)
 	logger := slog.New(jsonHandler)
 
+	transport, err := mail.NewTransport(cfg)
+	if err != nil {
+		return err
+	}
+
+	templates, err := mail.LoadTemplates()
+	if err != nil {
+		return err
+	}
+
+	go workers.NewWebhookWorker(store.Webhook, logger).Run(ctx)
+	go workers.NewNotificationWorker(store, templates, logger).Run(ctx)
+	go workers.NewMailWorker(store.Notification, transport, cfg.MailFrom, cfg.MailReplyTo, cfg.InboundEmailSecret, logger).Run(ctx)
+	go workers.NewChatWorker(store.Notification, logger).Run(ctx)
+	go workers.NewSchedulerWorker(store.Schedule, logger).Run(ctx)
+	go workers.NewReminderWorker(store.Ticket, cfg.ReminderOffsets, logger).Run(ctx)
+
 	jwtManager := server.NewJwtManager(cfg)
 
-	server := server.New(cfg, logger, store, jwtManager)
+	server := server.New(cfg, logger, store, jwtManager, events, limiter)
 	if err := server.Start(ctx); err != nil {
 		return err
 	}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
@@ -19,20 +20,39 @@ const (
 )
 
 type Config struct {
-	ServerPort           string  `env:"SERVER_PORT"`
-	ServerHost           string  `env:"SERVER_HOST"`
-	DatabaseHost         string  `env:"DB_HOST"`
-	DatabasePort         string  `env:"DB_PORT"`
-	DatabaseUser         string  `env:"DB_USER"`
-	DatabaseName         string  `env:"DB_NAME"`
-	DatabasePassword     string  `env:"DB_PASS"`
-	DatabaseTestPort     string  `env:"DB_TEST_PORT"`
-	Env                  EnvType `env:"ENV" defaultEnv:"dev"`
-	JwtSecret            string  `env:"JWT_SECRET"`
-	ProjectRoot          string  `env:"PROJECT_ROOT"`
-	S3LocalStackEndpoint string  `env:"LOCALSTACK_S3_ENDPOINT"`
-	S3Bucket             string  `env:"S3_BUCKET"`
+	ServerPort           string          `env:"SERVER_PORT"`
+	ServerHost           string          `env:"SERVER_HOST"`
+	DatabaseHost         string          `env:"DB_HOST"`
+	DatabasePort         string          `env:"DB_PORT"`
+	DatabaseUser         string          `env:"DB_USER"`
+	DatabaseName         string          `env:"DB_NAME"`
+	DatabasePassword     string          `env:"DB_PASS"`
+	DatabaseTestPort     string          `env:"DB_TEST_PORT"`
+	Env                  EnvType         `env:"ENV" defaultEnv:"dev"`
+	JwtSecret            string          `env:"JWT_SECRET"`
+	ProjectRoot          string          `env:"PROJECT_ROOT"`
+	S3LocalStackEndpoint string          `env:"LOCALSTACK_S3_ENDPOINT"`
+	S3Bucket             string          `env:"S3_BUCKET"`
+	CsatWindow           time.Duration   `env:"CSAT_WINDOW" envDefault:"168h"`
+	ReplyEditWindow      time.Duration   `env:"REPLY_EDIT_WINDOW" envDefault:"15m"`
+	HotelTimezone        string          `env:"HOTEL_TIMEZONE" envDefault:"UTC"`
+	RateLimitStore       string          `env:"RATE_LIMIT_STORE" envDefault:"memory"`
+	RateLimits           string          `env:"RATE_LIMITS" envDefault:"POST /api/auth/signin=ip:10/1m;POST /api/auth/signup=ip:5/1h;POST /api/auth/refresh=ip:30/1m;POST /api/ticket=ip:60/1h,user:20/1h"`
+	TrustProxy           bool            `env:"TRUST_PROXY"`
+	MailTransport        string          `env:"MAIL_TRANSPORT" envDefault:"file"`
+	MailFrom             string          `env:"MAIL_FROM" envDefault:"Ticketr <no-reply@ticketr.local>"`
+	MailReplyTo          string          `env:"MAIL_REPLY_TO"`
+	MailDir              string          `env:"MAIL_DIR" envDefault:"tmp/mail"`
+	InboundEmailSecret   string          `env:"INBOUND_EMAIL_SECRET"`
+	InboundCreateUsers   bool            `env:"INBOUND_EMAIL_CREATE_USERS"`
+	SmtpHost             string          `env:"SMTP_HOST"`
+	SmtpPort             int             `env:"SMTP_PORT" envDefault:"587"`
+	SmtpUser             string          `env:"SMTP_USER"`
+	SmtpPassword         string          `env:"SMTP_PASS"`
+	ReminderOffsets      []time.Duration `env:"REMINDER_OFFSETS" envDefault:"24h,1h"`
 	S3Client             *s3.Client
+	// Location is HotelTimezone loaded, the time of day conditions of routing rules are in it
+	Location *time.Location
 }
 
 func New() (*Config, error) {
@@ -53,7 +73,13 @@ func New() (*Config, error) {
 		}
 	})
 
+	location, err := time.LoadLocation(cfg.HotelTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hotel timezone %q: %w", cfg.HotelTimezone, err)
+	}
+
 	cfg.S3Client = s3Client
+	cfg.Location = location
 	return &cfg, nil
 }
 
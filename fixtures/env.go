@@ -39,6 +39,6 @@ func (te *TestEnv) SetupDb(t *testing.T) func(t *testing.T) {
 }
 
 func (te *TestEnv) CleanupDb(t *testing.T) {
-	err := goose.Down(te.Db, fmt.Sprintf("%s/%s", te.Config.ProjectRoot, "schema"))
+	err := goose.DownTo(te.Db, fmt.Sprintf("%s/%s", te.Config.ProjectRoot, "schema"), 0)
 	require.NoError(t, err)
 }
@@ -0,0 +1,392 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxMimeDepth bounds how deeply multiparts may nest
+const maxMimeDepth = 10
+
+// InboundMessage is what is kept of a received email
+type InboundMessage struct {
+	From string
+	// Recipients are the addresses of the To, Cc, Delivered-To and X-Original-To headers
+	Recipients []string
+	Subject    string
+	MessageId  string
+	InReplyTo  string
+	References string
+	// AutoSubmitted is set for auto replies, bounces and the like, which must not be answered
+	AutoSubmitted bool
+	// Text is the plain text body, converted from the html body when there is no plain one
+	Text        string
+	Attachments []Attachment
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ParseInbound reads a raw RFC 5322 message
+func ParseInbound(r io.Reader) (*InboundMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message: %w", err)
+	}
+
+	from, err := msg.Header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return nil, errors.New("message has no valid from address")
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	autoSubmitted := msg.Header.Get("Auto-Submitted")
+	inbound := &InboundMessage{
+		From:       strings.ToLower(from[0].Address),
+		Subject:    strings.TrimSpace(subject),
+		MessageId:  msg.Header.Get("Message-Id"),
+		InReplyTo:  msg.Header.Get("In-Reply-To"),
+		References: msg.Header.Get("References"),
+		AutoSubmitted: (autoSubmitted != "" && !strings.EqualFold(autoSubmitted, "no")) ||
+			msg.Header.Get("X-Autoreply") != "" || strings.EqualFold(msg.Header.Get("Precedence"), "bulk"),
+	}
+
+	for _, header := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, value := range msg.Header[header] {
+			addresses, err := mail.ParseAddressList(value)
+			if err != nil {
+				continue
+			}
+			for _, address := range addresses {
+				inbound.Recipients = append(inbound.Recipients, strings.ToLower(address.Address))
+			}
+		}
+	}
+
+	var parts inboundParts
+	if err := parts.read(msg.Body, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), "", 0); err != nil {
+		return nil, err
+	}
+
+	inbound.Text = parts.text
+	if inbound.Text == "" && parts.html != "" {
+		inbound.Text = htmlToText(parts.html)
+	}
+	inbound.Attachments = parts.attachments
+
+	return inbound, nil
+}
+
+type inboundParts struct {
+	text        string
+	html        string
+	attachments []Attachment
+}
+
+func (p *inboundParts) read(body io.Reader, contentType, encoding, disposition string, depth int) error {
+	if depth > maxMimeDepth {
+		return errors.New("message parts are nested too deeply")
+	}
+
+	if contentType == "" {
+		contentType = "text/plain; charset=us-ascii"
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read message part: %w", err)
+			}
+
+			if err := p.read(part, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(body, encoding))
+	if err != nil {
+		return fmt.Errorf("failed to decode message part: %w", err)
+	}
+
+	dispositionType, dispositionParams, _ := mime.ParseMediaType(disposition)
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	isBody := dispositionType != "attachment" && filename == ""
+	switch {
+	case isBody && mediaType == "text/plain" && p.text == "":
+		p.text = decodeCharset(data, params["charset"])
+	case isBody && mediaType == "text/html" && p.html == "":
+		p.html = decodeCharset(data, params["charset"])
+	default:
+		if filename == "" {
+			filename = "attachment"
+		}
+		if decoded, err := new(mime.WordDecoder).DecodeHeader(filename); err == nil {
+			filename = decoded
+		}
+
+		p.attachments = append(p.attachments, Attachment{
+			Filename:    filename,
+			ContentType: mediaType,
+			Data:        data,
+		})
+	}
+
+	return nil
+}
+
+func decodeTransfer(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// newlineStripper drops the line breaks base64 bodies are wrapped with
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s *newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+			p[kept] = b
+			kept++
+		}
+	}
+	if kept == 0 && err == nil {
+		return s.Read(p)
+	}
+	return kept, err
+}
+
+// decodeCharset turns the text into utf-8, only latin-1 needs converting
+// among the charsets that are not already utf-8 compatible
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(bytes.ToValidUTF8(data, []byte("�")))
+	}
+}
+
+var (
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</tr>|</h[1-6]>`)
+	htmlQuotes = regexp.MustCompile(`(?is)<blockquote.*?</blockquote>`)
+	htmlHidden = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)>`)
+	htmlTags   = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText keeps the text of an html body, dropping quoted blocks
+func htmlToText(s string) string {
+	s = htmlHidden.ReplaceAllString(s, "")
+	s = htmlQuotes.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = htmlTags.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+var (
+	// "On Mon, 1 Jan 2024 at 10:00, Guest <guest@example.com> wrote:", possibly wrapped over two lines
+	replyHeader     = regexp.MustCompile(`(?i)^(on\s.+|am\s.+|le\s.+)(wrote|schrieb|a écrit)\s*:$`)
+	replyHeaderHead = regexp.MustCompile(`(?i)^(on|am|le)\s.+`)
+	outlookHeader   = regexp.MustCompile(`(?i)^(-{2,}\s*original message\s*-{2,}|_{10,}|from:\s.+)$`)
+)
+
+// StripQuoted removes the quoted previous messages and the signature from a reply
+func StripQuoted(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var kept []string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var previous string
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// everything after the reply header or the signature delimiter goes
+		if line == "-- " || outlookHeader.MatchString(trimmed) || replyHeader.MatchString(trimmed) {
+			break
+		}
+		if replyHeaderHead.MatchString(previous) && replyHeader.MatchString(previous+" "+trimmed) {
+			kept = kept[:len(kept)-1]
+			break
+		}
+
+		previous = trimmed
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+var ticketToken = regexp.MustCompile(`(?i)\[ticket:([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\]`)
+
+var ticketReference = regexp.MustCompile(`(?i)<ticket\.([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})@`)
+
+// TicketToken is the reference put in the subject of emails about a ticket
+func TicketToken(ticketId uuid.UUID) string {
+	return "[ticket:" + ticketId.String() + "]"
+}
+
+// TicketMessageId is the message id emails about a ticket refer to in their References
+// header, mail clients keep it in the References of replies
+func TicketMessageId(ticketId uuid.UUID, from string) string {
+	return "<ticket." + ticketId.String() + "@" + addressDomain(from) + ">"
+}
+
+// TicketReference finds the ticket the message is about from the token in its
+// subject or the ticket message id in its In-Reply-To or References headers
+func (m *InboundMessage) TicketReference() (uuid.UUID, bool) {
+	for _, match := range [][]string{
+		ticketToken.FindStringSubmatch(m.Subject),
+		ticketReference.FindStringSubmatch(m.InReplyTo),
+		ticketReference.FindStringSubmatch(m.References),
+	} {
+		if match == nil {
+			continue
+		}
+		if ticketId, err := uuid.Parse(match[1]); err == nil {
+			return ticketId, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+// replyTokenEncoding is lower cased in addresses, mtas are free to change the case of local parts
+var replyTokenEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// replyMacSize is how much of the hmac the reply token keeps, it has to fit the 64 characters of
+// a local part next to the user id
+const replyMacSize = 10
+
+func replyMac(secret string, ticketId, userId uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(ticketId[:])
+	mac.Write(userId[:])
+	return mac.Sum(nil)[:replyMacSize]
+}
+
+// ReplyAddress adds a token signed with secret to the local part of the address (local+token@domain),
+// replying to it proves the reply comes from the user the email about the ticket was sent to.
+// ticketId is uuid.Nil for emails that are not about a ticket.
+func ReplyAddress(address, secret string, ticketId, userId uuid.UUID) (string, error) {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid reply address %q: %w", address, err)
+	}
+
+	at := strings.LastIndex(addr.Address, "@")
+	if at == -1 {
+		return "", fmt.Errorf("invalid reply address %q", address)
+	}
+
+	token := replyTokenEncoding.EncodeToString(append(userId[:], replyMac(secret, ticketId, userId)...))
+	addr.Address = addr.Address[:at] + "+" + strings.ToLower(token) + addr.Address[at:]
+
+	return addr.String(), nil
+}
+
+// ReplyUser is the user the reply token among the recipients was signed for, when there
+// is one signed with secret for the ticket
+func (m *InboundMessage) ReplyUser(secret string, ticketId uuid.UUID) (uuid.UUID, bool) {
+	for _, recipient := range m.Recipients {
+		at := strings.LastIndex(recipient, "@")
+		if at == -1 {
+			continue
+		}
+
+		_, token, ok := strings.Cut(recipient[:at], "+")
+		if !ok {
+			continue
+		}
+
+		data, err := replyTokenEncoding.DecodeString(strings.ToUpper(token))
+		if err != nil || len(data) != len(uuid.Nil)+replyMacSize {
+			continue
+		}
+
+		userId, err := uuid.FromBytes(data[:len(uuid.Nil)])
+		if err != nil {
+			continue
+		}
+
+		if hmac.Equal(data[len(uuid.Nil):], replyMac(secret, ticketId, userId)) {
+			return userId, true
+		}
+	}
+
+	return uuid.Nil, false
+}
+
+var subjectPrefixes = regexp.MustCompile(`(?i)^((re|fwd?|aw|wg|tr)\s*:\s*)+`)
+
+// TicketTitle is the subject without reply prefixes and the ticket token
+func (m *InboundMessage) TicketTitle() string {
+	title := ticketToken.ReplaceAllString(m.Subject, "")
+	title = strings.TrimSpace(subjectPrefixes.ReplaceAllString(strings.TrimSpace(title), ""))
+	return strings.Join(strings.Fields(title), " ")
+}
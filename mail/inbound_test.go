@@ -0,0 +1,168 @@
+package mail_test
+
+import (
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/mail"
+)
+
+func TestParseInbound(t *testing.T) {
+	ticketId := uuid.New()
+
+	raw := strings.Join([]string{
+		"From: =?utf-8?q?J=C3=BCrgen?= <Guest@Example.com>",
+		"To: frontdesk@ticketr.local",
+		"Subject: Re: Re: broken lamp " + mail.TicketToken(ticketId),
+		"Message-Id: <reply-1@example.com>",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"Still flickering, see the photo.=0A",
+		"",
+		"On Mon, 1 Jan 2024 at 10:00, Ticketr <no-reply@ticketr.local> wrote:",
+		"> staff@test.com replied to \"broken lamp\":",
+		"--inner",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<p>Still flickering, see the photo.</p>",
+		"--inner--",
+		"--outer",
+		`Content-Type: image/png; name="lamp.png"`,
+		"Content-Transfer-Encoding: base64",
+		`Content-Disposition: attachment; filename="lamp.png"`,
+		"",
+		"iVBORw0K",
+		"GgoAAAA=",
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	msg, err := mail.ParseInbound(strings.NewReader(raw))
+	require.NoError(t, err)
+
+	require.Equal(t, "guest@example.com", msg.From)
+	require.Equal(t, []string{"frontdesk@ticketr.local"}, msg.Recipients)
+	require.False(t, msg.AutoSubmitted)
+	require.Equal(t, "broken lamp", msg.TicketTitle())
+	require.Equal(t, "Still flickering, see the photo.", mail.StripQuoted(msg.Text))
+
+	referenced, ok := msg.TicketReference()
+	require.True(t, ok)
+	require.Equal(t, ticketId, referenced)
+
+	require.Len(t, msg.Attachments, 1)
+	require.Equal(t, "lamp.png", msg.Attachments[0].Filename)
+	require.Equal(t, "image/png", msg.Attachments[0].ContentType)
+	require.Equal(t, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00"), msg.Attachments[0].Data)
+}
+
+func TestParseInboundHtmlOnly(t *testing.T) {
+	raw := strings.Join([]string{
+		"From: guest@example.com",
+		"Subject: towels",
+		"Auto-Submitted: auto-replied",
+		"Content-Type: text/html; charset=utf-8",
+		"",
+		"<html><head><style>p { color: red }</style></head><body>",
+		"<p>Two more towels &amp; soap please</p>",
+		"<blockquote>earlier message</blockquote>",
+		"</body></html>",
+	}, "\r\n")
+
+	msg, err := mail.ParseInbound(strings.NewReader(raw))
+	require.NoError(t, err)
+	require.True(t, msg.AutoSubmitted)
+	require.Equal(t, "Two more towels & soap please", msg.Text)
+
+	_, ok := msg.TicketReference()
+	require.False(t, ok)
+}
+
+func TestReplyAddress(t *testing.T) {
+	ticketId := uuid.New()
+	userId := uuid.New()
+
+	address, err := mail.ReplyAddress("Front Desk <frontdesk@ticketr.local>", "secret", ticketId, userId)
+	require.NoError(t, err)
+
+	parsed, err := netmail.ParseAddress(address)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(parsed.Address, "frontdesk+"))
+	require.True(t, strings.HasSuffix(parsed.Address, "@ticketr.local"))
+	require.LessOrEqual(t, strings.Index(parsed.Address, "@"), 64)
+
+	// mtas may change the case of the local part
+	msg := &mail.InboundMessage{Recipients: []string{"other@ticketr.local", strings.ToUpper(parsed.Address)}}
+	replyUser, ok := msg.ReplyUser("secret", ticketId)
+	require.True(t, ok)
+	require.Equal(t, userId, replyUser)
+
+	// the token only holds for its ticket and secret
+	_, ok = msg.ReplyUser("secret", uuid.New())
+	require.False(t, ok)
+	_, ok = msg.ReplyUser("other secret", ticketId)
+	require.False(t, ok)
+
+	// nor once the user id in it is changed
+	local, domain, _ := strings.Cut(parsed.Address, "@")
+	forged := []byte(local)
+	forged[len("frontdesk+")] ^= 1
+	msg = &mail.InboundMessage{Recipients: []string{string(forged) + "@" + domain}}
+	_, ok = msg.ReplyUser("secret", ticketId)
+	require.False(t, ok)
+
+	msg = &mail.InboundMessage{Recipients: []string{"frontdesk@ticketr.local"}}
+	_, ok = msg.ReplyUser("secret", ticketId)
+	require.False(t, ok)
+}
+
+func TestStripQuoted(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "quoted lines",
+			text:     "thanks!\n> earlier\n> message",
+			expected: "thanks!",
+		},
+		{
+			name:     "wrapped reply header",
+			text:     "thanks!\n\nOn Mon, 1 Jan 2024 at 10:00, Front Desk\n<frontdesk@ticketr.local> wrote:\n\nearlier message",
+			expected: "thanks!",
+		},
+		{
+			name:     "outlook",
+			text:     "thanks!\r\n\r\n-----Original Message-----\r\nFrom: Front Desk",
+			expected: "thanks!",
+		},
+		{
+			name:     "signature",
+			text:     "thanks!\n-- \nJürgen\nRoom 412",
+			expected: "thanks!",
+		},
+		{
+			name:     "nothing quoted",
+			text:     "the tv in room 412\nhas no signal",
+			expected: "the tv in room 412\nhas no signal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, mail.StripQuoted(tt.text))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text body and optionally an html alternative
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are set on top of the ones every message gets, e.g. In-Reply-To
+	Headers map[string]string
+}
+
+// Transport sends messages, it is what decides where mail ends up
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Validate checks the message can be sent
+func (m *Message) Validate() error {
+	if _, err := mail.ParseAddress(m.From); err != nil {
+		return fmt.Errorf("invalid from address %q: %w", m.From, err)
+	}
+
+	if len(m.To) == 0 {
+		return fmt.Errorf("message has no recipients")
+	}
+
+	for _, to := range m.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient address %q: %w", to, err)
+		}
+	}
+
+	return nil
+}
+
+// Bytes encodes the message as RFC 5322 mail, multipart/alternative when it has an html body
+func (m *Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	headers := map[string]string{
+		"From":         m.From,
+		"To":           strings.Join(m.To, ", "),
+		"Subject":      mime.QEncoding.Encode("utf-8", m.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-Id":   newMessageId(m.From),
+		"MIME-Version": "1.0",
+	}
+	for key, value := range m.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(key)] = value
+	}
+
+	if m.HTML == "" {
+		headers["Content-Type"] = "text/plain; charset=utf-8"
+		headers["Content-Transfer-Encoding"] = "quoted-printable"
+		writeHeaders(&buf, headers)
+
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	headers["Content-Type"] = "multipart/alternative; boundary=" + parts.Boundary()
+	writeHeaders(&buf, headers)
+
+	// the last part is the preferred one
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create message part: %w", err)
+		}
+
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close message parts: %w", err)
+	}
+
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+func writeHeaders(buf *bytes.Buffer, headers map[string]string) {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s: %s\r\n", key, headers[key])
+	}
+	buf.WriteString("\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return fmt.Errorf("failed to encode message body: %w", err)
+	}
+	return qp.Close()
+}
+
+func newMessageId(from string) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), addressDomain(from))
+}
+
+// addressDomain is the domain of the address, message ids are made unique with it
+func addressDomain(address string) string {
+	if addr, err := mail.ParseAddress(address); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at != -1 {
+			return addr.Address[at+1:]
+		}
+	}
+	return "localhost"
+}
+
+// envelopeAddress is the bare address of "Name <address>" as smtp wants it
+func envelopeAddress(address string) (string, error) {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	return addr.Address, nil
+}
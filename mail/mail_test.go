@@ -0,0 +1,123 @@
+package mail_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/mail"
+)
+
+func TestMessageBytes(t *testing.T) {
+	msg := &mail.Message{
+		From:    "Ticketr <no-reply@ticketr.local>",
+		To:      []string{"guest@test.com"},
+		Subject: "Re: Zimmer 412 – Tür klemmt",
+		Text:    "someone is on the way",
+		HTML:    "<p>someone is on the way</p>",
+		Headers: map[string]string{"in-reply-to": "<abc@ticketr.local>"},
+	}
+
+	data, err := msg.Bytes()
+	require.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, msg.Subject, subject)
+	require.Equal(t, "<abc@ticketr.local>", parsed.Header.Get("In-Reply-To"))
+	require.True(t, strings.HasSuffix(parsed.Header.Get("Message-Id"), "@ticketr.local>"))
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.NextRawPart()
+		require.NoError(t, err)
+		require.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(t, err)
+		require.Equal(t, expected.body, string(body))
+	}
+
+	_, err = parts.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestTemplatesRender(t *testing.T) {
+	templates, err := mail.LoadTemplates()
+	require.NoError(t, err)
+
+	data := map[string]any{
+		"Recipient": "guest@test.com",
+		"Reason":    "creator",
+		"Actor":     "staff@test.com",
+		"Ticket":    map[string]any{"Title": "<b>broken</b> lamp"},
+		"Reply":     "fixed & tested",
+	}
+
+	var msg mail.Message
+	require.NoError(t, templates.Render("replied", data, &msg))
+
+	require.Equal(t, "Re: <b>broken</b> lamp", msg.Subject)
+	require.Contains(t, msg.Text, "fixed & tested")
+	require.Contains(t, msg.Text, "you opened this ticket")
+	require.Contains(t, msg.HTML, "&lt;b&gt;broken&lt;/b&gt; lamp")
+	require.Contains(t, msg.HTML, "fixed &amp; tested")
+
+	require.Error(t, templates.Render("missing", data, &msg))
+
+	// the due date may have been cleared since the reminder was queued
+	data["Details"] = map[string]any{"before": "1 hour"}
+	require.NoError(t, templates.Render("due_soon", data, &msg))
+	require.Contains(t, msg.Text, "is due in 1 hour.")
+
+	// or since the ticket breached it
+	require.NoError(t, templates.Render("sla_breach", data, &msg))
+	require.Equal(t, "Overdue: <b>broken</b> lamp", msg.Subject)
+	require.Contains(t, msg.Text, "is past its due date and is not done yet")
+
+	digest := map[string]any{
+		"Recipient": "guest@test.com",
+		"Reason":    "digest",
+		"Items": []map[string]any{
+			{"Subject": "Re: broken lamp", "Text": "fixed"},
+			{"Subject": "Ticket closed: broken lamp", "Text": "closed"},
+		},
+	}
+
+	require.NoError(t, templates.Render("digest", digest, &msg))
+	require.Equal(t, "Your daily digest: 2 ticket updates", msg.Subject)
+	require.Contains(t, msg.Text, "Ticket closed: broken lamp\nclosed")
+	require.Contains(t, msg.Text, "daily digest in your notification preferences")
+}
+
+func TestMemoryTransport(t *testing.T) {
+	transport := mail.NewMemoryTransport()
+
+	require.Error(t, transport.Send(context.Background(), &mail.Message{From: "no-reply@ticketr.local"}))
+
+	require.NoError(t, transport.Send(context.Background(), &mail.Message{
+		From: "no-reply@ticketr.local",
+		To:   []string{"guest@test.com"},
+		Text: "hello",
+	}))
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "hello", messages[0].Text)
+}
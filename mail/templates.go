@@ -0,0 +1,87 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// the layout wraps every template, which defines "subject", "text" and "body"
+const layoutTemplate = "templates/layout.tmpl"
+
+// Templates renders the emails, the subject and text parts with text/template
+// and the html part with html/template so that content is escaped
+type Templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func LoadTemplates() (*Templates, error) {
+	files, err := fs.Glob(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mail templates: %w", err)
+	}
+
+	t := &Templates{
+		text: map[string]*texttemplate.Template{},
+		html: map[string]*htmltemplate.Template{},
+	}
+
+	for _, file := range files {
+		if file == layoutTemplate {
+			continue
+		}
+
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+
+		text, err := texttemplate.ParseFS(templateFiles, layoutTemplate, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s: %w", name, err)
+		}
+
+		html, err := htmltemplate.ParseFS(templateFiles, layoutTemplate, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s: %w", name, err)
+		}
+
+		t.text[name], t.html[name] = text, html
+	}
+
+	return t, nil
+}
+
+// Render fills in the subject and both bodies of the message from the named template
+func (t *Templates) Render(name string, data any, msg *Message) error {
+	text, ok := t.text[name]
+	if !ok {
+		return fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+
+	if err := text.ExecuteTemplate(&textBody, "layout.text", data); err != nil {
+		return fmt.Errorf("failed to render text of %s: %w", name, err)
+	}
+
+	if err := t.html[name].ExecuteTemplate(&htmlBody, "layout.html", data); err != nil {
+		return fmt.Errorf("failed to render html of %s: %w", name, err)
+	}
+
+	msg.Subject = strings.Join(strings.Fields(subject.String()), " ")
+	msg.Text = strings.TrimSpace(textBody.String()) + "\n"
+	msg.HTML = htmlBody.String()
+
+	return nil
+}
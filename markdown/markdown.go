@@ -0,0 +1,37 @@
+// Package markdown renders the CommonMark of ticket descriptions and replies to HTML
+// that is safe to show in a browser
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// raw HTML is left out by goldmark unless it is asked to keep it, the policy
+// then removes whatever else is not on its allow-list, such as javascript: links
+var (
+	renderer = goldmark.New()
+	policy   = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render turns CommonMark into sanitized HTML. Markdown that fails to render, which
+// goldmark only does when writing fails, is shown as escaped text instead.
+func Render(source string) string {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return strings.TrimSpace(policy.Sanitize(buf.String()))
+}
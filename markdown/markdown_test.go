@@ -0,0 +1,41 @@
+package markdown_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/markdown"
+)
+
+func TestRender(t *testing.T) {
+	html := markdown.Render("Please **double check**:\n\n- the minibar\n- the [checklist](https://wiki.hotel.com/minibar)\n")
+
+	require.Contains(t, html, "<strong>double check</strong>")
+	require.Contains(t, html, "<li>the minibar</li>")
+	require.Contains(t, html, `href="https://wiki.hotel.com/minibar"`)
+	require.Contains(t, html, `rel="nofollow noreferrer noopener"`)
+	require.Contains(t, html, `target="_blank"`)
+}
+
+func TestRenderNeutralizesXSS(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`![x](javascript:alert(1))`,
+		`<a href="javascript:alert(1)">click</a>`,
+		`<iframe src="https://evil.com"></iframe>`,
+		`<div style="background:url(javascript:alert(1))">x</div>`,
+		"[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+		"<svg onload=alert(1)>",
+	}
+
+	for _, payload := range payloads {
+		html := strings.ToLower(markdown.Render(payload))
+		for _, forbidden := range []string{"<script", "onerror", "onload", "javascript:", "<iframe", "<svg", "style=", "data:text"} {
+			require.NotContains(t, html, forbidden, "payload %q", payload)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE tickets ADD COLUMN category VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE TABLE ticket_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    title_pattern VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    priority SMALLINT NOT NULL DEFAULT 3,
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    variables TEXT[] NOT NULL DEFAULT '{}',
+    creator UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_templates;
+ALTER TABLE tickets DROP COLUMN IF EXISTS category;
+-- +goose StatementEnd
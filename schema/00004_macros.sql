@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE tickets ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE macros (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    owner UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shared BOOLEAN NOT NULL DEFAULT FALSE,
+    reply TEXT NOT NULL DEFAULT '',
+    set_status SMALLINT,
+    set_priority SMALLINT,
+    add_tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS macros;
+ALTER TABLE tickets DROP COLUMN IF EXISTS tags;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE users ADD COLUMN available BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE assignment_queues (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    strategy VARCHAR(20) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_assignee UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX tickets_current_assignee_status_idx ON tickets (current_assignee, status);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tickets_current_assignee_status_idx;
+DROP TABLE IF EXISTS assignment_queues;
+ALTER TABLE users DROP COLUMN IF EXISTS available;
+-- +goose StatementEnd
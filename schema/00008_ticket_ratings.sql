@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE tickets ADD COLUMN resolved_at TIMESTAMPTZ;
+
+-- ratings outlive their ticket, closed tickets are archived and deleted,
+-- so the assignee, team and category are copied at rating time
+CREATE TABLE ticket_ratings (
+    ticket_id UUID PRIMARY KEY,
+    creator UUID REFERENCES users(id) ON DELETE SET NULL,
+    assignee UUID REFERENCES users(id) ON DELETE SET NULL,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_ratings;
+ALTER TABLE tickets DROP COLUMN IF EXISTS resolved_at;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the last reply each participant has read, for chat read receipts
+CREATE TABLE ticket_reads (
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reply_id UUID NOT NULL REFERENCES ticket_replies(id) ON DELETE CASCADE,
+    read_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticket_id, user_id)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_reads;
+-- +goose StatementEnd
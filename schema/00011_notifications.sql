@@ -0,0 +1,47 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE ticket_watchers (
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticket_id, user_id)
+);
+
+-- ticket events waiting to be turned into notifications, written with the change itself
+CREATE TABLE notification_events (
+    id BIGSERIAL PRIMARY KEY,
+    payload JSONB NOT NULL,
+    claimed_until TIMESTAMPTZ NOT NULL DEFAULT '-infinity',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX notification_events_pending_idx ON notification_events (id) WHERE processed_at IS NULL;
+
+-- the outgoing mail queue
+CREATE TABLE emails (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    to_address VARCHAR(320) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX emails_due_idx ON emails (next_attempt_at) WHERE status = 'pending';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS emails;
+DROP TABLE IF EXISTS notification_events;
+DROP TABLE IF EXISTS ticket_watchers;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the ticket an email is about, replies to it are matched back to the ticket
+ALTER TABLE emails ADD COLUMN ticket_id UUID;
+
+CREATE TABLE ticket_attachments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    reply_id UUID REFERENCES ticket_replies(id) ON DELETE CASCADE,
+    uploader UUID REFERENCES users(id) ON DELETE SET NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    s3_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ticket_attachments_ticket_idx ON ticket_attachments (ticket_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_attachments;
+ALTER TABLE emails DROP COLUMN IF EXISTS ticket_id;
+-- +goose StatementEnd
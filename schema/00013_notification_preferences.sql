@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- the channels per kind of notification, kinds that are missing use the defaults
+    channels JSONB NOT NULL DEFAULT '{}',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    -- HH:MM in the timezone, both empty when there are no quiet hours
+    quiet_start VARCHAR(5) NOT NULL DEFAULT '',
+    quiet_end VARCHAR(5) NOT NULL DEFAULT '',
+    digest BOOLEAN NOT NULL DEFAULT FALSE,
+    digest_hour SMALLINT NOT NULL DEFAULT 8,
+    last_digest_at TIMESTAMPTZ,
+    chat_webhook_url TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- emails held for the daily digest
+CREATE INDEX emails_digest_idx ON emails (user_id) WHERE status = 'digest';
+
+CREATE TABLE chat_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    text TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX chat_notifications_due_idx ON chat_notifications (next_attempt_at) WHERE status = 'pending';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_notifications;
+DROP INDEX IF EXISTS emails_digest_idx;
+DROP TABLE IF EXISTS notification_preferences;
+-- +goose StatementEnd
@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- tickets opened from a template on a schedule, e.g. preventive maintenance
+CREATE TABLE ticket_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    template_id UUID NOT NULL REFERENCES ticket_templates(id) ON DELETE CASCADE,
+    variables JSONB NOT NULL DEFAULT '{}',
+    -- 'cron' or 'rrule'
+    kind VARCHAR(10) NOT NULL,
+    expression TEXT NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    -- the first run is at or after starts_at, it is also the DTSTART of rrules
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- what happens to runs the scheduler was too late for: 'skip', 'once' or 'all'
+    catch_up VARCHAR(10) NOT NULL DEFAULT 'once',
+    priority SMALLINT,
+    assignee UUID REFERENCES users(id) ON DELETE SET NULL,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- NULL once the schedule has no more runs
+    next_run_at TIMESTAMPTZ,
+    last_run_at TIMESTAMPTZ,
+    creator UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX ticket_schedules_due_idx ON ticket_schedules (next_run_at) WHERE enabled;
+
+-- every run of a schedule once, whichever scheduler gets to it
+CREATE TABLE ticket_schedule_runs (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES ticket_schedules(id) ON DELETE CASCADE,
+    run_at TIMESTAMPTZ NOT NULL,
+    ticket_id UUID REFERENCES tickets(id) ON DELETE SET NULL,
+    -- 'created', 'skipped' or 'failed'
+    outcome VARCHAR(10) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (schedule_id, run_at)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_schedule_runs;
+DROP TABLE IF EXISTS ticket_schedules;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE tickets ADD COLUMN due_at TIMESTAMPTZ;
+CREATE INDEX tickets_due_at_idx ON tickets (due_at) WHERE due_at IS NOT NULL;
+
+-- the reminders sent to the assignee before a due date, a new due date is reminded of again
+CREATE TABLE ticket_reminders (
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    due_at TIMESTAMPTZ NOT NULL,
+    before_seconds BIGINT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticket_id, due_at, before_seconds)
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ticket_reminders;
+DROP INDEX IF EXISTS tickets_due_at_idx;
+ALTER TABLE tickets DROP COLUMN IF EXISTS due_at;
+-- +goose StatementEnd
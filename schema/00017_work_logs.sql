@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the time staff spent working on tickets
+CREATE TABLE work_logs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seconds INTEGER NOT NULL CHECK (seconds > 0),
+    note TEXT NOT NULL DEFAULT '',
+    worked_on DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX work_logs_ticket_id_idx ON work_logs (ticket_id);
+CREATE INDEX work_logs_worked_on_idx ON work_logs (worked_on);
+
+-- the running timer of each user, stopping it logs the time on its ticket
+CREATE TABLE work_timers (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS work_timers;
+DROP TABLE IF EXISTS work_logs;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the users mentioned in a reply, with the mention as it was written
+CREATE TABLE reply_mentions (
+    reply_id UUID NOT NULL REFERENCES ticket_replies(id) ON DELETE CASCADE,
+    ticket_id UUID NOT NULL REFERENCES tickets(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mention TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (reply_id, user_id)
+);
+
+CREATE INDEX reply_mentions_user_id_created_at_idx ON reply_mentions (user_id, created_at DESC);
+CREATE INDEX reply_mentions_ticket_id_idx ON reply_mentions (ticket_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reply_mentions;
+-- +goose StatementEnd
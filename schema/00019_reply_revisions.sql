@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+ALTER TABLE ticket_replies ADD COLUMN edited_at TIMESTAMPTZ;
+-- deleted replies stay in the thread as tombstones without a message
+ALTER TABLE ticket_replies ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- the messages replies had before they were edited or deleted
+CREATE TABLE reply_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reply_id UUID NOT NULL REFERENCES ticket_replies(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    replaced_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    replaced_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX reply_revisions_reply_id_idx ON reply_revisions (reply_id, replaced_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS reply_revisions;
+ALTER TABLE ticket_replies DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE ticket_replies DROP COLUMN IF EXISTS edited_at;
+-- +goose StatementEnd
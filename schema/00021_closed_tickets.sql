@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- what the reports need of closed tickets, which are archived and deleted,
+-- copied when they are closed so volume and response times keep counting them
+CREATE TABLE closed_tickets (
+    id UUID PRIMARY KEY,
+    creator UUID REFERENCES users(id) ON DELETE SET NULL,
+    current_assignee UUID REFERENCES users(id) ON DELETE SET NULL,
+    team_id UUID REFERENCES teams(id) ON DELETE SET NULL,
+    category VARCHAR(50) NOT NULL DEFAULT '',
+    priority SMALLINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    resolved_at TIMESTAMPTZ,
+    first_response_at TIMESTAMPTZ,
+    closed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX closed_tickets_created_at_idx ON closed_tickets (created_at);
+CREATE INDEX closed_tickets_resolved_at_idx ON closed_tickets (resolved_at) WHERE resolved_at IS NOT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS closed_tickets;
+-- +goose StatementEnd
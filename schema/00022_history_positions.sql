@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- history outlives its ticket so the event stream still has the events of closed tickets,
+-- who could see the ticket is copied onto each entry for the same reason
+ALTER TABLE ticket_history DROP CONSTRAINT IF EXISTS ticket_history_ticket_id_fkey;
+ALTER TABLE ticket_history ADD COLUMN creator UUID;
+ALTER TABLE ticket_history ADD COLUMN current_assignee UUID;
+ALTER TABLE ticket_history ADD COLUMN team_id UUID;
+
+UPDATE ticket_history h SET creator = t.creator, current_assignee = t.current_assignee, team_id = t.team_id
+FROM tickets t WHERE t.id = h.ticket_id;
+
+-- the position of an entry in the event stream. Ids are taken when rows are inserted but rows
+-- become visible when their transaction commits, so a lower id can show up after a higher one.
+-- Positions are given out while committing, one transaction at a time, and follow commit order.
+ALTER TABLE ticket_history ADD COLUMN position BIGINT;
+UPDATE ticket_history SET position = id;
+CREATE UNIQUE INDEX ticket_history_position_idx ON ticket_history (position);
+
+CREATE SEQUENCE ticket_history_position_seq;
+SELECT setval('ticket_history_position_seq', COALESCE(MAX(position), 0) + 1, false) FROM ticket_history;
+
+CREATE FUNCTION ticket_history_position() RETURNS trigger AS $$
+BEGIN
+    -- held until the transaction ends, after its rows became visible
+    PERFORM pg_advisory_xact_lock(hashtext('ticket_history_position'));
+    UPDATE ticket_history SET position = nextval('ticket_history_position_seq') WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ticket_history_position AFTER INSERT ON ticket_history
+DEFERRABLE INITIALLY DEFERRED FOR EACH ROW EXECUTE FUNCTION ticket_history_position();
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS ticket_history_position ON ticket_history;
+DROP FUNCTION IF EXISTS ticket_history_position();
+DROP SEQUENCE IF EXISTS ticket_history_position_seq;
+DROP INDEX IF EXISTS ticket_history_position_idx;
+ALTER TABLE ticket_history DROP COLUMN IF EXISTS position;
+ALTER TABLE ticket_history DROP COLUMN IF EXISTS team_id;
+ALTER TABLE ticket_history DROP COLUMN IF EXISTS current_assignee;
+ALTER TABLE ticket_history DROP COLUMN IF EXISTS creator;
+DELETE FROM ticket_history h WHERE NOT EXISTS (SELECT 1 FROM tickets t WHERE t.id = h.ticket_id);
+ALTER TABLE ticket_history ADD CONSTRAINT ticket_history_ticket_id_fkey
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE;
+-- +goose StatementEnd
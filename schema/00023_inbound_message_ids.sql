@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the emails taken in by message id, an mta retrying a delivery gets the first result back
+-- instead of opening a second ticket. There is no foreign key, the ticket may be closed since.
+CREATE TABLE inbound_emails (
+    message_id TEXT PRIMARY KEY,
+    ticket_id UUID NOT NULL,
+    reply_id UUID,
+    attachments INT NOT NULL DEFAULT 0,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS inbound_emails;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- tickets that are already overdue are not reported as breaching their due date all at once,
+-- a breach is recorded as the reminder 0 seconds before the due date
+INSERT INTO ticket_reminders (ticket_id, due_at, before_seconds)
+SELECT id, due_at, 0 FROM tickets WHERE due_at <= CURRENT_TIMESTAMP
+ON CONFLICT DO NOTHING;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM ticket_reminders WHERE before_seconds = 0;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the inbox keeps the notifications of closed tickets, the notification of the closure included.
+-- Who may see them is decided by the ticket as it was closed, which closed_tickets keeps.
+ALTER TABLE notifications DROP CONSTRAINT notifications_ticket_id_fkey;
+CREATE INDEX notifications_ticket_id_idx ON notifications (ticket_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS notifications_ticket_id_idx;
+DELETE FROM notifications n WHERE NOT EXISTS (SELECT 1 FROM tickets t WHERE t.id = n.ticket_id);
+ALTER TABLE notifications ADD CONSTRAINT notifications_ticket_id_fkey
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- work logs outlive their ticket, the labour reports keep counting closed tickets. The title
+-- and category the reports group by are kept on the log.
+ALTER TABLE work_logs DROP CONSTRAINT work_logs_ticket_id_fkey;
+ALTER TABLE work_logs ADD COLUMN ticket_title TEXT NOT NULL DEFAULT '';
+ALTER TABLE work_logs ADD COLUMN category VARCHAR(50) NOT NULL DEFAULT '';
+
+UPDATE work_logs l SET ticket_title = t.title, category = t.category FROM tickets t WHERE t.id = l.ticket_id;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM work_logs l WHERE NOT EXISTS (SELECT 1 FROM tickets t WHERE t.id = l.ticket_id);
+ALTER TABLE work_logs DROP COLUMN IF EXISTS category;
+ALTER TABLE work_logs DROP COLUMN IF EXISTS ticket_title;
+ALTER TABLE work_logs ADD CONSTRAINT work_logs_ticket_id_fkey
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE;
+-- +goose StatementEnd
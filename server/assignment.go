@@ -0,0 +1,202 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type AvailabilityRequest struct {
+	Available bool `json:"available"`
+}
+
+func (req AvailabilityRequest) Validate() error {
+	return nil
+}
+
+type AvailabilityResponse struct {
+	Available bool `json:"available"`
+}
+
+func (s *Server) updateAvailabilityHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[AvailabilityRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		user, err = s.store.User.SetAvailability(r.Context(), user.Id, req.Available)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[AvailabilityResponse]](w, http.StatusOK, ApiResponse[AvailabilityResponse]{
+			Data: &AvailabilityResponse{
+				Available: user.Available,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+type AssignmentQueueRequest struct {
+	Name     string                   `json:"name"`
+	Category string                   `json:"category"`
+	TeamId   uuid.UUID                `json:"team_id"`
+	Strategy store.AssignmentStrategy `json:"strategy"`
+	Enabled  bool                     `json:"enabled"`
+}
+
+func (req AssignmentQueueRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req AssignmentQueueRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if !req.Strategy.Valid() {
+		errs.Add("strategy", FieldInvalid, "strategy must be round_robin or least_open")
+	}
+
+	return errs
+}
+
+func (req AssignmentQueueRequest) params() store.AssignmentQueueParams {
+	return store.AssignmentQueueParams{
+		Name:     req.Name,
+		Category: req.Category,
+		TeamId:   req.TeamId,
+		Strategy: req.Strategy,
+		Enabled:  req.Enabled,
+	}
+}
+
+type UpdateAssignmentQueueRequest struct {
+	Id uuid.UUID `json:"id"`
+	AssignmentQueueRequest
+}
+
+func (req UpdateAssignmentQueueRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.AssignmentQueueRequest.validate()...).Err()
+}
+
+type DeleteAssignmentQueueRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteAssignmentQueueRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetAssignmentQueuesResponse struct {
+	Queues []store.AssignmentQueue `json:"queues"`
+}
+
+func (s *Server) getAssignmentQueuesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		queues, err := s.store.Queue.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetAssignmentQueuesResponse]](w, http.StatusOK, ApiResponse[GetAssignmentQueuesResponse]{
+			Data: &GetAssignmentQueuesResponse{
+				Queues: queues,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createAssignmentQueueHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[AssignmentQueueRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		queue, err := s.store.Queue.Create(r.Context(), req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.AssignmentQueue]](w, http.StatusCreated, ApiResponse[store.AssignmentQueue]{
+			Data: queue,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateAssignmentQueueHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateAssignmentQueueRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		queue, err := s.store.Queue.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.AssignmentQueue]](w, http.StatusOK, ApiResponse[store.AssignmentQueue]{
+			Data: queue,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteAssignmentQueueHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteAssignmentQueueRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.Queue.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "assignment queue has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type GetAttachmentsResponse struct {
+	Attachments []store.TicketAttachment `json:"attachments"`
+}
+
+func (s *Server) getAttachmentsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		attachments, err := s.store.Attachment.ByTicketId(r.Context(), ticketId)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetAttachmentsResponse]](w, http.StatusOK, ApiResponse[GetAttachmentsResponse]{
+			Data: &GetAttachmentsResponse{
+				Attachments: attachments,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// downloadAttachmentHandler streams the content of an attachment from s3
+func (s *Server) downloadAttachmentHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		attachmentId, err := uuid.Parse(r.PathValue("attachmentId"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid attachment id: %w", err))
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		attachment, err := s.store.Attachment.ById(r.Context(), attachmentId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if attachment.TicketId != ticketId {
+			return NewApiError(http.StatusNotFound, fmt.Errorf("attachment %v does not belong to ticket %v", attachmentId, ticketId))
+		}
+
+		object, err := s.Config.S3Client.GetObject(r.Context(), &s3.GetObjectInput{
+			Bucket: aws.String(s.Config.S3Bucket),
+			Key:    aws.String(attachment.S3Key),
+		})
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, fmt.Errorf("failed to get attachment from s3: %w", err))
+		}
+		defer object.Body.Close()
+
+		// attachments are never rendered inline, they come from strangers' emails
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if _, err := io.Copy(w, object.Body); err != nil {
+			s.logger.Error("failed to stream attachment", "error", err, "attachment", attachmentId)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/tatucosmin/hotel-system/markdown"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	chatMaxFrameSize = 16 << 10
+	chatWriteTimeout = 10 * time.Second
+	chatPongTimeout  = 60 * time.Second
+	chatPingInterval = chatPongTimeout * 9 / 10
+)
+
+// chat events are relayed through the event bus to the other instances but not stored
+const (
+	eventChatTyping = "chat.typing"
+	eventChatRead   = "chat.read"
+)
+
+const (
+	ChatFrameMessage = "message"
+	ChatFrameEdited  = "edited"
+	ChatFrameDeleted = "deleted"
+	ChatFrameTyping  = "typing"
+	ChatFrameRead    = "read"
+	ChatFrameClosed  = "closed"
+	ChatFrameError   = "error"
+)
+
+var chatUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the access token is part of the url, not a cookie, so other origins gain nothing
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ChatRequest is what clients send: a message with a body, a typing
+// indicator, or a read receipt for a reply
+type ChatRequest struct {
+	Type    string    `json:"type"`
+	Body    string    `json:"body,omitempty"`
+	ReplyId uuid.UUID `json:"reply_id,omitempty"`
+}
+
+// ChatFrame is what the server sends, which fields are set depends on the type
+type ChatFrame struct {
+	Type    string         `json:"type"`
+	UserId  *uuid.UUID     `json:"user_id,omitempty"`
+	ReplyId *uuid.UUID     `json:"reply_id,omitempty"`
+	Reply   *ReplyResponse `json:"reply,omitempty"`
+	// Mentions are the users mentioned in the reply of a message
+	Mentions []store.Mention `json:"mentions,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ReplyResponse is a reply as it is read back, with its message rendered from markdown
+type ReplyResponse struct {
+	store.TicketReply
+	MessageHtml string `json:"message_html"`
+}
+
+func newReplyResponse(reply *store.TicketReply) *ReplyResponse {
+	return &ReplyResponse{
+		TicketReply: *reply,
+		MessageHtml: markdown.Render(reply.Message),
+	}
+}
+
+type chatConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *chatConn) send(frame ChatFrame) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+	return c.conn.WriteJSON(frame)
+}
+
+func (c *chatConn) ping() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(chatWriteTimeout))
+}
+
+// chatHandler upgrades to a websocket for live chat on a ticket. Messages are stored as
+// replies, typing indicators and read receipts reach the participants on every instance
+// through the event bus. Clients authenticate with ?access_token= when they cannot send headers.
+func (s *Server) chatHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		ws, err := chatUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader has already answered the request
+			s.logger.Error("failed to upgrade chat connection", "error", err)
+			return nil
+		}
+		defer ws.Close()
+
+		conn := &chatConn{conn: ws}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events := s.events.Subscribe(ctx)
+
+		reads, err := s.store.TicketReply.Reads(ctx, ticketId)
+		if err != nil {
+			s.logger.Error("failed to get chat read receipts", "error", err, "ticket", ticketId)
+			return nil
+		}
+		for _, read := range reads {
+			if err := conn.send(ChatFrame{Type: ChatFrameRead, UserId: &read.UserId, ReplyId: &read.ReplyId}); err != nil {
+				return nil
+			}
+		}
+
+		go s.relayChatEvents(ctx, cancel, conn, events, ticketId, user)
+
+		ws.SetReadLimit(chatMaxFrameSize)
+		ws.SetReadDeadline(time.Now().Add(chatPongTimeout))
+		ws.SetPongHandler(func(string) error {
+			return ws.SetReadDeadline(time.Now().Add(chatPongTimeout))
+		})
+
+		for {
+			var req ChatRequest
+			if err := ws.ReadJSON(&req); err != nil {
+				var closeErr *websocket.CloseError
+				if !errors.As(err, &closeErr) && ctx.Err() == nil {
+					s.logger.Info("chat connection ended", "error", err, "ticket", ticketId)
+				}
+				return nil
+			}
+
+			if err := s.handleChatRequest(ctx, req, ticketId, user); err != nil {
+				if err := conn.send(ChatFrame{Type: ChatFrameError, Error: err.Error()}); err != nil {
+					return nil
+				}
+			}
+		}
+	})
+}
+
+func (s *Server) handleChatRequest(ctx context.Context, req ChatRequest, ticketId uuid.UUID, user *store.User) error {
+	switch req.Type {
+	case ChatFrameMessage:
+		body := strings.TrimSpace(req.Body)
+		if body == "" {
+			return errors.New("message body is required")
+		}
+
+		// the reply reaches everyone, the sender included, as a ticket.replied event
+		if _, err := s.store.TicketReply.Create(ctx, ticketId, user.Id, body); err != nil {
+			s.logger.Error("failed to store chat message", "error", err, "ticket", ticketId)
+			return errors.New("failed to send message")
+		}
+	case ChatFrameTyping:
+		if err := s.events.Publish(ctx, store.Event{Type: eventChatTyping, TicketId: ticketId, Actor: user.Id}); err != nil {
+			s.logger.Error("failed to publish typing indicator", "error", err, "ticket", ticketId)
+		}
+	case ChatFrameRead:
+		if req.ReplyId == uuid.Nil {
+			return errors.New("reply_id is required")
+		}
+
+		changed, err := s.store.TicketReply.MarkRead(ctx, ticketId, user.Id, req.ReplyId)
+		if err != nil {
+			s.logger.Error("failed to store read receipt", "error", err, "ticket", ticketId)
+			return errors.New("failed to mark as read")
+		}
+
+		if changed {
+			if err := s.events.Publish(ctx, store.Event{
+				Type:     eventChatRead,
+				TicketId: ticketId,
+				Actor:    user.Id,
+				Details:  store.HistoryDetails{"reply_id": req.ReplyId},
+			}); err != nil {
+				s.logger.Error("failed to publish read receipt", "error", err, "ticket", ticketId)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q, expected %s, %s or %s", req.Type, ChatFrameMessage, ChatFrameTyping, ChatFrameRead)
+	}
+
+	return nil
+}
+
+// relayChatEvents writes the bus events of the ticket to the connection and keeps it
+// alive with pings, it closes the connection when it stops
+func (s *Server) relayChatEvents(ctx context.Context, cancel context.CancelFunc, conn *chatConn, events <-chan store.Event, ticketId uuid.UUID, user *store.User) {
+	defer cancel()
+	defer conn.conn.Close()
+
+	ping := time.NewTicker(chatPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.closing:
+			conn.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"), time.Now().Add(chatWriteTimeout))
+			return
+		case <-ping.C:
+			if err := conn.ping(); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if event.TicketId != ticketId {
+				continue
+			}
+
+			frame, ok := s.chatFrame(ctx, event, user)
+			if !ok {
+				continue
+			}
+
+			if err := conn.send(*frame); err != nil {
+				return
+			}
+
+			if frame.Type == ChatFrameClosed {
+				return
+			}
+		}
+	}
+}
+
+// chatFrame turns a bus event into the frame sent to the user, if there is one
+func (s *Server) chatFrame(ctx context.Context, event store.Event, user *store.User) (*ChatFrame, bool) {
+	actor := event.Actor
+
+	switch event.Type {
+	case store.EventTicketReplied:
+		replyId, err := uuid.Parse(fmt.Sprint(event.Details["reply_id"]))
+		if err != nil {
+			return nil, false
+		}
+
+		reply, err := s.store.TicketReply.ById(ctx, replyId)
+		if err != nil {
+			s.logger.Error("failed to get chat message", "error", err, "reply", replyId)
+			return nil, false
+		}
+
+		mentions, err := s.store.TicketReply.ReplyMentions(ctx, replyId)
+		if err != nil {
+			s.logger.Error("failed to get chat message mentions", "error", err, "reply", replyId)
+			return nil, false
+		}
+
+		return &ChatFrame{Type: ChatFrameMessage, UserId: &actor, Reply: newReplyResponse(reply), Mentions: mentions}, true
+	case store.EventTicketUpdated:
+		var frameType string
+		switch event.Action {
+		case store.HistoryReplyEdited:
+			frameType = ChatFrameEdited
+		case store.HistoryReplyDeleted:
+			frameType = ChatFrameDeleted
+		default:
+			return nil, false
+		}
+
+		replyId, err := uuid.Parse(fmt.Sprint(event.Details["reply_id"]))
+		if err != nil {
+			return nil, false
+		}
+
+		reply, err := s.store.TicketReply.ById(ctx, replyId)
+		if err != nil {
+			s.logger.Error("failed to get chat message", "error", err, "reply", replyId)
+			return nil, false
+		}
+
+		return &ChatFrame{Type: frameType, UserId: &actor, Reply: newReplyResponse(reply)}, true
+	case eventChatTyping:
+		if actor == user.Id {
+			return nil, false
+		}
+
+		return &ChatFrame{Type: ChatFrameTyping, UserId: &actor}, true
+	case eventChatRead:
+		replyId, err := uuid.Parse(fmt.Sprint(event.Details["reply_id"]))
+		if err != nil {
+			return nil, false
+		}
+
+		return &ChatFrame{Type: ChatFrameRead, UserId: &actor, ReplyId: &replyId}, true
+	case store.EventTicketClosed:
+		return &ChatFrame{Type: ChatFrameClosed, UserId: &actor}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type CustomFieldRequest struct {
+	Category string                `json:"category"`
+	Name     string                `json:"name"`
+	Label    string                `json:"label"`
+	Type     store.CustomFieldType `json:"type"`
+	Required bool                  `json:"required"`
+	Options  []string              `json:"options"`
+}
+
+func (req CustomFieldRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req CustomFieldRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Category == "" {
+		errs.Add("category", FieldRequired, "category is required")
+	}
+
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if req.Label == "" {
+		errs.Add("label", FieldRequired, "label is required")
+	}
+
+	if !req.Type.Valid() {
+		errs.Add("type", FieldInvalid, "type must be one of text, number, enum, date or user")
+	}
+
+	if req.Type == store.CustomFieldEnum && len(req.Options) == 0 {
+		errs.Add("options", FieldRequired, "options are required for enum fields")
+	}
+
+	if req.Type != store.CustomFieldEnum && len(req.Options) > 0 {
+		errs.Add("options", FieldInvalid, "options are only allowed for enum fields")
+	}
+
+	return errs
+}
+
+func (req CustomFieldRequest) params() store.CustomFieldParams {
+	return store.CustomFieldParams{
+		Category: req.Category,
+		Name:     req.Name,
+		Label:    req.Label,
+		Type:     req.Type,
+		Required: req.Required,
+		Options:  req.Options,
+	}
+}
+
+type UpdateCustomFieldRequest struct {
+	Id uuid.UUID `json:"id"`
+	CustomFieldRequest
+}
+
+func (req UpdateCustomFieldRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.CustomFieldRequest.validate()...).Err()
+}
+
+type DeleteCustomFieldRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteCustomFieldRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetCustomFieldsResponse struct {
+	Fields []store.CustomField `json:"fields"`
+}
+
+func (s *Server) getCustomFieldsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		var fields []store.CustomField
+		var err error
+
+		if category := r.URL.Query().Get("category"); category != "" {
+			fields, err = s.store.CustomField.ByCategory(r.Context(), category)
+		} else {
+			fields, err = s.store.CustomField.All(r.Context())
+		}
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetCustomFieldsResponse]](w, http.StatusOK, ApiResponse[GetCustomFieldsResponse]{
+			Data: &GetCustomFieldsResponse{
+				Fields: fields,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createCustomFieldHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[CustomFieldRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		field, err := s.store.CustomField.Create(r.Context(), req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.CustomField]](w, http.StatusCreated, ApiResponse[store.CustomField]{
+			Data: field,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateCustomFieldHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateCustomFieldRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		field, err := s.store.CustomField.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.CustomField]](w, http.StatusOK, ApiResponse[store.CustomField]{
+			Data: field,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteCustomFieldHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteCustomFieldRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.CustomField.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "custom field has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// customFieldErrors reports the problems with custom field values as field errors of the request
+func customFieldErrors(err error) error {
+	var fieldErrs store.CustomFieldErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	var errs ValidationErrors
+	for _, fieldErr := range fieldErrs {
+		errs.Add("custom_fields."+fieldErr.Name, fieldErr.Reason, "%s %s", fieldErr.Name, fieldErr.Message)
+	}
+	return errs
+}
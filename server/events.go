@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	// the history is read whenever the bus announces an event, polling only
+	// covers notifications that got lost
+	eventPollInterval = 10 * time.Second
+	eventHeartbeat    = 15 * time.Second
+	eventBatchSize    = 100
+	// how long a stream trusts the teams of its user before reading them again
+	eventTeamsTtl = time.Minute
+)
+
+// TicketEventData is the data of every event on the stream, Id is the history entry
+// and the position in the stream is the event id
+type TicketEventData struct {
+	Id        int64                `json:"id"`
+	TicketId  uuid.UUID            `json:"ticket_id"`
+	Action    string               `json:"action"`
+	Actor     *uuid.UUID           `json:"actor"`
+	Details   store.HistoryDetails `json:"details"`
+	CreatedAt time.Time            `json:"created_at"`
+}
+
+// lastEventId is where the client wants to resume from, EventSource sends it as the
+// Last-Event-ID header on reconnect and ?last_event_id= allows setting it on the first connect
+func lastEventId(r *http.Request) (int64, bool, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+
+	if value == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, errors.New("last event id must be a non negative integer")
+	}
+
+	return id, true, nil
+}
+
+// streamEventsHandler streams the ticket events the caller may see as server-sent events.
+// Events come from the ticket history in the database, so every instance serves the same
+// stream and the history position is the event id clients resume from. The event bus tells
+// the stream when there is something new to read.
+func (s *Server) streamEventsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return NewApiError(http.StatusInternalServerError, errors.New("streaming is not supported"))
+		}
+
+		lastId, resume, err := lastEventId(r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if !resume {
+			lastId, err = s.store.History.LatestPosition(r.Context())
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+
+		user := s.getUserFromContext(r.Context())
+		access := &streamAccess{user: user}
+		if err := s.loadStreamAccess(r.Context(), access); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+		notifications := s.events.Subscribe(r.Context())
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		poll := time.NewTicker(eventPollInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(eventHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+			case <-s.closing:
+				return nil
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return nil
+				}
+				flusher.Flush()
+			case event, ok := <-notifications:
+				if !ok {
+					return nil
+				}
+				// chat, due date reminders and other events that are not recorded in the
+				// history are not streamed, only what is recorded needs a read
+				if event.HistoryId == 0 && event.Type != store.EventResync {
+					continue
+				}
+				lastId, err = s.writeEvents(w, r, access, lastId)
+				if err != nil {
+					s.logger.Error("failed to stream ticket events", "error", err, "user", user.Id)
+					return nil
+				}
+				flusher.Flush()
+			case <-poll.C:
+				lastId, err = s.writeEvents(w, r, access, lastId)
+				if err != nil {
+					s.logger.Error("failed to stream ticket events", "error", err, "user", user.Id)
+					return nil
+				}
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+// streamAccess is what a stream needs to know of its user to decide which events they may
+// see, the teams are kept for eventTeamsTtl rather than read for every event
+type streamAccess struct {
+	user     *store.User
+	memberOf map[uuid.UUID]bool
+	loadedAt time.Time
+}
+
+func (s *Server) loadStreamAccess(ctx context.Context, access *streamAccess) error {
+	if time.Since(access.loadedAt) < eventTeamsTtl {
+		return nil
+	}
+
+	teams, err := s.store.Team.ByUser(ctx, access.user.Id)
+	if err != nil {
+		return err
+	}
+
+	access.memberOf = map[uuid.UUID]bool{}
+	for _, team := range teams {
+		access.memberOf[team.Id] = true
+	}
+	access.loadedAt = time.Now()
+
+	return nil
+}
+
+func (a *streamAccess) visible(creator, assignee, teamId uuid.UUID) bool {
+	visible, _ := ticketVisible(a.user, creator, assignee, teamId, func(teamId uuid.UUID) (bool, error) {
+		return a.memberOf[teamId], nil
+	})
+	return visible
+}
+
+// writeEvents writes the events after the position lastId that the user may see
+// and returns the new position
+func (s *Server) writeEvents(w http.ResponseWriter, r *http.Request, access *streamAccess, lastId int64) (int64, error) {
+	if err := s.loadStreamAccess(r.Context(), access); err != nil {
+		return lastId, err
+	}
+
+	for {
+		events, err := s.store.History.Since(r.Context(), lastId, eventBatchSize)
+		if err != nil {
+			return lastId, err
+		}
+
+		for _, event := range events {
+			lastId = event.Position
+
+			if !access.visible(event.Creator, event.Assignee, event.TeamId) {
+				continue
+			}
+
+			data := TicketEventData{
+				Id:        event.Id,
+				TicketId:  event.TicketId,
+				Action:    event.Action,
+				Details:   event.Details,
+				CreatedAt: event.CreatedAt,
+			}
+			if event.Actor != uuid.Nil {
+				data.Actor = &event.Actor
+			}
+
+			payload, err := json.Marshal(data)
+			if err != nil {
+				return lastId, err
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Position, store.EventType(event.Action), payload); err != nil {
+				return lastId, err
+			}
+		}
+
+		if len(events) < eventBatchSize {
+			return lastId, nil
+		}
+	}
+}
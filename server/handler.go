@@ -5,10 +5,16 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/markdown"
 	"github.com/tatucosmin/hotel-system/store"
 	"github.com/tatucosmin/hotel-system/workers"
 )
@@ -24,15 +30,16 @@ type ApiResponse[T any] struct {
 }
 
 func (req SignupRequest) Validate() error {
+	var errs ValidationErrors
 	if req.Email == "" {
-		return errors.New("email is required to sign up")
+		errs.Add("email", FieldRequired, "email is required to sign up")
 	}
 
 	if req.Password == "" {
-		return errors.New("password is required to sign up")
+		errs.Add("password", FieldRequired, "password is required to sign up")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 func (s *Server) signUpHandler() http.HandlerFunc {
@@ -50,7 +57,7 @@ func (s *Server) signUpHandler() http.HandlerFunc {
 		}
 
 		if existingUser != nil {
-			return NewApiError(http.StatusConflict, fmt.Errorf("email adress is already registered"))
+			return NewApiError(http.StatusConflict, fmt.Errorf("email adress is already registered")).WithCode(CodeEmailTaken)
 		}
 
 		_, err = s.store.User.CreateUser(r.Context(), req.Email, req.Password)
@@ -80,15 +87,16 @@ type SigninResponse struct {
 }
 
 func (req SigninRequest) Validate() error {
+	var errs ValidationErrors
 	if req.Email == "" {
-		return errors.New("email is required to sign in")
+		errs.Add("email", FieldRequired, "email is required to sign in")
 	}
 
 	if req.Password == "" {
-		return errors.New("password is required to sign in")
+		errs.Add("password", FieldRequired, "password is required to sign in")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 func (s *Server) signInHandler() http.HandlerFunc {
@@ -104,7 +112,7 @@ func (s *Server) signInHandler() http.HandlerFunc {
 		}
 
 		if err := user.ComparePasswordHash(req.Password); err != nil {
-			return NewApiError(http.StatusUnauthorized, err)
+			return NewApiError(http.StatusUnauthorized, err).WithCode(CodeInvalidCredentials)
 		}
 
 		tokens, err := s.jwtManager.GenerateTokens(user.Id)
@@ -147,11 +155,12 @@ type RefreshRequest struct {
 }
 
 func (req RefreshRequest) Validate() error {
+	var errs ValidationErrors
 	if req.RefreshToken == "" {
-		return errors.New("refresh_token is required")
+		errs.Add("refresh_token", FieldRequired, "refresh_token is required")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 type RefreshResponse struct {
@@ -168,31 +177,30 @@ func (s *Server) refreshTokenHandler() http.HandlerFunc {
 
 		nowRefreshToken, err := s.jwtManager.ParseToken(req.RefreshToken)
 		if err != nil {
-			return NewApiError(http.StatusUnauthorized, err)
+			return NewApiError(http.StatusUnauthorized, err).WithCode(CodeInvalidRefreshToken)
 		}
 
 		parsedUserId, err := nowRefreshToken.Claims.GetSubject()
 		if err != nil {
-			return NewApiError(http.StatusUnauthorized, err)
+			return NewApiError(http.StatusUnauthorized, err).WithCode(CodeInvalidRefreshToken)
 		}
 
 		userId, err := uuid.Parse(parsedUserId)
 		if err != nil {
-			return NewApiError(http.StatusUnauthorized, err)
+			return NewApiError(http.StatusUnauthorized, err).WithCode(CodeInvalidRefreshToken)
 		}
 
 		nowRefreshTokenRow, err := s.store.RefreshToken.ByPK(r.Context(), nowRefreshToken, userId)
 		if err != nil {
-			status := http.StatusInternalServerError
 			if errors.Is(err, sql.ErrNoRows) {
-				status = http.StatusUnauthorized
+				return NewApiError(http.StatusUnauthorized, err).WithCode(CodeInvalidRefreshToken)
 			}
 
-			return NewApiError(status, err)
+			return NewApiError(http.StatusInternalServerError, err)
 		}
 
 		if nowRefreshTokenRow.ExpiresAt.Before(time.Now()) {
-			return NewApiError(http.StatusUnauthorized, fmt.Errorf("refresh token has expired"))
+			return NewApiError(http.StatusUnauthorized, fmt.Errorf("refresh token has expired")).WithCode(CodeInvalidRefreshToken)
 		}
 
 		tokens, err := s.jwtManager.GenerateTokens(userId)
@@ -233,16 +241,70 @@ func (s *Server) getUserFromContext(ctx context.Context) *store.User {
 	return user
 }
 
+// canAccessTicket reports whether the user may read the ticket and its activity.
+// Admins see every ticket, staff see the tickets assigned to them, the tickets of their
+// teams and the ones no team picked up yet, everybody sees the tickets they created.
+func (s *Server) canAccessTicket(ctx context.Context, user *store.User, ticket *store.Ticket) (bool, error) {
+	return ticketVisible(user, ticket.Creator, ticket.CurrentAssignee, ticket.TeamId, func(teamId uuid.UUID) (bool, error) {
+		member, _, err := s.store.Team.Membership(ctx, teamId, user.Id)
+		return member, err
+	})
+}
+
+// ticketVisible decides who sees a ticket: admins, its creator, its assignee and the staff
+// of its team, tickets without a team are visible to all staff. isMember is only asked
+// about the ticket's team when nothing else decides. Queries of the store follow the same
+// rule in sql.
+func ticketVisible(user *store.User, creator, assignee, teamId uuid.UUID, isMember func(teamId uuid.UUID) (bool, error)) (bool, error) {
+	if creator == user.Id || user.HasRole(store.RoleAdmin) {
+		return true, nil
+	}
+
+	if !user.HasRole(store.RoleStaff) {
+		return false, nil
+	}
+
+	if assignee == user.Id || teamId == uuid.Nil {
+		return true, nil
+	}
+
+	return isMember(teamId)
+}
+
+// ticketForUser fetches the ticket and checks the user may access it
+func (s *Server) ticketForUser(ctx context.Context, user *store.User, ticketId uuid.UUID) (*store.Ticket, error) {
+	ticket, err := s.store.Ticket.ById(ctx, ticketId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		return nil, NewApiError(status, err)
+	}
+
+	allowed, err := s.canAccessTicket(ctx, user, ticket)
+	if err != nil {
+		return nil, NewApiError(http.StatusInternalServerError, err)
+	}
+
+	if !allowed {
+		return nil, NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to access this ticket"))
+	}
+
+	return ticket, nil
+}
+
 type TicketRequest struct {
 	Id uuid.UUID `json:"id"`
 }
 
 func (req TicketRequest) Validate() error {
+	var errs ValidationErrors
 	if req.Id == uuid.Nil {
-		return errors.New("id is required")
+		errs.Add("id", FieldRequired, "id is required")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 func (s *Server) getTicketHandler() http.HandlerFunc {
@@ -255,21 +317,14 @@ func (s *Server) getTicketHandler() http.HandlerFunc {
 			return NewApiError(http.StatusBadRequest, err)
 		}
 
-		ticket, err := s.store.Ticket.ById(r.Context(), req.Id)
+		ticket, err := s.ticketForUser(r.Context(), user, req.Id)
 		if err != nil {
-			status := http.StatusInternalServerError
-			if errors.Is(err, sql.ErrNoRows) {
-				status = http.StatusNotFound
-			}
-			return NewApiError(status, err)
+			return err
 		}
 
-		if ticket.Creator != user.Id {
-			return NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to access this ticket"))
-		}
-
-		if err := encode[ApiResponse[store.Ticket]](w, http.StatusOK, ApiResponse[store.Ticket]{
-			Data: ticket,
+		response := newTicketResponse(*ticket, time.Now())
+		if err := encode[ApiResponse[TicketResponse]](w, http.StatusOK, ApiResponse[TicketResponse]{
+			Data: &response,
 		}); err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
@@ -278,20 +333,98 @@ func (s *Server) getTicketHandler() http.HandlerFunc {
 	})
 }
 
+// TicketResponse is a ticket as it is read back, with its description rendered
+// from markdown and flagged when it is past its due date
+type TicketResponse struct {
+	store.Ticket
+	DescriptionHtml string `json:"description_html"`
+	Overdue         bool   `json:"overdue"`
+}
+
+func newTicketResponse(ticket store.Ticket, now time.Time) TicketResponse {
+	return TicketResponse{
+		Ticket:          ticket,
+		DescriptionHtml: markdown.Render(ticket.Description),
+		Overdue:         ticket.Overdue(now),
+	}
+}
+
+func newTicketResponses(tickets []store.Ticket) []TicketResponse {
+	now := time.Now()
+	responses := make([]TicketResponse, 0, len(tickets))
+	for _, ticket := range tickets {
+		responses = append(responses, newTicketResponse(ticket, now))
+	}
+	return responses
+}
+
+// ticketFilter reads the listing filters shared by the ticket listings from the query:
+// ?category=, ?field.<name>=, ?due_before= and ?due_after= (RFC 3339), ?overdue=true and ?sort=
+func ticketFilter(query url.Values) (store.TicketFilter, error) {
+	filter := store.TicketFilter{
+		Category:     query.Get("category"),
+		CustomFields: map[string]string{},
+		Overdue:      query.Get("overdue") == "true",
+		Sort:         query.Get("sort"),
+	}
+
+	// custom fields are filtered with ?field.<name>=<value>
+	for key, values := range query {
+		if name, ok := strings.CutPrefix(key, "field."); ok && len(values) > 0 {
+			filter.CustomFields[name] = values[0]
+		}
+	}
+
+	var errs ValidationErrors
+	var err error
+	if filter.DueBefore, err = queryTime(query, "due_before"); err != nil {
+		errs.Add("due_before", FieldInvalid, "%v", err)
+	}
+
+	if filter.DueAfter, err = queryTime(query, "due_after"); err != nil {
+		errs.Add("due_after", FieldInvalid, "%v", err)
+	}
+
+	if _, ok := store.TicketSorts[filter.Sort]; filter.Sort != "" && !ok {
+		errs.Add("sort", FieldInvalid, "unknown sort %q, available sorts are %v", filter.Sort, slices.Sorted(maps.Keys(store.TicketSorts)))
+	}
+
+	return filter, errs.Err()
+}
+
+// queryTime reads an optional RFC 3339 time from the query
+func queryTime(query url.Values, key string) (*time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 time: %w", key, err)
+	}
+	return &t, nil
+}
+
 type GetAllTicketsResponse struct {
-	Tickets []store.Ticket `json:"tickets"`
+	Tickets []TicketResponse `json:"tickets"`
 }
 
 func (s *Server) getAllTicketsHandler() http.HandlerFunc {
 	return handler(func(w http.ResponseWriter, r *http.Request) error {
-		tickets, err := s.store.Ticket.All(r.Context())
+		filter, err := ticketFilter(r.URL.Query())
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		tickets, err := s.store.Ticket.All(r.Context(), filter)
 		if err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
 
 		if err := encode[ApiResponse[GetAllTicketsResponse]](w, http.StatusOK, ApiResponse[GetAllTicketsResponse]{
 			Data: &GetAllTicketsResponse{
-				Tickets: tickets,
+				Tickets: newTicketResponses(tickets),
 			},
 		}); err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
@@ -301,25 +434,69 @@ func (s *Server) getAllTicketsHandler() http.HandlerFunc {
 }
 
 type CreateTicketRequest struct {
-	Title       string               `json:"title"`
-	Description string               `json:"description"`
-	Priority    store.TicketPriority `json:"priority"`
+	Title        string                `json:"title"`
+	Description  string                `json:"description"`
+	Category     string                `json:"category"`
+	Priority     *store.TicketPriority `json:"priority"`
+	TemplateId   uuid.UUID             `json:"template_id"`
+	Variables    map[string]string     `json:"variables"`
+	CustomFields store.CustomFields    `json:"custom_fields"`
+	DueAt        *time.Time            `json:"due_at"`
 }
 
 func (req CreateTicketRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Priority != nil && !req.Priority.WithinBounds() {
+		errs.Add("priority", FieldInvalid, "priority is invalid")
+	}
+
+	// title, description and priority come from the template when one is used
+	if req.TemplateId != uuid.Nil {
+		return errs.Err()
+	}
+
 	if req.Title == "" {
-		return errors.New("title is required")
+		errs.Add("title", FieldRequired, "title is required")
 	}
 
 	if req.Description == "" {
-		return errors.New("description is required")
+		errs.Add("description", FieldRequired, "description is required")
 	}
 
-	if !req.Priority.WithinBounds() {
-		return errors.New("priority is required")
+	if req.Priority == nil {
+		errs.Add("priority", FieldRequired, "priority is required")
+	}
+
+	return errs.Err()
+}
+
+// validateStore checks that the template exists and that the custom fields fit the category
+// the ticket ends up in. It returns the template, if one is used, so it is only loaded once.
+func (req CreateTicketRequest) validateStore(ctx context.Context, st *store.Store) (*store.TicketTemplate, error) {
+	var template *store.TicketTemplate
+	category := req.Category
+	if req.TemplateId != uuid.Nil {
+		var err error
+		template, err = st.Template.ById(ctx, req.TemplateId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				var errs ValidationErrors
+				errs.Add("template_id", FieldNotFound, "template %v does not exist", req.TemplateId)
+				return nil, errs
+			}
+			return nil, err
+		}
+
+		if category == "" {
+			category = template.Category
+		}
+	}
+
+	if err := customFieldErrors(st.CustomField.Validate(ctx, category, req.CustomFields)); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return template, nil
 }
 
 type CreateTicketResponse struct {
@@ -331,21 +508,55 @@ func (s *Server) createTicketHandler() http.HandlerFunc {
 	return handler(func(w http.ResponseWriter, r *http.Request) error {
 
 		req, err := decode[CreateTicketRequest](r)
-
 		if err != nil {
 			return NewApiError(http.StatusBadRequest, err)
 		}
 
+		template, err := req.validateStore(r.Context(), s.store)
+		if err != nil {
+			return storeValidationError(err)
+		}
+
 		user := s.getUserFromContext(r.Context())
 
-		ticket, err := s.store.Ticket.Create(r.Context(), req.Title, req.Description, user.Id, req.Priority)
+		params := store.CreateTicketParams{
+			Title:        req.Title,
+			Description:  req.Description,
+			Category:     req.Category,
+			Creator:      user.Id,
+			CustomFields: req.CustomFields,
+			DueAt:        req.DueAt,
+		}
+
+		if template != nil {
+			params.Title, params.Description, err = template.Render(req.Variables)
+			if err != nil {
+				return NewApiError(http.StatusBadRequest, err)
+			}
+
+			if utf8.RuneCountInString(params.Title) > 100 {
+				return NewApiError(http.StatusBadRequest, errors.New("rendered title is longer than 100 characters"))
+			}
+
+			params.Priority = template.Priority
+			if params.Category == "" {
+				params.Category = template.Category
+			}
+		}
+
+		if req.Priority != nil {
+			params.Priority = *req.Priority
+		}
+
+		ticket, err := s.store.Ticket.Create(r.Context(), params)
 
 		if err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
 
-		if err := encode[ApiResponse[store.Ticket]](w, http.StatusCreated, ApiResponse[store.Ticket]{
-			Data: ticket,
+		response := newTicketResponse(*ticket, time.Now())
+		if err := encode[ApiResponse[TicketResponse]](w, http.StatusCreated, ApiResponse[TicketResponse]{
+			Data: &response,
 		}); err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
@@ -355,56 +566,235 @@ func (s *Server) createTicketHandler() http.HandlerFunc {
 }
 
 type UpdateTicketRequest struct {
-	Id       uuid.UUID            `json:"id"`
-	Priority store.TicketPriority `json:"priority"`
-	Status   store.TicketStatus   `json:"status"`
+	Id           uuid.UUID            `json:"id"`
+	Priority     store.TicketPriority `json:"priority"`
+	Status       store.TicketStatus   `json:"status"`
+	CustomFields store.CustomFields   `json:"custom_fields"`
+	// DueAt moves the due date when it is set, ClearDueAt removes it
+	DueAt      *time.Time `json:"due_at"`
+	ClearDueAt bool       `json:"clear_due_at"`
 }
 
 func (req UpdateTicketRequest) Validate() error {
+	var errs ValidationErrors
 	if req.Id == uuid.Nil {
-		return errors.New("id is required")
+		errs.Add("id", FieldRequired, "id is required")
 	}
 
 	if !req.Priority.WithinBounds() {
-		return errors.New("priority is required")
+		errs.Add("priority", FieldRequired, "priority is required")
 	}
 
 	if !req.Status.WithinBounds() {
-		return errors.New("status is required")
+		errs.Add("status", FieldRequired, "status is required")
 	}
 
-	return nil
+	if req.DueAt != nil && req.ClearDueAt {
+		errs.Add("clear_due_at", FieldConflict, "due_at and clear_due_at can't be used together")
+	}
+
+	return errs.Err()
+}
+
+func (req UpdateTicketRequest) ValidateStore(ctx context.Context, st *store.Store) error {
+	if req.CustomFields == nil {
+		return nil
+	}
+
+	ticket, err := st.Ticket.ById(ctx, req.Id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			var errs ValidationErrors
+			errs.Add("id", FieldNotFound, "ticket %v does not exist", req.Id)
+			return errs
+		}
+		return err
+	}
+
+	return customFieldErrors(st.CustomField.Validate(ctx, ticket.Category, req.CustomFields))
 }
 
 func (s *Server) updateTicketHandler() http.HandlerFunc {
 	return handler(func(w http.ResponseWriter, r *http.Request) error {
 
-		req, err := decode[UpdateTicketRequest](r)
+		req, err := decodeWithStore[UpdateTicketRequest](r, s.store)
 
 		if err != nil {
-			return NewApiError(http.StatusBadRequest, err)
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		ticket, err := s.ticketForUser(r.Context(), user, req.Id)
+		if err != nil {
+			return err
 		}
 
 		if req.Status == store.TicketStatusClosed {
-			err = workers.SaveTicketToS3(r.Context(), req.Id, s.store.TicketReply, s.Config)
+			err = workers.SaveTicketToS3(r.Context(), ticket, s.store.TicketReply, s.store.WorkLog, s.store.Attachment, s.Config)
 			if err != nil {
 				return NewApiError(http.StatusInternalServerError, err)
 			}
 
-			err = s.store.Ticket.Delete(r.Context(), req.Id)
+			err = s.store.Ticket.Close(r.Context(), req.Id, user.Id)
 			if err != nil {
 				return NewApiError(http.StatusInternalServerError, err)
 			}
+		} else {
+			err = s.store.Ticket.Update(r.Context(), req.Id, store.UpdateTicketParams{
+				Actor:        user.Id,
+				Priority:     req.Priority,
+				Status:       req.Status,
+				CustomFields: req.CustomFields,
+				DueAt:        req.DueAt,
+				ClearDueAt:   req.ClearDueAt,
+			})
+
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "ticket has been updated",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
 		}
 
-		err = s.store.Ticket.Update(r.Context(), req.Id, req.Priority, req.Status)
+		return nil
+	})
+}
+
+type GetTicketHistoryResponse struct {
+	History []store.TicketHistory `json:"history"`
+}
 
+func (s *Server) getTicketHistoryHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		history, err := s.store.History.ByTicketId(r.Context(), ticketId)
 		if err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
 
-		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
-			Message: "ticket has been updated",
+		if err := encode[ApiResponse[GetTicketHistoryResponse]](w, http.StatusOK, ApiResponse[GetTicketHistoryResponse]{
+			Data: &GetTicketHistoryResponse{
+				History: history,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+type AssignTicketRequest struct {
+	Id         uuid.UUID `json:"id"`
+	AssigneeId uuid.UUID `json:"assignee_id"`
+	// TeamId moves the ticket to another team when it is set, ClearTeam takes it off its team
+	TeamId    uuid.UUID `json:"team_id"`
+	ClearTeam bool      `json:"clear_team"`
+}
+
+func (req AssignTicketRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	if req.TeamId != uuid.Nil && req.ClearTeam {
+		errs.Add("clear_team", FieldConflict, "team_id and clear_team can't be used together")
+	}
+
+	return errs.Err()
+}
+
+func (req AssignTicketRequest) ValidateStore(ctx context.Context, st *store.Store) error {
+	var errs ValidationErrors
+	staff := false
+	if req.AssigneeId != uuid.Nil {
+		assignee, err := st.User.ById(ctx, req.AssigneeId)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		switch {
+		case assignee == nil:
+			errs.Add("assignee_id", FieldNotFound, "assignee %v does not exist", req.AssigneeId)
+		case !assignee.HasRole(store.RoleStaff | store.RoleAdmin):
+			errs.Add("assignee_id", FieldInvalid, "tickets can only be assigned to staff")
+		default:
+			staff = true
+		}
+	}
+
+	teamId := req.TeamId
+	if teamId == uuid.Nil && !req.ClearTeam {
+		// the ticket keeps its team, the assignee has to be on it
+		ticket, err := st.Ticket.ById(ctx, req.Id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if ticket != nil {
+			teamId = ticket.TeamId
+		}
+	} else if teamId != uuid.Nil {
+		if _, err := st.Team.ById(ctx, teamId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				errs.Add("team_id", FieldNotFound, "team %v does not exist", teamId)
+				return errs
+			}
+			return err
+		}
+	}
+
+	if teamId == uuid.Nil {
+		return errs.Err()
+	}
+
+	if staff {
+		member, _, err := st.Team.Membership(ctx, teamId, req.AssigneeId)
+		if err != nil {
+			return err
+		}
+
+		if !member {
+			errs.Add("assignee_id", FieldInvalid, "assignee is not a member of the team")
+		}
+	}
+
+	return errs.Err()
+}
+
+func (s *Server) assignTicketHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decodeWithStore[AssignTicketRequest](r, s.store)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, req.Id); err != nil {
+			return err
+		}
+
+		ticket, err := s.store.Ticket.Assign(r.Context(), req.Id, user.Id, req.AssigneeId, req.TeamId, req.ClearTeam)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		response := newTicketResponse(*ticket, time.Now())
+		if err := encode[ApiResponse[TicketResponse]](w, http.StatusOK, ApiResponse[TicketResponse]{
+			Data: &response,
 		}); err != nil {
 			return NewApiError(http.StatusInternalServerError, err)
 		}
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 type ApiError struct {
 	status int
+	code   string
 	err    error
 }
 
@@ -19,30 +21,35 @@ func (e *ApiError) Error() string {
 	return e.err.Error()
 }
 
+func (e *ApiError) Unwrap() error {
+	return e.err
+}
+
+// NewApiError answers a request with the status, the error code comes from the error
+// when it is a known one and from the status otherwise
 func NewApiError(status int, err error) *ApiError {
-	return &ApiError{status, err}
+	code := statusCode(status)
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			code = known.code
+			break
+		}
+	}
+	return &ApiError{status, code, err}
+}
+
+// WithCode sets an error code that tells the error apart from others with the same status
+func (e *ApiError) WithCode(code string) *ApiError {
+	e.code = code
+	return e
 }
 
 func handler(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			status := http.StatusInternalServerError
-			msg := http.StatusText(status)
-			if e, ok := err.(*ApiError); ok {
-				status = e.status
-				msg = http.StatusText(e.status)
-				if e.status == http.StatusBadRequest || e.status == http.StatusConflict || e.status == http.StatusUnauthorized {
-					msg = e.err.Error()
-				}
-			}
-
-			slog.Error("error while executing handler", "error", err, "status", status, "message", msg)
-			w.WriteHeader(status)
-			if err := json.NewEncoder(w).Encode(ApiResponse[struct{}]{
-				Message: msg,
-			}); err != nil {
-				slog.Error("error encoding response", "error", err, "status", status, "message", msg)
-			}
+			problem := problemFor(r, err)
+			slog.Error("error while executing handler", "error", err, "status", problem.Status, "code", problem.Code)
+			writeProblem(w, problem)
 		}
 	}
 }
@@ -56,6 +63,7 @@ func encode[T any](w http.ResponseWriter, status int, v T) error {
 	return nil
 }
 
+// Validator is implemented by requests, Validate returns ValidationErrors with every invalid field
 type Validator interface {
 	Validate() error
 }
@@ -63,7 +71,13 @@ type Validator interface {
 func decode[T Validator](r *http.Request) (T, error) {
 	var v T
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		return v, fmt.Errorf("decode json: %w", err)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			var errs ValidationErrors
+			errs.Add(typeErr.Field, FieldInvalid, "%s can't be a json %s", typeErr.Field, typeErr.Value)
+			return v, errs
+		}
+		return v, fmt.Errorf("%w: %w", errMalformedBody, err)
 	}
 
 	if err := v.Validate(); err != nil {
@@ -72,6 +86,39 @@ func decode[T Validator](r *http.Request) (T, error) {
 	return v, nil
 }
 
+// StoreValidator is implemented by requests that can only be fully validated
+// against data in the store, such as custom fields whose schema depends on the category
+type StoreValidator interface {
+	ValidateStore(ctx context.Context, st *store.Store) error
+}
+
+// decodeWithStore decodes and validates the request like decode, then runs the
+// store validation when the request supports it. The returned error is an *ApiError.
+func decodeWithStore[T Validator](r *http.Request, st *store.Store) (T, error) {
+	v, err := decode[T](r)
+	if err != nil {
+		return v, NewApiError(http.StatusBadRequest, err)
+	}
+
+	if sv, ok := any(v).(StoreValidator); ok {
+		if err := sv.ValidateStore(r.Context(), st); err != nil {
+			return v, storeValidationError(err)
+		}
+	}
+
+	return v, nil
+}
+
+// storeValidationError turns the error of a store validation into a 400 when the request
+// is invalid and a 500 when the store failed
+func storeValidationError(err error) *ApiError {
+	status := http.StatusInternalServerError
+	if errors.Is(err, store.ErrValidation) {
+		status = http.StatusBadRequest
+	}
+	return NewApiError(status, err)
+}
+
 func GetUserFromContext(ctx context.Context) (*store.User, error) {
 	user, ok := ctx.Value(ContextUserKey{}).(*store.User)
 	if !ok {
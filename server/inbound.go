@@ -0,0 +1,286 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	netmail "net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/mail"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	inboundEmailMaxSize = 25 << 20
+	maxTicketTitle      = 100
+)
+
+type InboundEmailResponse struct {
+	TicketId    uuid.UUID  `json:"ticket_id"`
+	ReplyId     *uuid.UUID `json:"reply_id,omitempty"`
+	Attachments int        `json:"attachments"`
+}
+
+// errUnknownSender is returned for senders without an account unless INBOUND_EMAIL_CREATE_USERS is set
+var errUnknownSender = errors.New("sender has no account")
+
+// inboundEmailHandler takes a raw email, as piped from an mta, and turns it into a reply to the
+// ticket it references or into a new ticket. The mta authenticates with INBOUND_EMAIL_SECRET as
+// a bearer token. The From header can be forged, so replies are only taken from the user whose
+// signed reply token they were sent to. New tickets are opened for the sender's account, senders
+// without one only get an account with INBOUND_EMAIL_CREATE_USERS. An email that was already taken
+// in, as when the mta retries a delivery, gets the first result back.
+func (s *Server) inboundEmailHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		if s.Config.InboundEmailSecret == "" {
+			return NewApiError(http.StatusNotFound, errors.New("inbound email is not configured"))
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.Config.InboundEmailSecret)) != 1 {
+			return NewApiError(http.StatusUnauthorized, errors.New("invalid inbound email secret"))
+		}
+
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, inboundEmailMaxSize))
+		if err != nil {
+			return NewApiError(http.StatusRequestEntityTooLarge, err)
+		}
+
+		msg, err := mail.ParseInbound(bytes.NewReader(raw))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		// answering auto replies or our own notifications would loop
+		from, err := netmail.ParseAddress(s.Config.MailFrom)
+		if msg.AutoSubmitted || (err == nil && strings.EqualFold(msg.From, from.Address)) {
+			if err := encode[ApiResponse[struct{}]](w, http.StatusAccepted, ApiResponse[struct{}]{
+				Message: "automatic message has been ignored",
+			}); err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+			return nil
+		}
+
+		messageId := strings.TrimSpace(msg.MessageId)
+		if messageId != "" {
+			received, err := s.store.InboundEmail.ByMessageId(r.Context(), messageId)
+			if err == nil {
+				res := InboundEmailResponse{TicketId: received.TicketId, Attachments: received.Attachments}
+				if received.ReplyId.Valid {
+					res.ReplyId = &received.ReplyId.UUID
+				}
+
+				if err := encode[ApiResponse[InboundEmailResponse]](w, http.StatusOK, ApiResponse[InboundEmailResponse]{
+					Data:    &res,
+					Message: "message has already been received",
+				}); err != nil {
+					return NewApiError(http.StatusInternalServerError, err)
+				}
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+
+		ticketId, isReply := msg.TicketReference()
+		user, err := s.inboundSender(r.Context(), msg, ticketId, isReply)
+		if err != nil {
+			if errors.Is(err, errUnknownSender) {
+				return NewApiError(http.StatusForbidden, fmt.Errorf("%s has no account", msg.From))
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				return NewApiError(http.StatusForbidden, errors.New("reply token is missing or invalid"))
+			}
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		body := mail.StripQuoted(msg.Text)
+		if body == "" {
+			if len(msg.Attachments) == 0 {
+				return NewApiError(http.StatusBadRequest, errors.New("message has no content"))
+			}
+
+			filenames := make([]string, len(msg.Attachments))
+			for i, attachment := range msg.Attachments {
+				filenames[i] = attachment.Filename
+			}
+			body = "Attached: " + strings.Join(filenames, ", ")
+		}
+
+		res := InboundEmailResponse{}
+		var replyId uuid.UUID
+
+		var ticket *store.Ticket
+		if isReply {
+			ticket, err = s.referencedTicket(r.Context(), ticketId)
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+
+		if ticket != nil {
+			allowed, err := s.canAccessTicket(r.Context(), user, ticket)
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+
+			if !allowed {
+				return NewApiError(http.StatusForbidden, fmt.Errorf("%s is not allowed to reply to ticket %v", user.Email, ticket.Id))
+			}
+
+			reply, err := s.store.TicketReply.Create(r.Context(), ticket.Id, user.Id, body)
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+
+			replyId = reply.Id
+			res.ReplyId = &reply.Id
+		} else {
+			ticket, err = s.store.Ticket.Create(r.Context(), store.CreateTicketParams{
+				Title:       inboundTicketTitle(msg, body),
+				Description: body,
+				Creator:     user.Id,
+				Priority:    store.TicketPriorityMedium,
+			})
+			if err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+		res.TicketId = ticket.Id
+
+		for _, attachment := range msg.Attachments {
+			if err := s.saveAttachment(r.Context(), ticket.Id, replyId, user.Id, attachment); err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+			res.Attachments++
+		}
+
+		if messageId != "" {
+			if err := s.store.InboundEmail.Record(r.Context(), store.InboundEmail{
+				MessageId:   messageId,
+				TicketId:    res.TicketId,
+				ReplyId:     uuid.NullUUID{UUID: replyId, Valid: replyId != uuid.Nil},
+				Attachments: res.Attachments,
+			}); err != nil {
+				return NewApiError(http.StatusInternalServerError, err)
+			}
+		}
+
+		if err := encode[ApiResponse[InboundEmailResponse]](w, http.StatusCreated, ApiResponse[InboundEmailResponse]{
+			Data: &res,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// inboundSender is the user the message is from. Replies to a ticket must carry a reply token
+// signed for it and are from its user, sql.ErrNoRows is returned when there is none. Other
+// messages are from the user the reply token of an email that was not about a ticket was signed
+// for, or else from the user with the From address. An account with an unguessable password is
+// created for addresses that have none when INBOUND_EMAIL_CREATE_USERS is set.
+func (s *Server) inboundSender(ctx context.Context, msg *mail.InboundMessage, ticketId uuid.UUID, isReply bool) (*store.User, error) {
+	if !isReply {
+		ticketId = uuid.Nil
+	}
+
+	if userId, ok := msg.ReplyUser(s.Config.InboundEmailSecret, ticketId); ok {
+		return s.store.User.ById(ctx, userId)
+	}
+	if isReply {
+		return nil, sql.ErrNoRows
+	}
+
+	user, err := s.store.User.ByEmail(ctx, msg.From)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if !s.Config.InboundCreateUsers {
+		return nil, errUnknownSender
+	}
+
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	return s.store.User.CreateUser(ctx, msg.From, hex.EncodeToString(password))
+}
+
+// referencedTicket is the ticket the message replies to, nil when it is gone
+func (s *Server) referencedTicket(ctx context.Context, ticketId uuid.UUID) (*store.Ticket, error) {
+	ticket, err := s.store.Ticket.ById(ctx, ticketId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return ticket, nil
+}
+
+// saveAttachment uploads the attachment to s3 and records it for the ticket or reply
+func (s *Server) saveAttachment(ctx context.Context, ticketId, replyId, uploader uuid.UUID, attachment mail.Attachment) error {
+	key := store.NewAttachmentKey(ticketId)
+
+	if _, err := s.Config.S3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Config.S3Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(attachment.Data),
+		ContentType: aws.String(attachment.ContentType),
+	}); err != nil {
+		return fmt.Errorf("failed to upload attachment to s3: %w", err)
+	}
+
+	_, err := s.store.Attachment.Create(ctx, store.CreateAttachmentParams{
+		TicketId:    ticketId,
+		ReplyId:     replyId,
+		Uploader:    uploader,
+		Filename:    truncate(attachment.Filename, 255),
+		ContentType: truncate(attachment.ContentType, 255),
+		Size:        int64(len(attachment.Data)),
+		S3Key:       key,
+	})
+
+	return err
+}
+
+func inboundTicketTitle(msg *mail.InboundMessage, body string) string {
+	title := msg.TicketTitle()
+	if title == "" {
+		title, _, _ = strings.Cut(body, "\n")
+	}
+	if title == "" {
+		title = "Email from " + msg.From
+	}
+	return truncate(strings.TrimSpace(title), maxTicketTitle)
+}
+
+// truncate cuts s to at most n characters
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
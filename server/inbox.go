@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	inboxDefaultLimit = 20
+	inboxMaxLimit     = 100
+)
+
+type GetInboxResponse struct {
+	Notifications []store.InboxNotification `json:"notifications"`
+	// NextCursor fetches the next page as ?cursor=, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type UnreadCountResponse struct {
+	Unread int `json:"unread"`
+}
+
+type MarkReadRequest struct {
+	Ids []uuid.UUID `json:"ids"`
+}
+
+func (req MarkReadRequest) Validate() error {
+	var errs ValidationErrors
+	if len(req.Ids) == 0 {
+		errs.Add("ids", FieldRequired, "ids is required")
+	}
+
+	if len(req.Ids) > inboxMaxLimit {
+		errs.Add("ids", FieldInvalid, "at most %d ids can be marked at once", inboxMaxLimit)
+	}
+
+	return errs.Err()
+}
+
+type MarkReadResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+func parseInboxQuery(r *http.Request) (*store.InboxQuery, error) {
+	q := &store.InboxQuery{
+		Limit:      inboxDefaultLimit,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+	}
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > inboxMaxLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", inboxMaxLimit)
+		}
+		q.Limit = limit
+	}
+
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		after, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		q.After = after
+	}
+
+	return q, nil
+}
+
+// getInboxHandler lists the in-app notifications of the caller, newest first, leaving
+// out the ones about tickets they can no longer access
+func (s *Server) getInboxHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		q, err := parseInboxQuery(r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		notifications, err := s.store.Notification.Inbox(r.Context(), user, *q)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		res := GetInboxResponse{Notifications: notifications}
+		if len(notifications) == q.Limit {
+			res.NextCursor = notifications[len(notifications)-1].Id.String()
+		}
+
+		if err := encode[ApiResponse[GetInboxResponse]](w, http.StatusOK, ApiResponse[GetInboxResponse]{
+			Data: &res,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getUnreadCountHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		unread, err := s.store.Notification.UnreadCount(r.Context(), user)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[UnreadCountResponse]](w, http.StatusOK, ApiResponse[UnreadCountResponse]{
+			Data: &UnreadCountResponse{
+				Unread: unread,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) markReadHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[MarkReadRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		marked, err := s.store.Notification.MarkRead(r.Context(), user, req.Ids)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[MarkReadResponse]](w, http.StatusOK, ApiResponse[MarkReadResponse]{
+			Data: &MarkReadResponse{
+				Marked: marked,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) markAllReadHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		marked, err := s.store.Notification.MarkAllRead(r.Context(), user)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[MarkReadResponse]](w, http.StatusOK, ApiResponse[MarkReadResponse]{
+			Data: &MarkReadResponse{
+				Marked: marked,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type MacroRequest struct {
+	Name        string                `json:"name"`
+	Shared      bool                  `json:"shared"`
+	Reply       string                `json:"reply"`
+	SetStatus   *store.TicketStatus   `json:"set_status"`
+	SetPriority *store.TicketPriority `json:"set_priority"`
+	AddTags     []string              `json:"add_tags"`
+}
+
+func (req MacroRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req MacroRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if req.Reply == "" && req.SetStatus == nil && req.SetPriority == nil && len(req.AddTags) == 0 {
+		errs.Add("reply", FieldRequired, "a macro needs a reply or at least one field change")
+	}
+
+	if req.SetStatus != nil && !req.SetStatus.WithinBounds() {
+		errs.Add("set_status", FieldInvalid, "set_status is invalid")
+	}
+
+	// closing archives and removes the ticket, which is not something a one-click action should do
+	if req.SetStatus != nil && *req.SetStatus == store.TicketStatusClosed {
+		errs.Add("set_status", FieldInvalid, "macros cannot close tickets")
+	}
+
+	if req.SetPriority != nil && !req.SetPriority.WithinBounds() {
+		errs.Add("set_priority", FieldInvalid, "set_priority is invalid")
+	}
+
+	if slices.Contains(req.AddTags, "") {
+		errs.Add("add_tags", FieldInvalid, "tags cannot be empty")
+	}
+
+	for _, placeholder := range store.TemplatePlaceholders(req.Reply) {
+		if !slices.Contains(store.MacroVariables, placeholder) {
+			errs.Add("reply", FieldUnknown, "unknown variable %q, available variables are %v", placeholder, store.MacroVariables)
+		}
+	}
+
+	return errs
+}
+
+func (req MacroRequest) params() store.MacroParams {
+	return store.MacroParams{
+		Name:        req.Name,
+		Shared:      req.Shared,
+		Reply:       req.Reply,
+		SetStatus:   req.SetStatus,
+		SetPriority: req.SetPriority,
+		AddTags:     req.AddTags,
+	}
+}
+
+type UpdateMacroRequest struct {
+	Id uuid.UUID `json:"id"`
+	MacroRequest
+}
+
+func (req UpdateMacroRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.MacroRequest.validate()...).Err()
+}
+
+type DeleteMacroRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteMacroRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type ApplyMacroRequest struct {
+	MacroId  uuid.UUID `json:"macro_id"`
+	TicketId uuid.UUID `json:"ticket_id"`
+}
+
+func (req ApplyMacroRequest) Validate() error {
+	var errs ValidationErrors
+	if req.MacroId == uuid.Nil {
+		errs.Add("macro_id", FieldRequired, "macro_id is required")
+	}
+
+	if req.TicketId == uuid.Nil {
+		errs.Add("ticket_id", FieldRequired, "ticket_id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetMacrosResponse struct {
+	Macros []store.Macro `json:"macros"`
+}
+
+type ApplyMacroResponse struct {
+	Ticket TicketResponse `json:"ticket"`
+	Reply  *ReplyResponse `json:"reply,omitempty"`
+}
+
+// canManageMacro reports whether the user may edit or delete the macro,
+// shared macros belong to every admin while personal ones only to their owner
+func canManageMacro(user *store.User, macro *store.Macro) bool {
+	if macro.Owner == user.Id {
+		return true
+	}
+	return macro.Shared && user.HasRole(store.RoleAdmin)
+}
+
+// getMacroForUser fetches the macro and checks it is visible to the user
+func (s *Server) getMacroForUser(r *http.Request, user *store.User, macroId uuid.UUID) (*store.Macro, error) {
+	macro, err := s.store.Macro.ById(r.Context(), macroId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		return nil, NewApiError(status, err)
+	}
+
+	if !macro.Shared && macro.Owner != user.Id {
+		return nil, NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to access this macro"))
+	}
+
+	return macro, nil
+}
+
+func (s *Server) getMacrosHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		macros, err := s.store.Macro.AvailableTo(r.Context(), user.Id)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetMacrosResponse]](w, http.StatusOK, ApiResponse[GetMacrosResponse]{
+			Data: &GetMacrosResponse{
+				Macros: macros,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createMacroHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[MacroRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		if req.Shared && !user.HasRole(store.RoleAdmin) {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("only admins can create shared macros"))
+		}
+
+		macro, err := s.store.Macro.Create(r.Context(), user.Id, req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.Macro]](w, http.StatusCreated, ApiResponse[store.Macro]{
+			Data: macro,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateMacroHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateMacroRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		macro, err := s.getMacroForUser(r, user, req.Id)
+		if err != nil {
+			return err
+		}
+
+		if !canManageMacro(user, macro) || (req.Shared && !user.HasRole(store.RoleAdmin)) {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to update this macro"))
+		}
+
+		macro, err = s.store.Macro.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.Macro]](w, http.StatusOK, ApiResponse[store.Macro]{
+			Data: macro,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteMacroHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteMacroRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		macro, err := s.getMacroForUser(r, user, req.Id)
+		if err != nil {
+			return err
+		}
+
+		if !canManageMacro(user, macro) {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to delete this macro"))
+		}
+
+		if err := s.store.Macro.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "macro has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) applyMacroHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[ApplyMacroRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		macro, err := s.getMacroForUser(r, user, req.MacroId)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.ticketForUser(r.Context(), user, req.TicketId); err != nil {
+			return err
+		}
+
+		result, err := s.store.Macro.Apply(r.Context(), macro, req.TicketId, user.Id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		response := ApplyMacroResponse{
+			Ticket: newTicketResponse(*result.Ticket, time.Now()),
+		}
+		if result.Reply != nil {
+			response.Reply = newReplyResponse(result.Reply)
+		}
+
+		if err := encode[ApiResponse[ApplyMacroResponse]](w, http.StatusOK, ApiResponse[ApplyMacroResponse]{
+			Data: &response,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const mentionedTicketsLimit = 100
+
+type GetMentionsResponse struct {
+	Mentions []store.Mention `json:"mentions"`
+}
+
+type GetMentionedTicketsResponse struct {
+	Tickets []store.MentionedTicket `json:"tickets"`
+}
+
+// getTicketMentionsHandler lists who was mentioned in the replies of a ticket, so they can be rendered
+func (s *Server) getTicketMentionsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		mentions, err := s.store.TicketReply.Mentions(r.Context(), ticketId)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetMentionsResponse]](w, http.StatusOK, ApiResponse[GetMentionsResponse]{
+			Data: &GetMentionsResponse{
+				Mentions: mentions,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// getMentionedTicketsHandler lists the tickets the caller was mentioned on
+func (s *Server) getMentionedTicketsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		tickets, err := s.store.TicketReply.MentionedTickets(r.Context(), user, mentionedTicketsLimit)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetMentionedTicketsResponse]](w, http.StatusOK, ApiResponse[GetMentionedTicketsResponse]{
+			Data: &GetMentionedTicketsResponse{
+				Tickets: tickets,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type reportQuery struct {
+	Group store.ReportGroup
+	From  time.Time
+	To    time.Time
+}
+
+// parseReportQuery reads ?group_by=assignee|team|category and the RFC 3339 ?from= and ?to=,
+// the range defaults to the last 30 days
+func parseReportQuery(r *http.Request) (*reportQuery, error) {
+	query := r.URL.Query()
+
+	report := &reportQuery{
+		Group: store.ReportGroup(query.Get("group_by")),
+	}
+
+	if !report.Group.Valid() {
+		return nil, NewApiError(http.StatusBadRequest, errors.New("group_by must be assignee, team or category"))
+	}
+
+	var err error
+	if report.From, report.To, err = parseReportRange(query); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// parseReportRange reads the RFC 3339 ?from= and ?to=, the range defaults to the last 30 days
+func parseReportRange(query url.Values) (from time.Time, to time.Time, err error) {
+	to = time.Now()
+	from = to.AddDate(0, 0, -30)
+
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return from, to, NewApiError(http.StatusBadRequest, fmt.Errorf("%s must be an RFC 3339 timestamp", name))
+			}
+			*target = t
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, NewApiError(http.StatusBadRequest, errors.New("from must be before to"))
+	}
+
+	return from, to, nil
+}
+
+type GetVolumeResponse struct {
+	GroupBy store.ReportGroup   `json:"group_by"`
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	Days    []store.DailyVolume `json:"days"`
+}
+
+type GetResponseTimesResponse struct {
+	GroupBy store.ReportGroup     `json:"group_by"`
+	From    time.Time             `json:"from"`
+	To      time.Time             `json:"to"`
+	Groups  []store.ResponseTimes `json:"groups"`
+}
+
+type GetBacklogResponse struct {
+	GroupBy store.ReportGroup    `json:"group_by"`
+	Backlog []store.BacklogCount `json:"backlog"`
+}
+
+func (s *Server) getVolumeHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		report, err := parseReportQuery(r)
+		if err != nil {
+			return err
+		}
+
+		days, err := s.store.Metrics.Volume(r.Context(), report.Group, report.From, report.To)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetVolumeResponse]](w, http.StatusOK, ApiResponse[GetVolumeResponse]{
+			Data: &GetVolumeResponse{
+				GroupBy: report.Group,
+				From:    report.From,
+				To:      report.To,
+				Days:    days,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getResponseTimesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		report, err := parseReportQuery(r)
+		if err != nil {
+			return err
+		}
+
+		groups, err := s.store.Metrics.ResponseTimes(r.Context(), report.Group, report.From, report.To)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetResponseTimesResponse]](w, http.StatusOK, ApiResponse[GetResponseTimesResponse]{
+			Data: &GetResponseTimesResponse{
+				GroupBy: report.Group,
+				From:    report.From,
+				To:      report.To,
+				Groups:  groups,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getBacklogHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		report, err := parseReportQuery(r)
+		if err != nil {
+			return err
+		}
+
+		backlog, err := s.store.Metrics.Backlog(r.Context(), report.Group)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetBacklogResponse]](w, http.StatusOK, ApiResponse[GetBacklogResponse]{
+			Data: &GetBacklogResponse{
+				GroupBy: report.Group,
+				Backlog: backlog,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -25,25 +25,47 @@ func WithUserContext(ctx context.Context, user *store.User) context.Context {
 	return context.WithValue(ctx, ContextUserKey{}, user)
 }
 
-var admin_routes = []string{"/api/tickets"}
+// public routes skip the jwt checks, inbound email authenticates with its own secret
+var public_routes = []string{"/api/auth", "/api/inbound", "/api/openapi.json"}
+
+func isPublicRoute(path string) bool {
+	for _, route := range public_routes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+var admin_routes = []string{"/api/tickets", "/api/admin"}
+
+// staff routes are open to both staff and admins
+var staff_routes = []string{"/api/macros", "/api/staff", "/api/ticket/assign", "/api/teams"}
 
 func NewPermissionsMiddleware() func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.HasPrefix(r.URL.Path, "/api/auth") {
+			if isPublicRoute(r.URL.Path) {
 				h.ServeHTTP(w, r)
 				return
 			}
 
 			user, err := GetUserFromContext(r.Context())
 			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				return
 			}
 
 			for _, route := range admin_routes {
 				if strings.HasPrefix(r.URL.Path, route) && !user.HasRole(store.RoleAdmin) {
-					w.WriteHeader(http.StatusForbidden)
+					writeStatusProblem(w, r, http.StatusForbidden, "")
+					return
+				}
+			}
+
+			for _, route := range staff_routes {
+				if strings.HasPrefix(r.URL.Path, route) && !user.HasRole(store.RoleStaff|store.RoleAdmin) {
+					writeStatusProblem(w, r, http.StatusForbidden, "")
 					return
 				}
 			}
@@ -56,7 +78,7 @@ func NewPermissionsMiddleware() func(h http.Handler) http.Handler {
 func NewAuthMiddleware(jwtManager *JwtManager, userStore *store.UserStore) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if strings.HasPrefix(r.URL.Path, "/api/auth") {
+			if isPublicRoute(r.URL.Path) {
 				h.ServeHTTP(w, r)
 				return
 			}
@@ -67,42 +89,46 @@ func NewAuthMiddleware(jwtManager *JwtManager, userStore *store.UserStore) func(
 				token = splitted[1]
 			}
 
+			// browsers cannot set headers on websocket handshakes
+			if token == "" && isWebsocketUpgrade(r) {
+				token = r.URL.Query().Get("access_token")
+			}
+
 			if token == "" {
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				return
 			}
 
 			parsedToken, err := jwtManager.ParseToken(token)
 			if err != nil {
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				slog.Error("failed to parse token", "error", err)
 				return
 			}
 
 			if !jwtManager.IsAccessToken(parsedToken) {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte("providing refresh tokens is not permitted"))
+				writeStatusProblem(w, r, http.StatusUnauthorized, "providing refresh tokens is not permitted")
 				return
 			}
 
 			parsedUserId, err := parsedToken.Claims.GetSubject()
 			if err != nil {
 				slog.Error("failed to get subject from token", "error", err)
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				return
 			}
 
 			userId, err := uuid.Parse(parsedUserId)
 			if err != nil {
 				slog.Error("couldn't parse user id string into an uuid", "error", err)
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				return
 			}
 
 			user, err := userStore.ById(r.Context(), userId)
 			if err != nil {
 				slog.Error("couldn't get user from db with user uuid", "error", err)
-				w.WriteHeader(http.StatusUnauthorized)
+				writeStatusProblem(w, r, http.StatusUnauthorized, "")
 				return
 			}
 
@@ -111,3 +137,7 @@ func NewAuthMiddleware(jwtManager *JwtManager, userStore *store.UserStore) func(
 		})
 	}
 }
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type NotificationPreferencesRequest struct {
+	// Channels maps notification kinds to the channels they are sent on, kinds left out are emailed
+	Channels       map[string][]string `json:"channels"`
+	Timezone       string              `json:"timezone"`
+	QuietStart     string              `json:"quiet_start"`
+	QuietEnd       string              `json:"quiet_end"`
+	Digest         bool                `json:"digest"`
+	DigestHour     int                 `json:"digest_hour"`
+	ChatWebhookUrl string              `json:"chat_webhook_url"`
+}
+
+func (req NotificationPreferencesRequest) Validate() error {
+	var errs ValidationErrors
+	for _, kind := range slices.Sorted(maps.Keys(req.Channels)) {
+		if !slices.Contains(store.NotificationKinds, kind) {
+			errs.Add("channels."+kind, FieldUnknown, "unknown notification kind %q, available kinds are %v", kind, store.NotificationKinds)
+			continue
+		}
+
+		for _, channel := range req.Channels[kind] {
+			if !slices.Contains(store.NotificationChannels, channel) {
+				errs.Add("channels."+kind, FieldInvalid, "unknown channel %q, available channels are %v", channel, store.NotificationChannels)
+			}
+		}
+	}
+
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			errs.Add("timezone", FieldInvalid, "unknown timezone %q", req.Timezone)
+		}
+	}
+
+	if (req.QuietStart == "") != (req.QuietEnd == "") {
+		field := "quiet_start"
+		if req.QuietEnd == "" {
+			field = "quiet_end"
+		}
+		errs.Add(field, FieldRequired, "quiet_start and quiet_end must be set together")
+	}
+
+	if req.QuietStart != "" {
+		if _, err := store.ParseClock(req.QuietStart); err != nil {
+			errs.Add("quiet_start", FieldInvalid, "quiet_start: %v", err)
+		}
+	}
+
+	if req.QuietEnd != "" {
+		if _, err := store.ParseClock(req.QuietEnd); err != nil {
+			errs.Add("quiet_end", FieldInvalid, "quiet_end: %v", err)
+		}
+	}
+
+	if req.DigestHour < 0 || req.DigestHour > 23 {
+		errs.Add("digest_hour", FieldInvalid, "digest_hour must be between 0 and 23")
+	}
+
+	if req.ChatWebhookUrl != "" {
+		u, err := url.Parse(req.ChatWebhookUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.Add("chat_webhook_url", FieldInvalid, "chat_webhook_url must be an absolute http or https url")
+		}
+	} else if req.usesChannel(store.ChannelChat) {
+		errs.Add("chat_webhook_url", FieldRequired, "chat_webhook_url is required to get notifications on chat")
+	}
+
+	return errs.Err()
+}
+
+func (req NotificationPreferencesRequest) usesChannel(channel string) bool {
+	for _, channels := range req.Channels {
+		if slices.Contains(channels, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (req NotificationPreferencesRequest) params(user *store.User) *store.NotificationPreferences {
+	prefs := store.DefaultNotificationPreferences(user.Id)
+
+	for kind, channels := range req.Channels {
+		// the same channel twice would send everything twice
+		slices.Sort(channels)
+		prefs.Channels[kind] = slices.Compact(channels)
+	}
+
+	if req.Timezone != "" {
+		prefs.Timezone = req.Timezone
+	}
+
+	prefs.QuietStart = req.QuietStart
+	prefs.QuietEnd = req.QuietEnd
+	prefs.Digest = req.Digest
+	prefs.DigestHour = req.DigestHour
+	prefs.ChatWebhookUrl = req.ChatWebhookUrl
+
+	return prefs
+}
+
+func (s *Server) getNotificationPreferencesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		prefs, err := s.store.Notification.Preferences(r.Context(), user.Id)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.NotificationPreferences]](w, http.StatusOK, ApiResponse[store.NotificationPreferences]{
+			Data: prefs,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// updateNotificationPreferencesHandler replaces the notification preferences of the user
+func (s *Server) updateNotificationPreferencesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[NotificationPreferencesRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		// chat webhooks make the server post to any url, which only staff is trusted with
+		if req.ChatWebhookUrl != "" && !user.HasRole(store.RoleStaff|store.RoleAdmin) {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("only staff can get notifications on chat"))
+		}
+
+		prefs, err := s.store.Notification.SetPreferences(r.Context(), req.params(user))
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.NotificationPreferences]](w, http.StatusOK, ApiResponse[store.NotificationPreferences]{
+			Data: prefs,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,653 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+// OpenApiDocument describes the api as OpenAPI 3.1, it is built from the operations below
+// and the request and response types they name, so their shapes can't drift apart
+type OpenApiDocument struct {
+	OpenApi    string                     `json:"openapi"`
+	Info       OpenApiInfo                `json:"info"`
+	Paths      map[string]OpenApiPathItem `json:"paths"`
+	Components OpenApiComponents          `json:"components"`
+	Security   []OpenApiSecurity          `json:"security"`
+}
+
+type OpenApiInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// OpenApiPathItem holds the operations of a path by lowercase method
+type OpenApiPathItem map[string]*OpenApiOperation
+
+type OpenApiOperation struct {
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []OpenApiParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenApiRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenApiResponse `json:"responses"`
+	// Security overrides the document security, an empty list makes the operation public
+	Security *[]OpenApiSecurity `json:"security,omitempty"`
+}
+
+type OpenApiSecurity map[string][]string
+
+type OpenApiParameter struct {
+	Name        string      `json:"name"`
+	In          string      `json:"in"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *JsonSchema `json:"schema"`
+}
+
+type OpenApiRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiMediaType struct {
+	Schema *JsonSchema `json:"schema,omitempty"`
+}
+
+type OpenApiResponse struct {
+	Ref         string                      `json:"$ref,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Headers     map[string]OpenApiHeader    `json:"headers,omitempty"`
+	Content     map[string]OpenApiMediaType `json:"content,omitempty"`
+}
+
+type OpenApiHeader struct {
+	Description string      `json:"description"`
+	Schema      *JsonSchema `json:"schema"`
+}
+
+type OpenApiComponents struct {
+	Schemas         map[string]*JsonSchema           `json:"schemas"`
+	Responses       map[string]*OpenApiResponse      `json:"responses"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes"`
+}
+
+type OpenApiSecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// JsonSchema is the part of JSON Schema the document uses. Type is a string, or a list
+// of strings for nullable values.
+type JsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 any                    `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	Properties           map[string]*JsonSchema `json:"properties,omitempty"`
+	Items                *JsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *JsonSchema            `json:"additionalProperties,omitempty"`
+	OneOf                []*JsonSchema          `json:"oneOf,omitempty"`
+}
+
+// operation documents a route
+type operation struct {
+	Summary     string
+	Description string
+	Query       []OpenApiParameter
+	// Request is the json body the route decodes, nil when it takes none and
+	// the media type as a string when the body isn't json
+	Request any
+	// Status of a successful response, 200 when not set
+	Status int
+	// Response is the data of a successful ApiResponse, nil when it only has a message
+	Response any
+	// Content is the media type of routes that don't answer with a json ApiResponse,
+	// websocket for the ones that upgrade the connection
+	Content string
+}
+
+func queryParam(name, typ, description string) OpenApiParameter {
+	schema := &JsonSchema{Type: typ}
+	if typ == "date-time" || typ == "uuid" {
+		schema = &JsonSchema{Type: "string", Format: typ}
+	}
+	return OpenApiParameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+var reportRangeQuery = []OpenApiParameter{
+	queryParam("from", "date-time", "Start of the report, 30 days before to by default"),
+	queryParam("to", "date-time", "End of the report, now by default"),
+}
+
+var ticketFilterQuery = []OpenApiParameter{
+	queryParam("category", "string", "Only tickets of the category"),
+	queryParam("overdue", "boolean", "Only tickets past their due date that are not done"),
+	queryParam("due_before", "date-time", "Only tickets due before the time"),
+	queryParam("due_after", "date-time", "Only tickets due after the time"),
+	queryParam("sort", "string", "Order of the tickets, such as due_at"),
+}
+
+// operations documents every route registered in Server.routes, by the same pattern
+var operations = map[string]operation{
+	"GET /ping":             {Summary: "Check that the server is up", Content: "text/plain"},
+	"GET /api/openapi.json": {Summary: "This document", Content: "application/json"},
+	// auth
+	"POST /api/auth/signup":  {Summary: "Sign up a new user", Request: SignupRequest{}, Status: http.StatusCreated},
+	"POST /api/auth/signin":  {Summary: "Sign in and get an access and a refresh token", Request: SigninRequest{}, Response: SigninResponse{}},
+	"POST /api/auth/refresh": {Summary: "Trade a refresh token for new tokens", Request: RefreshRequest{}, Response: RefreshResponse{}},
+	// ticket
+	"GET /api/ticket": {Summary: "Get a ticket", Request: TicketRequest{}, Response: TicketResponse{}},
+	"GET /api/tickets": {
+		Summary:     "List all tickets",
+		Description: "Custom fields are filtered with ?field.<name>=<value>.",
+		Query:       ticketFilterQuery,
+		Response:    GetAllTicketsResponse{},
+	},
+	"POST /api/ticket":                                {Summary: "Create a ticket, optionally from a template", Request: CreateTicketRequest{}, Status: http.StatusCreated, Response: TicketResponse{}},
+	"PUT /api/ticket":                                 {Summary: "Update a ticket", Request: UpdateTicketRequest{}},
+	"GET /api/ticket/{id}/history":                    {Summary: "The history of a ticket", Response: GetTicketHistoryResponse{}},
+	"PUT /api/ticket/assign":                          {Summary: "Assign a ticket to staff and/or a team", Request: AssignTicketRequest{}, Response: TicketResponse{}},
+	"GET /api/ticket/{id}/watchers":                   {Summary: "List the watchers of a ticket", Response: GetWatchersResponse{}},
+	"POST /api/ticket/{id}/watchers":                  {Summary: "Watch a ticket"},
+	"DELETE /api/ticket/{id}/watchers":                {Summary: "Stop watching a ticket"},
+	"PUT /api/ticket/{id}/replies/{replyId}":          {Summary: "Edit a reply", Request: EditReplyRequest{}, Response: ReplyResponse{}},
+	"DELETE /api/ticket/{id}/replies/{replyId}":       {Summary: "Delete a reply"},
+	"GET /api/admin/replies/{id}/revisions":           {Summary: "The previous messages of an edited or deleted reply", Response: GetReplyRevisionsResponse{}},
+	"DELETE /api/admin/replies/{id}/revisions":        {Summary: "Purge the previous messages of a reply"},
+	"GET /api/ticket/{id}/mentions":                   {Summary: "The mentions in the replies of a ticket", Response: GetMentionsResponse{}},
+	"GET /api/mentions":                               {Summary: "The latest tickets the caller was mentioned in", Response: GetMentionedTicketsResponse{}},
+	"GET /api/ticket/{id}/attachments":                {Summary: "List the attachments of a ticket", Response: GetAttachmentsResponse{}},
+	"GET /api/ticket/{id}/attachments/{attachmentId}": {Summary: "Download an attachment", Content: "application/octet-stream"},
+	"GET /api/events": {
+		Summary:     "Stream the ticket events the caller may see",
+		Description: "Server-sent events, resume with the Last-Event-ID header or ?last_event_id=.",
+		Query:       []OpenApiParameter{queryParam("last_event_id", "integer", "Id of the last event received, its position in the stream")},
+		Content:     "text/event-stream",
+	},
+	"GET /api/ticket/{id}/chat": {
+		Summary:     "Chat on a ticket over a websocket",
+		Description: "Browsers pass the access token as ?access_token= since they can't set headers on the handshake.",
+		Query:       []OpenApiParameter{queryParam("access_token", "string", "Access token, when the Authorization header can't be set")},
+		Status:      http.StatusSwitchingProtocols,
+		Content:     "websocket",
+	},
+	// notifications
+	"GET /api/notifications": {
+		Summary: "List the in-app notifications of the caller, newest first",
+		Query: []OpenApiParameter{
+			queryParam("unread", "boolean", "Only unread notifications"),
+			queryParam("limit", "integer", "How many notifications to list"),
+			queryParam("cursor", "uuid", "List the notifications after this one"),
+		},
+		Response: GetInboxResponse{},
+	},
+	"GET /api/notifications/unread":      {Summary: "Count the unread notifications", Response: UnreadCountResponse{}},
+	"POST /api/notifications/read":       {Summary: "Mark notifications as read", Request: MarkReadRequest{}, Response: MarkReadResponse{}},
+	"POST /api/notifications/read-all":   {Summary: "Mark every notification as read", Response: MarkReadResponse{}},
+	"GET /api/notifications/preferences": {Summary: "Get the notification preferences of the caller", Response: store.NotificationPreferences{}},
+	"PUT /api/notifications/preferences": {Summary: "Update the notification preferences of the caller", Request: NotificationPreferencesRequest{}, Response: store.NotificationPreferences{}},
+	"POST /api/inbound/email": {
+		Summary:     "Turn a raw email into a reply or a new ticket",
+		Description: "Takes the email as message/rfc822, automatic messages are ignored with 202 Accepted and emails that were already taken in answer 200 OK with the first result. Replies need the reply token of their ticket in a recipient address.",
+		Request:     "message/rfc822",
+		Status:      http.StatusCreated,
+		Response:    InboundEmailResponse{},
+	},
+	// ratings
+	"POST /api/ticket/{id}/rating":   {Summary: "Rate a resolved ticket", Request: RateTicketRequest{}, Status: http.StatusCreated, Response: store.TicketRating{}},
+	"GET /api/ticket/{id}/rating":    {Summary: "Get the rating of a ticket", Response: store.TicketRating{}},
+	"GET /api/admin/ratings/summary": {Summary: "Average ratings", Query: reportGroupQuery("assignee, team or category"), Response: GetRatingSummaryResponse{}},
+	// metrics
+	"GET /api/admin/metrics/volume":         {Summary: "Tickets created and resolved per day", Query: reportGroupQuery("assignee, team or category"), Response: GetVolumeResponse{}},
+	"GET /api/admin/metrics/response-times": {Summary: "Median and p90 time to first response and to resolution", Query: reportGroupQuery("assignee, team or category"), Response: GetResponseTimesResponse{}},
+	"GET /api/admin/metrics/backlog":        {Summary: "Unresolved tickets by status and priority", Query: reportGroupQuery("assignee, team or category"), Response: GetBacklogResponse{}},
+	"GET /api/admin/metrics/work":           {Summary: "Time logged on the days from from through to", Query: reportGroupQuery("ticket, user or category"), Response: GetWorkTotalsResponse{}},
+	// time tracking
+	"GET /api/ticket/{id}/worklogs":            {Summary: "List the work logged on a ticket", Response: GetWorkLogsResponse{}},
+	"POST /api/ticket/{id}/worklogs":           {Summary: "Log work on a ticket", Request: LogWorkRequest{}, Status: http.StatusCreated, Response: store.WorkLog{}},
+	"DELETE /api/ticket/{id}/worklogs/{logId}": {Summary: "Delete a work log"},
+	"POST /api/ticket/{id}/timer":              {Summary: "Start a timer on a ticket", Status: http.StatusCreated, Response: store.WorkTimer{}},
+	"GET /api/staff/timer":                     {Summary: "The running timer of the caller", Response: store.WorkTimer{}},
+	"POST /api/staff/timer/stop":               {Summary: "Stop the running timer and log its time", Request: StopTimerRequest{}, Status: http.StatusCreated, Response: store.WorkLog{}},
+	// webhooks
+	"GET /api/admin/webhooks":                    {Summary: "List webhooks", Response: GetWebhooksResponse{}},
+	"POST /api/admin/webhooks":                   {Summary: "Register a webhook", Request: WebhookRequest{}, Status: http.StatusCreated, Response: store.Webhook{}},
+	"PUT /api/admin/webhooks":                    {Summary: "Update a webhook", Request: UpdateWebhookRequest{}, Response: store.Webhook{}},
+	"DELETE /api/admin/webhooks":                 {Summary: "Delete a webhook", Request: DeleteWebhookRequest{}},
+	"GET /api/admin/webhooks/{id}/deliveries":    {Summary: "The latest deliveries of a webhook", Response: GetWebhookDeliveriesResponse{}},
+	"POST /api/admin/webhooks/deliveries/replay": {Summary: "Send a past delivery again", Request: ReplayDeliveryRequest{}, Status: http.StatusCreated, Response: store.WebhookDelivery{}},
+	// templates
+	"GET /api/templates":          {Summary: "List ticket templates", Response: GetAllTemplatesResponse{}},
+	"POST /api/admin/templates":   {Summary: "Create a ticket template", Request: TemplateRequest{}, Status: http.StatusCreated, Response: store.TicketTemplate{}},
+	"PUT /api/admin/templates":    {Summary: "Update a ticket template", Request: UpdateTemplateRequest{}, Response: store.TicketTemplate{}},
+	"DELETE /api/admin/templates": {Summary: "Delete a ticket template", Request: DeleteTemplateRequest{}},
+	// ticket schedules
+	"GET /api/admin/schedules":           {Summary: "List ticket schedules", Response: GetSchedulesResponse{}},
+	"POST /api/admin/schedules":          {Summary: "Create a schedule that opens tickets from a template", Request: ScheduleRequest{}, Status: http.StatusCreated, Response: store.TicketSchedule{}},
+	"PUT /api/admin/schedules":           {Summary: "Update a schedule", Request: UpdateScheduleRequest{}, Response: store.TicketSchedule{}},
+	"DELETE /api/admin/schedules":        {Summary: "Delete a schedule", Request: DeleteScheduleRequest{}},
+	"GET /api/admin/schedules/{id}/runs": {Summary: "The latest runs of a schedule", Response: GetScheduleRunsResponse{}},
+	// custom fields
+	"GET /api/fields": {
+		Summary:  "List custom fields",
+		Query:    []OpenApiParameter{queryParam("category", "string", "Only the fields of the category")},
+		Response: GetCustomFieldsResponse{},
+	},
+	"POST /api/admin/fields":   {Summary: "Create a custom field", Request: CustomFieldRequest{}, Status: http.StatusCreated, Response: store.CustomField{}},
+	"PUT /api/admin/fields":    {Summary: "Update a custom field", Request: UpdateCustomFieldRequest{}, Response: store.CustomField{}},
+	"DELETE /api/admin/fields": {Summary: "Delete a custom field", Request: DeleteCustomFieldRequest{}},
+	// routing rules
+	"GET /api/admin/rules":           {Summary: "List routing rules", Response: GetRoutingRulesResponse{}},
+	"POST /api/admin/rules":          {Summary: "Create a routing rule", Request: RoutingRuleRequest{}, Status: http.StatusCreated, Response: store.RoutingRule{}},
+	"PUT /api/admin/rules":           {Summary: "Update a routing rule", Request: UpdateRoutingRuleRequest{}, Response: store.RoutingRule{}},
+	"DELETE /api/admin/rules":        {Summary: "Delete a routing rule", Request: DeleteRoutingRuleRequest{}},
+	"POST /api/admin/rules/simulate": {Summary: "Show which rules would match a ticket", Request: SimulateRulesRequest{}, Response: SimulateRulesResponse{}},
+	// assignment queues
+	"GET /api/admin/queues":       {Summary: "List assignment queues", Response: GetAssignmentQueuesResponse{}},
+	"POST /api/admin/queues":      {Summary: "Create an assignment queue", Request: AssignmentQueueRequest{}, Status: http.StatusCreated, Response: store.AssignmentQueue{}},
+	"PUT /api/admin/queues":       {Summary: "Update an assignment queue", Request: UpdateAssignmentQueueRequest{}, Response: store.AssignmentQueue{}},
+	"DELETE /api/admin/queues":    {Summary: "Delete an assignment queue", Request: DeleteAssignmentQueueRequest{}},
+	"PUT /api/staff/availability": {Summary: "Set whether the caller takes new tickets", Request: AvailabilityRequest{}, Response: AvailabilityResponse{}},
+	// teams
+	"GET /api/teams": {
+		Summary:  "List teams",
+		Query:    []OpenApiParameter{queryParam("mine", "boolean", "Only the teams of the caller")},
+		Response: GetTeamsResponse{},
+	},
+	"GET /api/teams/{id}/members":    {Summary: "List the members of a team", Response: GetTeamMembersResponse{}},
+	"POST /api/teams/{id}/members":   {Summary: "Add a member to a team", Request: TeamMemberRequest{}},
+	"DELETE /api/teams/{id}/members": {Summary: "Remove a member from a team", Request: TeamMemberRequest{}},
+	"GET /api/teams/{id}/tickets": {
+		Summary:  "List the tickets of a team",
+		Query:    ticketFilterQuery,
+		Response: GetTeamTicketsResponse{},
+	},
+	"POST /api/admin/teams":   {Summary: "Create a team", Request: TeamRequest{}, Status: http.StatusCreated, Response: store.Team{}},
+	"PUT /api/admin/teams":    {Summary: "Update a team", Request: UpdateTeamRequest{}, Response: store.Team{}},
+	"DELETE /api/admin/teams": {Summary: "Delete a team", Request: DeleteTeamRequest{}},
+	// macros
+	"GET /api/macros":        {Summary: "List macros", Response: GetMacrosResponse{}},
+	"POST /api/macros":       {Summary: "Create a macro", Request: MacroRequest{}, Status: http.StatusCreated, Response: store.Macro{}},
+	"PUT /api/macros":        {Summary: "Update a macro", Request: UpdateMacroRequest{}, Response: store.Macro{}},
+	"DELETE /api/macros":     {Summary: "Delete a macro", Request: DeleteMacroRequest{}},
+	"POST /api/macros/apply": {Summary: "Apply a macro to a ticket", Request: ApplyMacroRequest{}, Response: ApplyMacroResponse{}},
+}
+
+func reportGroupQuery(groups string) []OpenApiParameter {
+	return append([]OpenApiParameter{queryParam("group_by", "string", "Split the report by "+groups)}, reportRangeQuery...)
+}
+
+// schemaDescriptions document types whose json doesn't say what they mean
+var schemaDescriptions = map[reflect.Type]*JsonSchema{
+	reflect.TypeFor[store.TicketPriority](): {Type: "integer", Enum: []any{0, 1, 2, 3}, Description: "0 urgent, 1 high, 2 medium, 3 low"},
+	reflect.TypeFor[store.TicketStatus]():   {Type: "integer", Enum: []any{0, 1, 2, 3}, Description: "0 created, 1 in progress, 2 done, 3 closed"},
+	reflect.TypeFor[time.Duration]():        {Type: "integer", Description: "nanoseconds"},
+	reflect.TypeFor[time.Time]():            {Type: "string", Format: "date-time"},
+	reflect.TypeFor[uuid.UUID]():            {Type: "string", Format: "uuid"},
+	reflect.TypeFor[uuid.NullUUID]():        {Type: []string{"string", "null"}, Format: "uuid"},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// NewOpenApiDocument documents the routes, routes without an operation are left out.
+// Routes with rate limits document the 429 Too Many Requests they can be refused with.
+func NewOpenApiDocument(routes []string, limits RateLimits) (*OpenApiDocument, error) {
+	schemas := schemaBuilder{schemas: map[string]*JsonSchema{}, types: map[string]reflect.Type{}}
+	doc := &OpenApiDocument{
+		OpenApi: "3.1.0",
+		Info: OpenApiInfo{
+			Title:   "ticketr",
+			Version: "1.0.0",
+			Description: "Successful responses wrap their data in {\"data\": ..., \"message\": ...}, errors are RFC 9457 problems. " +
+				"Some routes are rate limited, limited responses carry RateLimit-* headers and refused ones Retry-After.",
+		},
+		Paths:    map[string]OpenApiPathItem{},
+		Security: []OpenApiSecurity{{"bearer": {}}},
+		Components: OpenApiComponents{
+			Schemas: schemas.schemas,
+			Responses: map[string]*OpenApiResponse{
+				"Problem": {
+					Description: "The request failed",
+					Content:     map[string]OpenApiMediaType{problemContentType: {Schema: &JsonSchema{Ref: "#/components/schemas/Problem"}}},
+				},
+				"TooManyRequests": {
+					Description: "The request is over a rate limit of the route",
+					Headers: map[string]OpenApiHeader{
+						"RateLimit-Limit":     {Description: "Requests allowed by the tightest limit", Schema: &JsonSchema{Type: "integer"}},
+						"RateLimit-Remaining": {Description: "Requests left under that limit", Schema: &JsonSchema{Type: "integer"}},
+						"RateLimit-Reset":     {Description: "Seconds until the limit is fully restored", Schema: &JsonSchema{Type: "integer"}},
+						"RateLimit-Policy":    {Description: "The limit as <requests>;w=<window seconds>", Schema: &JsonSchema{Type: "string"}},
+						"Retry-After":         {Description: "Seconds until the request would be allowed", Schema: &JsonSchema{Type: "integer"}},
+					},
+					Content: map[string]OpenApiMediaType{problemContentType: {Schema: &JsonSchema{Ref: "#/components/schemas/Problem"}}},
+				},
+			},
+			SecuritySchemes: map[string]OpenApiSecurityScheme{
+				"bearer":        {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from /api/auth/signin"},
+				"inboundSecret": {Type: "http", Scheme: "bearer", Description: "INBOUND_EMAIL_SECRET shared with the mta"},
+			},
+		},
+	}
+
+	if err := schemas.add(reflect.TypeFor[Problem]()); err != nil {
+		return nil, err
+	}
+
+	for _, route := range routes {
+		op, ok := operations[route]
+		if !ok {
+			continue
+		}
+
+		method, path, _ := strings.Cut(route, " ")
+		limited := len(limits[route]) > 0 || len(limits[path]) > 0
+		documented, err := op.document(path, limited, &schemas)
+		if err != nil {
+			return nil, fmt.Errorf("failed to document %s: %w", route, err)
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = OpenApiPathItem{}
+		}
+		doc.Paths[path][strings.ToLower(method)] = documented
+	}
+
+	return doc, nil
+}
+
+func (op operation) document(path string, limited bool, schemas *schemaBuilder) (*OpenApiOperation, error) {
+	documented := &OpenApiOperation{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses:   map[string]*OpenApiResponse{},
+	}
+
+	problem := &OpenApiResponse{Ref: "#/components/responses/Problem"}
+	documented.Responses["default"] = problem
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		documented.Parameters = append(documented.Parameters, OpenApiParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &JsonSchema{Type: "string", Format: "uuid"},
+		})
+	}
+	documented.Parameters = append(documented.Parameters, op.Query...)
+
+	switch request := op.Request.(type) {
+	case nil:
+	case string:
+		documented.RequestBody = &OpenApiRequestBody{Required: true, Content: map[string]OpenApiMediaType{request: {}}}
+	default:
+		schema, err := schemas.schema(reflect.TypeOf(request))
+		if err != nil {
+			return nil, err
+		}
+		documented.RequestBody = &OpenApiRequestBody{Required: true, Content: map[string]OpenApiMediaType{"application/json": {Schema: schema}}}
+	}
+
+	if documented.RequestBody != nil || len(documented.Parameters) > 0 {
+		documented.Responses["400"] = problem
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/api/inbound"):
+		documented.Security = &[]OpenApiSecurity{{"inboundSecret": {}}}
+		documented.Responses["401"] = problem
+	case isPublicRoute(path):
+		documented.Security = &[]OpenApiSecurity{}
+	default:
+		documented.Responses["401"] = problem
+	}
+
+	if access := routeAccess(path); access != "" {
+		documented.Description = strings.TrimSpace(documented.Description + " " + access)
+		documented.Responses["403"] = problem
+	}
+
+	if limited {
+		documented.Responses["429"] = &OpenApiResponse{Ref: "#/components/responses/TooManyRequests"}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := &OpenApiResponse{Description: http.StatusText(status)}
+	switch op.Content {
+	case "":
+		data := &JsonSchema{}
+		if op.Response != nil {
+			var err error
+			if data, err = schemas.schema(reflect.TypeOf(op.Response)); err != nil {
+				return nil, err
+			}
+		}
+
+		success.Content = map[string]OpenApiMediaType{"application/json": {Schema: &JsonSchema{
+			Type: "object",
+			Properties: map[string]*JsonSchema{
+				"data":    data,
+				"message": {Type: "string"},
+			},
+		}}}
+	case "websocket":
+		success.Description = "Upgraded to a websocket"
+	default:
+		success.Content = map[string]OpenApiMediaType{op.Content: {}}
+	}
+	documented.Responses[fmt.Sprint(status)] = success
+
+	return documented, nil
+}
+
+// routeAccess says who besides signed in users can call a route, as the permissions middleware checks it
+func routeAccess(path string) string {
+	for _, route := range admin_routes {
+		if strings.HasPrefix(path, route) {
+			return "Admins only."
+		}
+	}
+
+	for _, route := range staff_routes {
+		if strings.HasPrefix(path, route) {
+			return "Staff and admins only."
+		}
+	}
+
+	return ""
+}
+
+// schemaBuilder turns go types into json schemas the way encoding/json encodes them,
+// named structs become components that are referenced
+type schemaBuilder struct {
+	schemas map[string]*JsonSchema
+	types   map[string]reflect.Type
+}
+
+func (b *schemaBuilder) add(t reflect.Type) error {
+	_, err := b.schema(t)
+	return err
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) (*JsonSchema, error) {
+	if described, ok := schemaDescriptions[t]; ok {
+		return described, nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		schema, err := b.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(schema), nil
+	}
+
+	if t.Implements(reflect.TypeFor[json.Marshaler]()) {
+		return &JsonSchema{Description: "encoded by " + t.String()}, nil
+	}
+
+	if t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return &JsonSchema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JsonSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &JsonSchema{Type: "number"}, nil
+	case reflect.String:
+		return &JsonSchema{Type: "string"}, nil
+	case reflect.Interface:
+		return &JsonSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JsonSchema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := b.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := b.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JsonSchema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return b.object(t)
+	default:
+		return nil, fmt.Errorf("can't describe %s as json", t)
+	}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) (*JsonSchema, error) {
+	if t.Name() == "" {
+		return b.properties(t)
+	}
+
+	ref := &JsonSchema{Ref: "#/components/schemas/" + t.Name()}
+	if seen, ok := b.types[t.Name()]; ok {
+		if seen != t {
+			return nil, fmt.Errorf("%s and %s would share a schema name", seen, t)
+		}
+		return ref, nil
+	}
+
+	// registered before its fields so that types referencing themselves end
+	b.types[t.Name()] = t
+	schema, err := b.properties(t)
+	if err != nil {
+		return nil, err
+	}
+	b.schemas[t.Name()] = schema
+	return ref, nil
+}
+
+// properties lists the fields of a struct, fields of embedded structs are promoted like encoding/json does
+func (b *schemaBuilder) properties(t reflect.Type) (*JsonSchema, error) {
+	schema := &JsonSchema{Type: "object", Properties: map[string]*JsonSchema{}}
+
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || len(field.Index) > 1 && !promoted(t, field) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property, err := b.schema(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		schema.Properties[name] = property
+	}
+
+	return schema, nil
+}
+
+// promoted reports whether a field of an embedded struct ends up in the json of t, which
+// is only the case when every struct on the way to it is embedded without a json name
+func promoted(t reflect.Type, field reflect.StructField) bool {
+	for _, i := range field.Index[:len(field.Index)-1] {
+		embedded := t.Field(i)
+		if !embedded.Anonymous || embedded.Tag.Get("json") != "" {
+			return false
+		}
+		t = indirect(embedded.Type)
+	}
+	return true
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+func nullable(schema *JsonSchema) *JsonSchema {
+	if typ, ok := schema.Type.(string); ok && schema.Ref == "" && schema.Enum == nil {
+		copied := *schema
+		copied.Type = []string{typ, "null"}
+		return &copied
+	}
+
+	if schema.Type == nil && schema.Ref == "" {
+		return schema
+	}
+
+	return &JsonSchema{OneOf: []*JsonSchema{schema, {Type: "null"}}}
+}
+
+func (s *Server) openApiHandler() http.HandlerFunc {
+	// routes are fixed once the server runs, so the document is only built once
+	document := sync.OnceValues(func() (*OpenApiDocument, error) {
+		limits, err := ParseRateLimits(s.Config.RateLimits)
+		if err != nil {
+			return nil, err
+		}
+		return NewOpenApiDocument(s.Routes(), limits)
+	})
+
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		doc, err := document()
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[*OpenApiDocument](w, http.StatusOK, doc); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,67 @@
+package server_test
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/config"
+	"github.com/tatucosmin/hotel-system/server"
+)
+
+func TestOpenApiDocumentsEveryRoute(t *testing.T) {
+	cfg, err := config.New()
+	require.NoError(t, err)
+
+	limits, err := server.ParseRateLimits(cfg.RateLimits)
+	require.NoError(t, err)
+
+	routes := server.New(cfg, nil, nil, nil, nil, nil).Routes()
+	doc, err := server.NewOpenApiDocument(routes, limits)
+	require.NoError(t, err)
+
+	var undocumented []string
+	for _, route := range routes {
+		method, path, _ := strings.Cut(route, " ")
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			undocumented = append(undocumented, route)
+		}
+	}
+	require.Empty(t, undocumented, "add these routes to the operations in server/openapi.go")
+
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+
+	for _, match := range regexp.MustCompile(`"\$ref":"#/components/schemas/(\w+)"`).FindAllStringSubmatch(string(raw), -1) {
+		require.Contains(t, doc.Components.Schemas, match[1])
+	}
+
+	create := doc.Paths["/api/ticket"]["post"]
+	require.Nil(t, create.Security)
+	require.Contains(t, create.Responses, "201")
+	require.Contains(t, create.Responses, "400")
+	require.Equal(t, "#/components/schemas/CreateTicketRequest", create.RequestBody.Content["application/json"].Schema.Ref)
+
+	request := doc.Components.Schemas["CreateTicketRequest"]
+	require.Equal(t, "string", request.Properties["title"].Type)
+	require.Equal(t, []string{"string", "null"}, request.Properties["due_at"].Type)
+	require.Equal(t, []any{0, 1, 2, 3}, request.Properties["priority"].OneOf[0].Enum)
+
+	// embedded structs are flattened like encoding/json does
+	response := doc.Components.Schemas["TicketResponse"]
+	require.Contains(t, response.Properties, "Title")
+	require.Contains(t, response.Properties, "description_html")
+
+	signin := doc.Paths["/api/auth/signin"]["post"]
+	require.Empty(t, *signin.Security)
+
+	// the routes with rate limits can be refused with a 429
+	require.Equal(t, "#/components/responses/TooManyRequests", create.Responses["429"].Ref)
+	require.Contains(t, signin.Responses, "429")
+	require.NotContains(t, doc.Paths["/api/ticket"]["get"].Responses, "429")
+	require.Contains(t, doc.Components.Responses["TooManyRequests"].Headers, "Retry-After")
+	require.Contains(t, doc.Components.Responses["TooManyRequests"].Headers, "RateLimit-Remaining")
+	require.Contains(t, doc.Paths["/api/admin/webhooks"]["get"].Responses, "403")
+}
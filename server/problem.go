@@ -0,0 +1,187 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+// Error codes identify what went wrong independently of the message, clients can rely on them not changing
+const (
+	CodeBadRequest      = "bad_request"
+	CodeMalformedBody   = "malformed_body"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodePayloadTooLarge = "payload_too_large"
+	CodeRateLimited     = "rate_limited"
+	CodeInternal        = "internal_error"
+
+	CodeEmailTaken          = "email_taken"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeAlreadyRated        = "already_rated"
+	CodeTicketNotResolved   = "ticket_not_resolved"
+	CodeRatingClosed        = "rating_closed"
+	CodeEditWindowClosed    = "edit_window_closed"
+	CodeReplyDeleted        = "reply_deleted"
+	CodeTimerRunning        = "timer_running"
+)
+
+// Field error codes say what is wrong with a single field of a request
+const (
+	FieldRequired = "required"
+	FieldInvalid  = "invalid"
+	FieldUnknown  = "unknown"
+	FieldNotFound = "not_found"
+	FieldConflict = "conflict"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an error response as described by RFC 9457, with the code of the error
+// and, for requests that failed validation, the problems with each of their fields
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a problem with one field of a request, nested fields are joined with dots
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every problem with the fields of a request so that clients
+// learn about all of them at once. It wraps store.ErrValidation.
+type ValidationErrors []FieldError
+
+func (errs *ValidationErrors) Add(field, code, format string, args ...any) {
+	*errs = append(*errs, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err is nil when no problems were added, so that validators can end with return errs.Err()
+func (errs ValidationErrors) Err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (errs ValidationErrors) Unwrap() error {
+	return store.ErrValidation
+}
+
+// errMalformedBody is wrapped by errors decoding a request body that is not valid json
+var errMalformedBody = errors.New("malformed request body")
+
+// codes of the errors that mean the same thing wherever they come from
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{errMalformedBody, CodeMalformedBody},
+	{store.ErrValidation, CodeValidation},
+	{store.ErrAlreadyRated, CodeAlreadyRated},
+	{store.ErrReplyDeleted, CodeReplyDeleted},
+	{store.ErrTimerRunning, CodeTimerRunning},
+}
+
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	default:
+		return CodeInternal
+	}
+}
+
+// problemFor builds the response to an error returned by a handler. Only client errors
+// describe what went wrong, the rest could leak internals and just have their status.
+func problemFor(r *http.Request, err error) Problem {
+	status := http.StatusInternalServerError
+	code := CodeInternal
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		status = apiErr.status
+		code = apiErr.code
+	}
+
+	problem := Problem{
+		Type:     problemType(code),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.Path,
+		Code:     code,
+	}
+
+	if status < http.StatusInternalServerError && status != http.StatusForbidden && status != http.StatusNotFound {
+		problem.Detail = apiErr.err.Error()
+
+		var fieldErrs ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			problem.Detail = "the request has invalid fields"
+			problem.Errors = fieldErrs
+		}
+	}
+
+	return problem
+}
+
+func problemType(code string) string {
+	return "urn:ticketr:problem:" + code
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		slog.Error("error encoding problem", "error", err, "status", problem.Status, "code", problem.Code)
+	}
+}
+
+// writeStatusProblem answers with a problem that only has a status, for errors outside of handlers
+func writeStatusProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	code := statusCode(status)
+	writeProblem(w, Problem{
+		Type:     problemType(code),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	})
+}
@@ -0,0 +1,54 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/server"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestValidationErrors(t *testing.T) {
+	err := server.CreateTicketRequest{}.Validate()
+	require.ErrorIs(t, err, store.ErrValidation)
+
+	var errs server.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	require.Equal(t, server.ValidationErrors{
+		{Field: "title", Code: server.FieldRequired, Message: "title is required"},
+		{Field: "description", Code: server.FieldRequired, Message: "description is required"},
+		{Field: "priority", Code: server.FieldRequired, Message: "priority is required"},
+	}, errs)
+
+	require.NoError(t, server.SigninRequest{Email: "guest@test.com", Password: "test"}.Validate())
+
+	// embedded requests report their own fields after the ones of the outer request
+	err = server.UpdateTeamRequest{}.Validate()
+	require.ErrorAs(t, err, &errs)
+	require.Equal(t, []string{"id", "name"}, []string{errs[0].Field, errs[1].Field})
+}
+
+func TestProblemResponse(t *testing.T) {
+	handler := server.NewPermissionsMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/admin/webhooks", nil))
+
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem server.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	require.Equal(t, server.Problem{
+		Type:     "urn:ticketr:problem:unauthorized",
+		Title:    "Unauthorized",
+		Status:   http.StatusUnauthorized,
+		Instance: "/api/admin/webhooks",
+		Code:     server.CodeUnauthorized,
+	}, problem)
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	RateLimitByIp   = "ip"
+	RateLimitByUser = "user"
+)
+
+// RouteLimit is a rate limit on a route, counted per client ip or per signed in user
+type RouteLimit struct {
+	By string
+	store.RateLimit
+}
+
+// RateLimits are the limits of each route, keyed by "METHOD /path" or by "/path" for every method
+type RateLimits map[string][]RouteLimit
+
+// ParseRateLimits reads limits written as "POST /api/ticket=ip:60/1h,user:20/1h", with the
+// limits of several routes separated by semicolons
+func ParseRateLimits(spec string) (RateLimits, error) {
+	limits := RateLimits{}
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, routeLimits, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q must look like ROUTE=ip:10/1m", entry)
+		}
+
+		route = strings.Join(strings.Fields(route), " ")
+		if _, path, _ := strings.Cut(route, " "); !strings.HasPrefix(route, "/") && !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("rate limited route %q must be a path, optionally after a method", route)
+		}
+
+		for _, limit := range strings.Split(routeLimits, ",") {
+			parsed, err := parseRouteLimit(strings.TrimSpace(limit))
+			if err != nil {
+				return nil, fmt.Errorf("rate limit of %s: %w", route, err)
+			}
+			limits[route] = append(limits[route], parsed)
+		}
+	}
+
+	return limits, nil
+}
+
+func parseRouteLimit(limit string) (RouteLimit, error) {
+	by, rate, _ := strings.Cut(limit, ":")
+	if by != RateLimitByIp && by != RateLimitByUser {
+		return RouteLimit{}, fmt.Errorf("%q must be counted by %s or %s", limit, RateLimitByIp, RateLimitByUser)
+	}
+
+	count, window, _ := strings.Cut(rate, "/")
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return RouteLimit{}, fmt.Errorf("%q must allow a positive number of requests", limit)
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 || d > store.RateLimitMaxWindow {
+		return RouteLimit{}, fmt.Errorf("%q must have a window such as 1m, of at most %s", limit, store.RateLimitMaxWindow)
+	}
+
+	return RouteLimit{By: by, RateLimit: store.RateLimit{Limit: n, Window: d}}, nil
+}
+
+// NewRateLimitMiddleware refuses requests over the limits of their route with 429 Too Many
+// Requests and a Retry-After header, and tells every limited request where it stands in
+// RateLimit-* headers. Limits per user only count signed in requests, so the middleware
+// runs after authentication. A refused request gives its tokens back to the other limits,
+// so a client over its user limit does not drain the limit of its address. When the limiter
+// fails requests are let through.
+func NewRateLimitMiddleware(limiter store.RateLimiter, limits RateLimits, trustProxy bool, logger *slog.Logger) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := r.Method + " " + r.URL.Path
+			routeLimits, ok := limits[route]
+			if !ok {
+				route = r.URL.Path
+				routeLimits = limits[route]
+			}
+
+			type taken struct {
+				key   string
+				limit store.RateLimit
+			}
+
+			var tightest *store.RateLimitResult
+			var allowed []taken
+			for _, limit := range routeLimits {
+				var subject string
+				switch limit.By {
+				case RateLimitByIp:
+					subject = clientIp(r, trustProxy)
+				case RateLimitByUser:
+					user, err := GetUserFromContext(r.Context())
+					if err != nil {
+						continue
+					}
+					subject = user.Id.String()
+				}
+
+				key := fmt.Sprintf("%s|%s:%s|%s", route, limit.By, subject, limit.RateLimit)
+				result, err := limiter.Take(r.Context(), key, limit.RateLimit, time.Now())
+				if err != nil {
+					logger.Error("failed to check rate limit", "error", err, "route", route)
+					continue
+				}
+
+				if result.Allowed {
+					allowed = append(allowed, taken{key: key, limit: limit.RateLimit})
+				}
+
+				if tightest == nil || tighter(result, *tightest) {
+					tightest = &result
+				}
+			}
+
+			if tightest != nil && !tightest.Allowed {
+				for _, t := range allowed {
+					if err := limiter.Refund(r.Context(), t.key, t.limit); err != nil {
+						logger.Error("failed to refund rate limit", "error", err, "route", route)
+					}
+				}
+			}
+
+			if tightest == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			header := w.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(tightest.Limit.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(int(tightest.Reset.Seconds())))
+			header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", tightest.Limit.Limit, int(tightest.Limit.Window.Seconds())))
+
+			if tightest.Allowed {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := int(tightest.RetryAfter.Seconds())
+			header.Set("Retry-After", strconv.Itoa(retryAfter))
+			writeStatusProblem(w, r, http.StatusTooManyRequests, fmt.Sprintf("too many requests, retry in %d seconds", retryAfter))
+		})
+	}
+}
+
+// tighter reports whether a leaves the client less room than b, refusals the most
+func tighter(a, b store.RateLimitResult) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+
+	return a.Remaining < b.Remaining
+}
+
+// clientIp is the address the request came from. Behind a reverse proxy, which appends
+// the address it got the request from to X-Forwarded-For, that last address is the client.
+func clientIp(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
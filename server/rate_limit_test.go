@@ -0,0 +1,97 @@
+package server_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/server"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := server.ParseRateLimits("POST  /api/auth/signin=ip:10/1m; /api/ticket=ip:60/1h, user:20/1h;")
+	require.NoError(t, err)
+	require.Equal(t, server.RateLimits{
+		"POST /api/auth/signin": {
+			{By: server.RateLimitByIp, RateLimit: store.RateLimit{Limit: 10, Window: time.Minute}},
+		},
+		"/api/ticket": {
+			{By: server.RateLimitByIp, RateLimit: store.RateLimit{Limit: 60, Window: time.Hour}},
+			{By: server.RateLimitByUser, RateLimit: store.RateLimit{Limit: 20, Window: time.Hour}},
+		},
+	}, limits)
+
+	for _, spec := range []string{
+		"POST /api/ticket",
+		"api/ticket=ip:1/1m",
+		"/api/ticket=host:1/1m",
+		"/api/ticket=ip:0/1m",
+		"/api/ticket=ip:1/forever",
+		"/api/ticket=ip:1/48h",
+	} {
+		_, err := server.ParseRateLimits(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limits, err := server.ParseRateLimits("POST /api/ticket=ip:3/1m,user:2/1m")
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	middleware := server.NewRateLimitMiddleware(store.NewMemoryRateLimiter(), limits, false, logger)
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	user := &store.User{Id: uuid.New()}
+	request := func(method string, signedIn bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/ticket", nil)
+		r.RemoteAddr = "10.0.0.1:5000"
+		if signedIn {
+			r = r.WithContext(server.WithUserContext(r.Context(), user))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// other methods are not limited
+	w := request(http.MethodGet, true)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+	// the user limit is the tighter one
+	w = request(http.MethodPost, true)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = request(http.MethodPost, true)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = request(http.MethodPost, true)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	// signed out requests from the same address only count against the ip limit, which
+	// the refused request above left untouched
+	w = request(http.MethodPost, false)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = request(http.MethodPost, false)
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type RateTicketRequest struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+func (req RateTicketRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Rating < store.MinRating || req.Rating > store.MaxRating {
+		errs.Add("rating", FieldInvalid, "rating must be between %d and %d", store.MinRating, store.MaxRating)
+	}
+
+	return errs.Err()
+}
+
+type GetRatingSummaryResponse struct {
+	GroupBy   store.ReportGroup     `json:"group_by"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Summaries []store.RatingSummary `json:"summaries"`
+}
+
+func ticketIdFromPath(r *http.Request) (uuid.UUID, error) {
+	ticketId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return uuid.Nil, NewApiError(http.StatusBadRequest, fmt.Errorf("invalid ticket id: %w", err))
+	}
+	return ticketId, nil
+}
+
+// rateTicketHandler lets the creator rate their ticket once it is done and until the csat window runs out
+func (s *Server) rateTicketHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		req, err := decode[RateTicketRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		ticket, err := s.ticketForUser(r.Context(), user, ticketId)
+		if err != nil {
+			return err
+		}
+
+		if ticket.Creator != user.Id {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("only the creator can rate a ticket"))
+		}
+
+		if ticket.Status != store.TicketStatusDone || ticket.ResolvedAt == nil {
+			return NewApiError(http.StatusConflict, fmt.Errorf("only resolved tickets can be rated")).WithCode(CodeTicketNotResolved)
+		}
+
+		if time.Since(*ticket.ResolvedAt) > s.Config.CsatWindow {
+			return NewApiError(http.StatusConflict, fmt.Errorf("the rating window for this ticket has closed")).WithCode(CodeRatingClosed)
+		}
+
+		rating, err := s.store.Rating.Create(r.Context(), ticket, req.Rating, req.Comment)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrAlreadyRated) {
+				status = http.StatusConflict
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketRating]](w, http.StatusCreated, ApiResponse[store.TicketRating]{
+			Data: rating,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getTicketRatingHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		rating, err := s.store.Rating.ByTicketId(r.Context(), ticketId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketRating]](w, http.StatusOK, ApiResponse[store.TicketRating]{
+			Data: rating,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// getRatingSummaryHandler aggregates ratings by assignee unless another group_by is given
+func (s *Server) getRatingSummaryHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		report, err := parseReportQuery(r)
+		if err != nil {
+			return err
+		}
+
+		if report.Group == store.ReportGroupNone {
+			report.Group = store.ReportGroupAssignee
+		}
+
+		summaries, err := s.store.Rating.Summary(r.Context(), report.Group, report.From, report.To)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetRatingSummaryResponse]](w, http.StatusOK, ApiResponse[GetRatingSummaryResponse]{
+			Data: &GetRatingSummaryResponse{
+				GroupBy:   report.Group,
+				From:      report.From,
+				To:        report.To,
+				Summaries: summaries,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
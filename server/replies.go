@@ -0,0 +1,187 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type EditReplyRequest struct {
+	Message string `json:"message"`
+}
+
+func (req EditReplyRequest) Validate() error {
+	var errs ValidationErrors
+	if strings.TrimSpace(req.Message) == "" {
+		errs.Add("message", FieldRequired, "message is required")
+	}
+
+	return errs.Err()
+}
+
+type GetReplyRevisionsResponse struct {
+	Revisions []store.ReplyRevision `json:"revisions"`
+}
+
+// replyForChange loads the reply named by the {replyId} path value on the ticket of the {id}
+// path value. Authors may change their replies within the edit window, admins any reply.
+func (s *Server) replyForChange(r *http.Request, user *store.User) (*store.TicketReply, error) {
+	ticketId, err := ticketIdFromPath(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+		return nil, err
+	}
+
+	replyId, err := uuid.Parse(r.PathValue("replyId"))
+	if err != nil {
+		return nil, NewApiError(http.StatusBadRequest, fmt.Errorf("invalid reply id: %w", err))
+	}
+
+	reply, err := s.store.TicketReply.ById(r.Context(), replyId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		return nil, NewApiError(status, err)
+	}
+
+	if reply.TicketId != ticketId {
+		return nil, NewApiError(http.StatusNotFound, fmt.Errorf("reply %v is not on ticket %v", reply.Id, ticketId))
+	}
+
+	if user.HasRole(store.RoleAdmin) {
+		return reply, nil
+	}
+
+	if reply.Creator != user.Id {
+		return nil, NewApiError(http.StatusForbidden, errors.New("only the author can change a reply"))
+	}
+
+	if time.Since(reply.CreatedAt) > s.Config.ReplyEditWindow {
+		return nil, NewApiError(http.StatusConflict, errors.New("the edit window for this reply has closed")).WithCode(CodeEditWindowClosed)
+	}
+
+	return reply, nil
+}
+
+func (s *Server) editReplyHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		reply, err := s.replyForChange(r, user)
+		if err != nil {
+			return err
+		}
+
+		req, err := decode[EditReplyRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		reply, err = s.store.TicketReply.Edit(r.Context(), reply.Id, user.Id, strings.TrimSpace(req.Message))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrReplyDeleted) {
+				status = http.StatusConflict
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[ReplyResponse]](w, http.StatusOK, ApiResponse[ReplyResponse]{
+			Data: newReplyResponse(reply),
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// deleteReplyHandler replaces the reply with a tombstone
+func (s *Server) deleteReplyHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		reply, err := s.replyForChange(r, user)
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.store.TicketReply.Delete(r.Context(), reply.Id, user.Id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrReplyDeleted) {
+				status = http.StatusConflict
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "reply has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getReplyRevisionsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		replyId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid reply id: %w", err))
+		}
+
+		revisions, err := s.store.TicketReply.Revisions(r.Context(), replyId)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetReplyRevisionsResponse]](w, http.StatusOK, ApiResponse[GetReplyRevisionsResponse]{
+			Data: &GetReplyRevisionsResponse{
+				Revisions: revisions,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) purgeReplyRevisionsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		replyId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid reply id: %w", err))
+		}
+
+		if _, err := s.store.TicketReply.PurgeRevisions(r.Context(), replyId, user.Id); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "reply revisions have been purged",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type RoutingRuleRequest struct {
+	Name           string               `json:"name"`
+	Position       int                  `json:"position"`
+	Enabled        bool                 `json:"enabled"`
+	StopProcessing bool                 `json:"stop_processing"`
+	Conditions     store.RuleConditions `json:"conditions"`
+	Actions        store.RuleActions    `json:"actions"`
+}
+
+func (req RoutingRuleRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req RoutingRuleRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if err := req.Conditions.Validate(); err != nil {
+		errs.Add("conditions", FieldInvalid, "%v", err)
+	}
+
+	if err := req.Actions.Validate(); err != nil {
+		errs.Add("actions", FieldInvalid, "%v", err)
+	}
+
+	return errs
+}
+
+func (req RoutingRuleRequest) params() store.RoutingRuleParams {
+	return store.RoutingRuleParams{
+		Name:           req.Name,
+		Position:       req.Position,
+		Enabled:        req.Enabled,
+		StopProcessing: req.StopProcessing,
+		Conditions:     req.Conditions,
+		Actions:        req.Actions,
+	}
+}
+
+type UpdateRoutingRuleRequest struct {
+	Id uuid.UUID `json:"id"`
+	RoutingRuleRequest
+}
+
+func (req UpdateRoutingRuleRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.RoutingRuleRequest.validate()...).Err()
+}
+
+type DeleteRoutingRuleRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteRoutingRuleRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+// SimulateRulesRequest describes a ticket that isn't created, only run through the rules
+type SimulateRulesRequest struct {
+	Title        string               `json:"title"`
+	Description  string               `json:"description"`
+	Category     string               `json:"category"`
+	Priority     store.TicketPriority `json:"priority"`
+	CreatorRoles store.UserRole       `json:"creator_roles"`
+	// At is when the ticket would be created, now by default. Rules match its time of day
+	// in the timezone of the hotel, as they do for tickets that are created.
+	At *time.Time `json:"at"`
+}
+
+func (req SimulateRulesRequest) Validate() error {
+	var errs ValidationErrors
+	if !req.Priority.WithinBounds() {
+		errs.Add("priority", FieldInvalid, "priority is invalid")
+	}
+
+	if req.CreatorRoles == 0 {
+		errs.Add("creator_roles", FieldRequired, "creator_roles is required")
+	}
+
+	return errs.Err()
+}
+
+type SimulatedRule struct {
+	Id   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type SimulateRulesResponse struct {
+	Matched  []SimulatedRule      `json:"matched"`
+	Assignee *uuid.UUID           `json:"assignee"`
+	Team     *uuid.UUID           `json:"team"`
+	Priority store.TicketPriority `json:"priority"`
+	Tags     []string             `json:"tags"`
+}
+
+type GetRoutingRulesResponse struct {
+	Rules []store.RoutingRule `json:"rules"`
+}
+
+func (s *Server) getRoutingRulesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		rules, err := s.store.RoutingRule.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetRoutingRulesResponse]](w, http.StatusOK, ApiResponse[GetRoutingRulesResponse]{
+			Data: &GetRoutingRulesResponse{
+				Rules: rules,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createRoutingRuleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[RoutingRuleRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		rule, err := s.store.RoutingRule.Create(r.Context(), req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.RoutingRule]](w, http.StatusCreated, ApiResponse[store.RoutingRule]{
+			Data: rule,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateRoutingRuleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateRoutingRuleRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		rule, err := s.store.RoutingRule.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.RoutingRule]](w, http.StatusOK, ApiResponse[store.RoutingRule]{
+			Data: rule,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteRoutingRuleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteRoutingRuleRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.RoutingRule.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "routing rule has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) simulateRoutingRulesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[SimulateRulesRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		rules, err := s.store.RoutingRule.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		at := time.Now()
+		if req.At != nil {
+			at = *req.At
+		}
+		at = at.In(s.Config.Location)
+
+		outcome := store.EvaluateRules(rules, store.RuleInput{
+			Title:        req.Title,
+			Description:  req.Description,
+			Category:     req.Category,
+			Priority:     req.Priority,
+			CreatorRoles: req.CreatorRoles,
+			At:           at,
+		})
+
+		res := &SimulateRulesResponse{
+			Matched:  []SimulatedRule{},
+			Priority: outcome.Priority,
+			Tags:     outcome.Tags,
+		}
+
+		for _, rule := range outcome.Matched {
+			res.Matched = append(res.Matched, SimulatedRule{Id: rule.Id, Name: rule.Name})
+		}
+
+		if outcome.Assignee.Valid {
+			res.Assignee = &outcome.Assignee.UUID
+		}
+
+		if outcome.Team.Valid {
+			res.Team = &outcome.Team.UUID
+		}
+
+		if err := encode[ApiResponse[SimulateRulesResponse]](w, http.StatusOK, ApiResponse[SimulateRulesResponse]{
+			Data: res,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
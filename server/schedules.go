@@ -0,0 +1,298 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type ScheduleRequest struct {
+	Name       string               `json:"name"`
+	TemplateId uuid.UUID            `json:"template_id"`
+	Variables  store.TemplateValues `json:"variables"`
+	Kind       string               `json:"kind"`
+	Expression string               `json:"expression"`
+	Timezone   string               `json:"timezone"`
+	// StartsAt defaults to now
+	StartsAt *time.Time `json:"starts_at"`
+	// CatchUp defaults to once
+	CatchUp  string                `json:"catch_up"`
+	Priority *store.TicketPriority `json:"priority"`
+	Assignee uuid.UUID             `json:"assignee"`
+	TeamId   uuid.UUID             `json:"team_id"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (req ScheduleRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req ScheduleRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if req.TemplateId == uuid.Nil {
+		errs.Add("template_id", FieldRequired, "template_id is required")
+	}
+
+	if req.CatchUp != "" && !slices.Contains(store.CatchUpPolicies, req.CatchUp) {
+		errs.Add("catch_up", FieldInvalid, "unknown catch_up %q, available policies are %v", req.CatchUp, store.CatchUpPolicies)
+	}
+
+	if req.Priority != nil && !req.Priority.WithinBounds() {
+		errs.Add("priority", FieldInvalid, "priority is invalid")
+	}
+
+	params := req.params()
+	if _, err := time.LoadLocation(params.Timezone); err != nil {
+		errs.Add("timezone", FieldInvalid, "unknown timezone %q", params.Timezone)
+		return errs
+	}
+
+	if !slices.Contains(store.ScheduleKinds, req.Kind) {
+		errs.Add("kind", FieldInvalid, "unknown kind %q, available kinds are %v", req.Kind, store.ScheduleKinds)
+		return errs
+	}
+
+	if _, err := store.ParseRecurrence(params.Kind, params.Expression, params.Timezone, params.StartsAt); err != nil {
+		errs.Add("expression", FieldInvalid, "%v", err)
+	}
+
+	return errs
+}
+
+// ValidateStore checks that the template can be rendered with the variables and that
+// the assignee and team exist
+func (req ScheduleRequest) ValidateStore(ctx context.Context, st *store.Store) error {
+	var errs ValidationErrors
+	template, err := st.Template.ById(ctx, req.TemplateId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if template == nil {
+		errs.Add("template_id", FieldNotFound, "template %v does not exist", req.TemplateId)
+	} else if _, _, err := template.Render(req.Variables); err != nil {
+		errs.Add("variables", FieldInvalid, "%v", err)
+	}
+
+	if req.Assignee != uuid.Nil {
+		assignee, err := st.User.ById(ctx, req.Assignee)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if assignee == nil {
+			errs.Add("assignee", FieldNotFound, "user %v does not exist", req.Assignee)
+		} else if !assignee.HasRole(store.RoleStaff | store.RoleAdmin) {
+			errs.Add("assignee", FieldInvalid, "only staff can be assigned tickets")
+		}
+	}
+
+	if req.TeamId != uuid.Nil {
+		if _, err := st.Team.ById(ctx, req.TeamId); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+			errs.Add("team_id", FieldNotFound, "team %v does not exist", req.TeamId)
+		}
+	}
+
+	return errs.Err()
+}
+
+func (req ScheduleRequest) params() store.TicketScheduleParams {
+	params := store.TicketScheduleParams{
+		Name:       req.Name,
+		TemplateId: req.TemplateId,
+		Variables:  req.Variables,
+		Kind:       req.Kind,
+		Expression: req.Expression,
+		Timezone:   req.Timezone,
+		StartsAt:   time.Now(),
+		CatchUp:    req.CatchUp,
+		Priority:   req.Priority,
+		Assignee:   req.Assignee,
+		TeamId:     req.TeamId,
+		Enabled:    true,
+	}
+
+	if req.Enabled != nil {
+		params.Enabled = *req.Enabled
+	}
+
+	if params.Timezone == "" {
+		params.Timezone = "UTC"
+	}
+
+	if req.StartsAt != nil {
+		params.StartsAt = *req.StartsAt
+	}
+
+	if params.CatchUp == "" {
+		params.CatchUp = store.CatchUpOnce
+	}
+
+	return params
+}
+
+type UpdateScheduleRequest struct {
+	Id uuid.UUID `json:"id"`
+	ScheduleRequest
+}
+
+func (req UpdateScheduleRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.ScheduleRequest.validate()...).Err()
+}
+
+type DeleteScheduleRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteScheduleRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetSchedulesResponse struct {
+	Schedules []store.TicketSchedule `json:"schedules"`
+}
+
+type GetScheduleRunsResponse struct {
+	Runs []store.TicketScheduleRun `json:"runs"`
+}
+
+const scheduleRunsLimit = 100
+
+func (s *Server) getSchedulesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		schedules, err := s.store.Schedule.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetSchedulesResponse]](w, http.StatusOK, ApiResponse[GetSchedulesResponse]{
+			Data: &GetSchedulesResponse{
+				Schedules: schedules,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createScheduleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decodeWithStore[ScheduleRequest](r, s.store)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		schedule, err := s.store.Schedule.Create(r.Context(), user.Id, req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketSchedule]](w, http.StatusCreated, ApiResponse[store.TicketSchedule]{
+			Data: schedule,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateScheduleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decodeWithStore[UpdateScheduleRequest](r, s.store)
+		if err != nil {
+			return err
+		}
+
+		schedule, err := s.store.Schedule.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketSchedule]](w, http.StatusOK, ApiResponse[store.TicketSchedule]{
+			Data: schedule,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteScheduleHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteScheduleRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.Schedule.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "schedule has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getScheduleRunsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		scheduleId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid schedule id: %w", err))
+		}
+
+		runs, err := s.store.Schedule.Runs(r.Context(), scheduleId, scheduleRunsLimit)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetScheduleRunsResponse]](w, http.StatusOK, ApiResponse[GetScheduleRunsResponse]{
+			Data: &GetScheduleRunsResponse{
+				Runs: runs,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -17,14 +17,21 @@ type Server struct {
 	logger     *slog.Logger
 	store      *store.Store
 	jwtManager *JwtManager
+	events     store.EventBus
+	limiter    store.RateLimiter
+	// closing is closed when the server shuts down so long lived streams let go
+	closing chan struct{}
 }
 
-func New(cfg *config.Config, logger *slog.Logger, store *store.Store, jwtManager *JwtManager) *Server {
+func New(cfg *config.Config, logger *slog.Logger, store *store.Store, jwtManager *JwtManager, events store.EventBus, limiter store.RateLimiter) *Server {
 	return &Server{
 		Config:     cfg,
 		logger:     logger,
 		store:      store,
 		jwtManager: jwtManager,
+		events:     events,
+		limiter:    limiter,
+		closing:    make(chan struct{}),
 	}
 }
 
@@ -33,9 +40,33 @@ func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("pong"))
 }
 
-func (s *Server) Start(ctx context.Context) error {
-	mux := http.NewServeMux()
+// router is a mux that remembers the patterns registered on it, so that they can be
+// checked against the api documentation
+type router struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (r *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	r.ServeMux.HandleFunc(pattern, handler)
+	r.patterns = append(r.patterns, pattern)
+}
+
+// Handler routes the requests to their handlers, without the middleware Start puts in
+// front of it, so it expects the user in the context of signed in requests
+func (s *Server) Handler() http.Handler {
+	return s.routes()
+}
+
+// Routes are the patterns of every route the server handles
+func (s *Server) Routes() []string {
+	return s.routes().patterns
+}
+
+func (s *Server) routes() *router {
+	mux := &router{ServeMux: http.NewServeMux()}
 	mux.HandleFunc("GET /ping", s.ping)
+	mux.HandleFunc("GET /api/openapi.json", s.openApiHandler())
 	// auth
 	mux.HandleFunc("POST /api/auth/signup", s.signUpHandler())
 	mux.HandleFunc("POST /api/auth/signin", s.signInHandler())
@@ -46,17 +77,120 @@ func (s *Server) Start(ctx context.Context) error {
 
 	mux.HandleFunc("POST /api/ticket", s.createTicketHandler())
 	mux.HandleFunc("PUT /api/ticket", s.updateTicketHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/history", s.getTicketHistoryHandler())
+	mux.HandleFunc("PUT /api/ticket/assign", s.assignTicketHandler()) // staff route
+	mux.HandleFunc("GET /api/events", s.streamEventsHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/chat", s.chatHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/watchers", s.getWatchersHandler())
+	mux.HandleFunc("POST /api/ticket/{id}/watchers", s.watchTicketHandler())
+	mux.HandleFunc("DELETE /api/ticket/{id}/watchers", s.unwatchTicketHandler())
+	mux.HandleFunc("PUT /api/ticket/{id}/replies/{replyId}", s.editReplyHandler())
+	mux.HandleFunc("DELETE /api/ticket/{id}/replies/{replyId}", s.deleteReplyHandler())
+	mux.HandleFunc("GET /api/admin/replies/{id}/revisions", s.getReplyRevisionsHandler())      // admin route
+	mux.HandleFunc("DELETE /api/admin/replies/{id}/revisions", s.purgeReplyRevisionsHandler()) // admin route
+	mux.HandleFunc("GET /api/ticket/{id}/mentions", s.getTicketMentionsHandler())
+	mux.HandleFunc("GET /api/mentions", s.getMentionedTicketsHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/attachments", s.getAttachmentsHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/attachments/{attachmentId}", s.downloadAttachmentHandler())
+	mux.HandleFunc("GET /api/notifications", s.getInboxHandler())
+	mux.HandleFunc("GET /api/notifications/unread", s.getUnreadCountHandler())
+	mux.HandleFunc("POST /api/notifications/read", s.markReadHandler())
+	mux.HandleFunc("POST /api/notifications/read-all", s.markAllReadHandler())
+	mux.HandleFunc("GET /api/notifications/preferences", s.getNotificationPreferencesHandler())
+	mux.HandleFunc("PUT /api/notifications/preferences", s.updateNotificationPreferencesHandler())
+	mux.HandleFunc("POST /api/inbound/email", s.inboundEmailHandler()) // authenticated with INBOUND_EMAIL_SECRET
+	// ratings
+	mux.HandleFunc("POST /api/ticket/{id}/rating", s.rateTicketHandler())
+	mux.HandleFunc("GET /api/ticket/{id}/rating", s.getTicketRatingHandler())
+	mux.HandleFunc("GET /api/admin/ratings/summary", s.getRatingSummaryHandler()) // admin route
+	// metrics, admin routes
+	mux.HandleFunc("GET /api/admin/metrics/volume", s.getVolumeHandler())
+	mux.HandleFunc("GET /api/admin/metrics/response-times", s.getResponseTimesHandler())
+	mux.HandleFunc("GET /api/admin/metrics/backlog", s.getBacklogHandler())
+	mux.HandleFunc("GET /api/admin/metrics/work", s.getWorkTotalsHandler())
+	// time tracking, staff only
+	mux.HandleFunc("GET /api/ticket/{id}/worklogs", s.getWorkLogsHandler())
+	mux.HandleFunc("POST /api/ticket/{id}/worklogs", s.logWorkHandler())
+	mux.HandleFunc("DELETE /api/ticket/{id}/worklogs/{logId}", s.deleteWorkLogHandler())
+	mux.HandleFunc("POST /api/ticket/{id}/timer", s.startTimerHandler())
+	mux.HandleFunc("GET /api/staff/timer", s.getTimerHandler())
+	mux.HandleFunc("POST /api/staff/timer/stop", s.stopTimerHandler())
+	// webhooks, admin routes
+	mux.HandleFunc("GET /api/admin/webhooks", s.getWebhooksHandler())
+	mux.HandleFunc("POST /api/admin/webhooks", s.createWebhookHandler())
+	mux.HandleFunc("PUT /api/admin/webhooks", s.updateWebhookHandler())
+	mux.HandleFunc("DELETE /api/admin/webhooks", s.deleteWebhookHandler())
+	mux.HandleFunc("GET /api/admin/webhooks/{id}/deliveries", s.getWebhookDeliveriesHandler())
+	mux.HandleFunc("POST /api/admin/webhooks/deliveries/replay", s.replayWebhookDeliveryHandler())
+	// templates
+	mux.HandleFunc("GET /api/templates", s.getAllTemplatesHandler())
+	mux.HandleFunc("POST /api/admin/templates", s.createTemplateHandler())   // admin route
+	mux.HandleFunc("PUT /api/admin/templates", s.updateTemplateHandler())    // admin route
+	mux.HandleFunc("DELETE /api/admin/templates", s.deleteTemplateHandler()) // admin route
+	// ticket schedules, admin routes
+	mux.HandleFunc("GET /api/admin/schedules", s.getSchedulesHandler())
+	mux.HandleFunc("POST /api/admin/schedules", s.createScheduleHandler())
+	mux.HandleFunc("PUT /api/admin/schedules", s.updateScheduleHandler())
+	mux.HandleFunc("DELETE /api/admin/schedules", s.deleteScheduleHandler())
+	mux.HandleFunc("GET /api/admin/schedules/{id}/runs", s.getScheduleRunsHandler())
+	// custom fields
+	mux.HandleFunc("GET /api/fields", s.getCustomFieldsHandler())
+	mux.HandleFunc("POST /api/admin/fields", s.createCustomFieldHandler())   // admin route
+	mux.HandleFunc("PUT /api/admin/fields", s.updateCustomFieldHandler())    // admin route
+	mux.HandleFunc("DELETE /api/admin/fields", s.deleteCustomFieldHandler()) // admin route
+	// routing rules
+	mux.HandleFunc("GET /api/admin/rules", s.getRoutingRulesHandler())                // admin route
+	mux.HandleFunc("POST /api/admin/rules", s.createRoutingRuleHandler())             // admin route
+	mux.HandleFunc("PUT /api/admin/rules", s.updateRoutingRuleHandler())              // admin route
+	mux.HandleFunc("DELETE /api/admin/rules", s.deleteRoutingRuleHandler())           // admin route
+	mux.HandleFunc("POST /api/admin/rules/simulate", s.simulateRoutingRulesHandler()) // admin route
+	// assignment queues
+	mux.HandleFunc("GET /api/admin/queues", s.getAssignmentQueuesHandler())      // admin route
+	mux.HandleFunc("POST /api/admin/queues", s.createAssignmentQueueHandler())   // admin route
+	mux.HandleFunc("PUT /api/admin/queues", s.updateAssignmentQueueHandler())    // admin route
+	mux.HandleFunc("DELETE /api/admin/queues", s.deleteAssignmentQueueHandler()) // admin route
+	mux.HandleFunc("PUT /api/staff/availability", s.updateAvailabilityHandler()) // staff route
+	// teams, staff routes
+	mux.HandleFunc("GET /api/teams", s.getTeamsHandler())
+	mux.HandleFunc("GET /api/teams/{id}/members", s.getTeamMembersHandler())
+	mux.HandleFunc("POST /api/teams/{id}/members", s.addTeamMemberHandler())
+	mux.HandleFunc("DELETE /api/teams/{id}/members", s.removeTeamMemberHandler())
+	mux.HandleFunc("GET /api/teams/{id}/tickets", s.getTeamTicketsHandler())
+	mux.HandleFunc("POST /api/admin/teams", s.createTeamHandler())   // admin route
+	mux.HandleFunc("PUT /api/admin/teams", s.updateTeamHandler())    // admin route
+	mux.HandleFunc("DELETE /api/admin/teams", s.deleteTeamHandler()) // admin route
+	// macros, staff routes
+	mux.HandleFunc("GET /api/macros", s.getMacrosHandler())
+	mux.HandleFunc("POST /api/macros", s.createMacroHandler())
+	mux.HandleFunc("PUT /api/macros", s.updateMacroHandler())
+	mux.HandleFunc("DELETE /api/macros", s.deleteMacroHandler())
+	mux.HandleFunc("POST /api/macros/apply", s.applyMacroHandler())
+
+	return mux
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	mux := s.routes()
+
+	rateLimits, err := ParseRateLimits(s.Config.RateLimits)
+	if err != nil {
+		return err
+	}
 
 	middlewareLogger := NewLoggerMiddleware(s.logger)
 	middlewareAuth := NewAuthMiddleware(s.jwtManager, s.store.User)
+	middlewareRateLimit := NewRateLimitMiddleware(s.limiter, rateLimits, s.Config.TrustProxy, s.logger)
 	middlewarePerms := NewPermissionsMiddleware()
 
-	middleware := middlewareLogger(middlewareAuth(middlewarePerms(mux)))
+	middleware := middlewareLogger(middlewareAuth(middlewareRateLimit(middlewarePerms(mux))))
 
 	server := &http.Server{
 		Addr:    net.JoinHostPort(s.Config.ServerHost, s.Config.ServerPort),
 		Handler: middleware,
 	}
+	server.RegisterOnShutdown(func() {
+		close(s.closing)
+	})
 
 	go func() {
 		s.logger.Info("server is running on", "port", s.Config.ServerPort)
@@ -0,0 +1,385 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type TeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (req TeamRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req TeamRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	return errs
+}
+
+type UpdateTeamRequest struct {
+	Id uuid.UUID `json:"id"`
+	TeamRequest
+}
+
+func (req UpdateTeamRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.TeamRequest.validate()...).Err()
+}
+
+type DeleteTeamRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteTeamRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type TeamMemberRequest struct {
+	UserId uuid.UUID `json:"user_id"`
+	Lead   bool      `json:"lead"`
+}
+
+func (req TeamMemberRequest) Validate() error {
+	var errs ValidationErrors
+	if req.UserId == uuid.Nil {
+		errs.Add("user_id", FieldRequired, "user_id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetTeamsResponse struct {
+	Teams []store.Team `json:"teams"`
+}
+
+type GetTeamMembersResponse struct {
+	Members []store.TeamMember `json:"members"`
+}
+
+type GetTeamTicketsResponse struct {
+	Tickets []TicketResponse `json:"tickets"`
+}
+
+// teamFromPath loads the team named by the {id} path value and checks the user is a member,
+// admins pass for every team. With leadOnly set plain members are refused as well.
+func (s *Server) teamFromPath(r *http.Request, user *store.User, leadOnly bool) (*store.Team, error) {
+	teamId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		return nil, NewApiError(http.StatusBadRequest, fmt.Errorf("invalid team id: %w", err))
+	}
+
+	team, err := s.store.Team.ById(r.Context(), teamId)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, sql.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		return nil, NewApiError(status, err)
+	}
+
+	if user.HasRole(store.RoleAdmin) {
+		return team, nil
+	}
+
+	member, lead, err := s.store.Team.Membership(r.Context(), teamId, user.Id)
+	if err != nil {
+		return nil, NewApiError(http.StatusInternalServerError, err)
+	}
+
+	if !member || (leadOnly && !lead) {
+		return nil, NewApiError(http.StatusForbidden, fmt.Errorf("you are not allowed to access this team"))
+	}
+
+	return team, nil
+}
+
+func (s *Server) getTeamsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		var teams []store.Team
+		var err error
+
+		user := s.getUserFromContext(r.Context())
+		if r.URL.Query().Get("mine") == "true" {
+			teams, err = s.store.Team.ByUser(r.Context(), user.Id)
+		} else {
+			teams, err = s.store.Team.All(r.Context())
+		}
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetTeamsResponse]](w, http.StatusOK, ApiResponse[GetTeamsResponse]{
+			Data: &GetTeamsResponse{
+				Teams: teams,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createTeamHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[TeamRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		team, err := s.store.Team.Create(r.Context(), req.Name, req.Description)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.Team]](w, http.StatusCreated, ApiResponse[store.Team]{
+			Data: team,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateTeamHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateTeamRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		team, err := s.store.Team.Update(r.Context(), req.Id, req.Name, req.Description)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.Team]](w, http.StatusOK, ApiResponse[store.Team]{
+			Data: team,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteTeamHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteTeamRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.Team.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "team has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getTeamMembersHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		team, err := s.teamFromPath(r, user, false)
+		if err != nil {
+			return err
+		}
+
+		members, err := s.store.Team.Members(r.Context(), team.Id)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetTeamMembersResponse]](w, http.StatusOK, ApiResponse[GetTeamMembersResponse]{
+			Data: &GetTeamMembersResponse{
+				Members: members,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// changeMember refuses team leads changing who else leads the team, leads and admins
+// are only added, demoted or removed by admins
+func (s *Server) changeMember(ctx context.Context, user *store.User, teamId uuid.UUID, member *store.User) error {
+	if user.HasRole(store.RoleAdmin) {
+		return nil
+	}
+
+	if member.HasRole(store.RoleAdmin) {
+		return NewApiError(http.StatusForbidden, fmt.Errorf("only admins can change the membership of admins"))
+	}
+
+	_, lead, err := s.store.Team.Membership(ctx, teamId, member.Id)
+	if err != nil {
+		return NewApiError(http.StatusInternalServerError, err)
+	}
+
+	if lead {
+		return NewApiError(http.StatusForbidden, fmt.Errorf("only admins can change team leads"))
+	}
+
+	return nil
+}
+
+// addTeamMemberHandler is open to admins and team leads, only admins can appoint or demote leads
+func (s *Server) addTeamMemberHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[TeamMemberRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		team, err := s.teamFromPath(r, user, true)
+		if err != nil {
+			return err
+		}
+
+		if req.Lead && !user.HasRole(store.RoleAdmin) {
+			return NewApiError(http.StatusForbidden, fmt.Errorf("only admins can appoint team leads"))
+		}
+
+		member, err := s.store.User.ById(r.Context(), req.UserId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusBadRequest
+			}
+			return NewApiError(status, err)
+		}
+
+		if !member.HasRole(store.RoleStaff | store.RoleAdmin) {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("only staff can be team members"))
+		}
+
+		if err := s.changeMember(r.Context(), user, team.Id, member); err != nil {
+			return err
+		}
+
+		if err := s.store.Team.AddMember(r.Context(), team.Id, member.Id, req.Lead); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "member has been added to the team",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// removeTeamMemberHandler is open to admins and team leads, only admins can remove leads and admins
+func (s *Server) removeTeamMemberHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[TeamMemberRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		team, err := s.teamFromPath(r, user, true)
+		if err != nil {
+			return err
+		}
+
+		member, err := s.store.User.ById(r.Context(), req.UserId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusBadRequest
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := s.changeMember(r.Context(), user, team.Id, member); err != nil {
+			return err
+		}
+
+		if err := s.store.Team.RemoveMember(r.Context(), team.Id, req.UserId); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "member has been removed from the team",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getTeamTicketsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		team, err := s.teamFromPath(r, user, false)
+		if err != nil {
+			return err
+		}
+
+		filter, err := ticketFilter(r.URL.Query())
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+		filter.TeamId = team.Id
+
+		tickets, err := s.store.Ticket.All(r.Context(), filter)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetTeamTicketsResponse]](w, http.StatusOK, ApiResponse[GetTeamTicketsResponse]{
+			Data: &GetTeamTicketsResponse{
+				Tickets: newTicketResponses(tickets),
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/server"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTeamMemberHandlers(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	st := store.New(env.Db, time.UTC)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := server.New(env.Config, logger, st, nil, nil, nil).Handler()
+
+	user := func(email string, role store.UserRole) *store.User {
+		user, err := st.User.CreateUser(ctx, email, "test")
+		require.NoError(t, err)
+		user.AddRole(role)
+		user, err = st.User.UpdateUserById(ctx, user.Id, user.Email, user.Roles)
+		require.NoError(t, err)
+		return user
+	}
+
+	lead := user("lead@hotel.com", store.RoleStaff)
+	coLead := user("colead@hotel.com", store.RoleStaff)
+	member := user("member@hotel.com", store.RoleStaff)
+	admin := user("admin@hotel.com", store.RoleAdmin)
+
+	team, err := st.Team.Create(ctx, "front desk", "")
+	require.NoError(t, err)
+	require.NoError(t, st.Team.AddMember(ctx, team.Id, lead.Id, true))
+	require.NoError(t, st.Team.AddMember(ctx, team.Id, coLead.Id, true))
+	require.NoError(t, st.Team.AddMember(ctx, team.Id, member.Id, false))
+	require.NoError(t, st.Team.AddMember(ctx, team.Id, admin.Id, false))
+
+	request := func(method string, actor *store.User, body string) int {
+		r := httptest.NewRequest(method, fmt.Sprintf("/api/teams/%s/members", team.Id), strings.NewReader(body))
+		r = r.WithContext(server.WithUserContext(r.Context(), actor))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// leads can't demote or remove their co-leads, nor remove admins
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, lead, fmt.Sprintf(`{"user_id": %q, "lead": false}`, coLead.Id)))
+	require.Equal(t, http.StatusForbidden, request(http.MethodDelete, lead, fmt.Sprintf(`{"user_id": %q}`, coLead.Id)))
+	require.Equal(t, http.StatusForbidden, request(http.MethodDelete, lead, fmt.Sprintf(`{"user_id": %q}`, admin.Id)))
+
+	_, isLead, err := st.Team.Membership(ctx, team.Id, coLead.Id)
+	require.NoError(t, err)
+	require.True(t, isLead)
+
+	// the other members are theirs to manage
+	require.Equal(t, http.StatusOK, request(http.MethodDelete, lead, fmt.Sprintf(`{"user_id": %q}`, member.Id)))
+	require.Equal(t, http.StatusOK, request(http.MethodPost, lead, fmt.Sprintf(`{"user_id": %q, "lead": false}`, member.Id)))
+
+	// admins change leads as they like
+	require.Equal(t, http.StatusOK, request(http.MethodPost, admin, fmt.Sprintf(`{"user_id": %q, "lead": false}`, coLead.Id)))
+
+	_, isLead, err = st.Team.Membership(ctx, team.Id, coLead.Id)
+	require.NoError(t, err)
+	require.False(t, isLead)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type TemplateRequest struct {
+	Name         string               `json:"name"`
+	TitlePattern string               `json:"title_pattern"`
+	Description  string               `json:"description"`
+	Priority     store.TicketPriority `json:"priority"`
+	Category     string               `json:"category"`
+	Variables    []string             `json:"variables"`
+}
+
+func (req TemplateRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req TemplateRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	if req.Name == "" {
+		errs.Add("name", FieldRequired, "name is required")
+	}
+
+	if req.TitlePattern == "" {
+		errs.Add("title_pattern", FieldRequired, "title_pattern is required")
+	}
+
+	if req.Description == "" {
+		errs.Add("description", FieldRequired, "description is required")
+	}
+
+	if !req.Priority.WithinBounds() {
+		errs.Add("priority", FieldInvalid, "priority is invalid")
+	}
+
+	placeholders := store.TemplatePlaceholders(req.TitlePattern + "\n" + req.Description)
+	for _, placeholder := range placeholders {
+		if !slices.Contains(req.Variables, placeholder) {
+			errs.Add("variables", FieldRequired, "placeholder %q is not declared in variables", placeholder)
+		}
+	}
+
+	for _, variable := range req.Variables {
+		if !slices.Contains(placeholders, variable) {
+			errs.Add("variables", FieldUnknown, "variable %q is not used in the title_pattern or description", variable)
+		}
+	}
+
+	return errs
+}
+
+func (req TemplateRequest) params() store.TicketTemplateParams {
+	return store.TicketTemplateParams{
+		Name:         req.Name,
+		TitlePattern: req.TitlePattern,
+		Description:  req.Description,
+		Priority:     req.Priority,
+		Category:     req.Category,
+		Variables:    req.Variables,
+	}
+}
+
+type UpdateTemplateRequest struct {
+	Id uuid.UUID `json:"id"`
+	TemplateRequest
+}
+
+func (req UpdateTemplateRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.TemplateRequest.validate()...).Err()
+}
+
+type DeleteTemplateRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteTemplateRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetAllTemplatesResponse struct {
+	Templates []store.TicketTemplate `json:"templates"`
+}
+
+func (s *Server) getAllTemplatesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		templates, err := s.store.Template.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetAllTemplatesResponse]](w, http.StatusOK, ApiResponse[GetAllTemplatesResponse]{
+			Data: &GetAllTemplatesResponse{
+				Templates: templates,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createTemplateHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[TemplateRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		user := s.getUserFromContext(r.Context())
+
+		template, err := s.store.Template.Create(r.Context(), user.Id, req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketTemplate]](w, http.StatusCreated, ApiResponse[store.TicketTemplate]{
+			Data: template,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateTemplateHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateTemplateRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		template, err := s.store.Template.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.TicketTemplate]](w, http.StatusOK, ApiResponse[store.TicketTemplate]{
+			Data: template,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteTemplateHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteTemplateRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.Template.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "template has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type GetWatchersResponse struct {
+	Watchers []store.TicketWatcher `json:"watchers"`
+}
+
+// getWatchersHandler lists the watchers of a ticket, only staff and admins get the email
+// addresses of the others
+func (s *Server) getWatchersHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		watchers, err := s.store.Notification.Watchers(r.Context(), ticketId)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		// guests see who watches their ticket, not how to reach them
+		if !user.HasRole(store.RoleStaff | store.RoleAdmin) {
+			for i := range watchers {
+				if watchers[i].UserId != user.Id {
+					watchers[i].Email = ""
+				}
+			}
+		}
+
+		if err := encode[ApiResponse[GetWatchersResponse]](w, http.StatusOK, ApiResponse[GetWatchersResponse]{
+			Data: &GetWatchersResponse{
+				Watchers: watchers,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// watchTicketHandler subscribes the user to the email notifications of a ticket they can see
+func (s *Server) watchTicketHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if _, err := s.ticketForUser(r.Context(), user, ticketId); err != nil {
+			return err
+		}
+
+		if err := s.store.Notification.Watch(r.Context(), ticketId, user.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "you are now watching this ticket",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) unwatchTicketHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		ticketId, err := ticketIdFromPath(r)
+		if err != nil {
+			return err
+		}
+
+		user := s.getUserFromContext(r.Context())
+		if err := s.store.Notification.Unwatch(r.Context(), ticketId, user.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "you are no longer watching this ticket",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
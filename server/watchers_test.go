@@ -0,0 +1,78 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/server"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestGetWatchersHandler(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	st := store.New(env.Db, time.UTC)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := server.New(env.Config, logger, st, nil, nil, nil).Handler()
+
+	guest, err := st.User.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := st.User.CreateUser(ctx, "staff@hotel.com", "test")
+	require.NoError(t, err)
+	staff.AddRole(store.RoleStaff)
+	staff, err = st.User.UpdateUserById(ctx, staff.Id, staff.Email, staff.Roles)
+	require.NoError(t, err)
+
+	ticket, err := st.Ticket.Create(ctx, store.CreateTicketParams{
+		Title:       "noisy neighbours",
+		Description: "room 305 is having a party",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, st.Notification.Watch(ctx, ticket.Id, guest.Id))
+	require.NoError(t, st.Notification.Watch(ctx, ticket.Id, staff.Id))
+
+	watchers := func(user *store.User) map[string]string {
+		r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/ticket/%s/watchers", ticket.Id), nil)
+		r = r.WithContext(server.WithUserContext(r.Context(), user))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response server.ApiResponse[server.GetWatchersResponse]
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+		emails := map[string]string{}
+		for _, watcher := range response.Data.Watchers {
+			emails[watcher.UserId.String()] = watcher.Email
+		}
+		return emails
+	}
+
+	// the guest only gets their own address
+	emails := watchers(guest)
+	require.Equal(t, "guest@test.com", emails[guest.Id.String()])
+	require.Contains(t, emails, staff.Id.String())
+	require.Empty(t, emails[staff.Id.String()])
+
+	emails = watchers(staff)
+	require.Equal(t, "guest@test.com", emails[guest.Id.String()])
+	require.Equal(t, "staff@hotel.com", emails[staff.Id.String()])
+}
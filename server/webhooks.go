@@ -0,0 +1,239 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type WebhookRequest struct {
+	Url        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Enabled    bool     `json:"enabled"`
+}
+
+func (req WebhookRequest) Validate() error {
+	return req.validate().Err()
+}
+
+func (req WebhookRequest) validate() ValidationErrors {
+	var errs ValidationErrors
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.Add("url", FieldInvalid, "url must be an absolute http or https url")
+	}
+
+	if len(req.EventTypes) == 0 {
+		errs.Add("event_types", FieldRequired, "event_types is required")
+	}
+
+	for _, eventType := range req.EventTypes {
+		if !slices.Contains(store.WebhookEventTypes, eventType) {
+			errs.Add("event_types", FieldUnknown, "unknown event type %q, available event types are %v", eventType, store.WebhookEventTypes)
+		}
+	}
+
+	return errs
+}
+
+func (req WebhookRequest) params() store.WebhookParams {
+	return store.WebhookParams{
+		Url:        req.Url,
+		EventTypes: req.EventTypes,
+		Enabled:    req.Enabled,
+	}
+}
+
+type UpdateWebhookRequest struct {
+	Id uuid.UUID `json:"id"`
+	WebhookRequest
+}
+
+func (req UpdateWebhookRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return append(errs, req.WebhookRequest.validate()...).Err()
+}
+
+type DeleteWebhookRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req DeleteWebhookRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type ReplayDeliveryRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+func (req ReplayDeliveryRequest) Validate() error {
+	var errs ValidationErrors
+	if req.Id == uuid.Nil {
+		errs.Add("id", FieldRequired, "id is required")
+	}
+
+	return errs.Err()
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []store.Webhook `json:"webhooks"`
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []store.WebhookDelivery `json:"deliveries"`
+}
+
+const webhookDeliveriesLimit = 100
+
+func (s *Server) getWebhooksHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		webhooks, err := s.store.Webhook.All(r.Context())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetWebhooksResponse]](w, http.StatusOK, ApiResponse[GetWebhooksResponse]{
+			Data: &GetWebhooksResponse{
+				Webhooks: webhooks,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) createWebhookHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[WebhookRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		webhook, err := s.store.Webhook.Create(r.Context(), req.params())
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.Webhook]](w, http.StatusCreated, ApiResponse[store.Webhook]{
+			Data: webhook,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) updateWebhookHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[UpdateWebhookRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		webhook, err := s.store.Webhook.Update(r.Context(), req.Id, req.params())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.Webhook]](w, http.StatusOK, ApiResponse[store.Webhook]{
+			Data: webhook,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) deleteWebhookHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[DeleteWebhookRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		if err := s.store.Webhook.Delete(r.Context(), req.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "webhook has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getWebhookDeliveriesHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		webhookId, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid webhook id: %w", err))
+		}
+
+		deliveries, err := s.store.Webhook.Deliveries(r.Context(), webhookId, webhookDeliveriesLimit)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetWebhookDeliveriesResponse]](w, http.StatusOK, ApiResponse[GetWebhookDeliveriesResponse]{
+			Data: &GetWebhookDeliveriesResponse{
+				Deliveries: deliveries,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) replayWebhookDeliveryHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		req, err := decode[ReplayDeliveryRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		delivery, err := s.store.Webhook.Replay(r.Context(), req.Id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.WebhookDelivery]](w, http.StatusCreated, ApiResponse[store.WebhookDelivery]{
+			Data: delivery,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
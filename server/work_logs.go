@@ -0,0 +1,316 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+type LogWorkRequest struct {
+	// Duration is a Go duration such as 1h30m
+	Duration string `json:"duration"`
+	Note     string `json:"note"`
+	// Date is the day the work was done as YYYY-MM-DD, today by default
+	Date string `json:"date"`
+}
+
+func (req LogWorkRequest) Validate() error {
+	var errs ValidationErrors
+	duration, err := time.ParseDuration(req.Duration)
+	switch {
+	case req.Duration == "":
+		errs.Add("duration", FieldRequired, "duration is required")
+	case err != nil:
+		errs.Add("duration", FieldInvalid, "duration must be a duration such as 1h30m: %v", err)
+	case duration < time.Second:
+		errs.Add("duration", FieldInvalid, "duration must be at least a second")
+	case duration > 24*time.Hour:
+		errs.Add("duration", FieldInvalid, "duration can't be longer than a day")
+	}
+
+	if req.Date != "" {
+		if _, err := time.Parse(time.DateOnly, req.Date); err != nil {
+			errs.Add("date", FieldInvalid, "date must be formatted as YYYY-MM-DD")
+		}
+	}
+
+	return errs.Err()
+}
+
+func (req LogWorkRequest) params(ticketId, userId uuid.UUID) store.WorkLogParams {
+	params := store.WorkLogParams{
+		TicketId: ticketId,
+		UserId:   userId,
+		Note:     req.Note,
+		WorkedOn: time.Now(),
+	}
+
+	params.Duration, _ = time.ParseDuration(req.Duration)
+	if req.Date != "" {
+		params.WorkedOn, _ = time.Parse(time.DateOnly, req.Date)
+	}
+
+	return params
+}
+
+type StopTimerRequest struct {
+	Note string `json:"note"`
+}
+
+func (req StopTimerRequest) Validate() error {
+	return nil
+}
+
+type GetWorkLogsResponse struct {
+	WorkLogs []store.WorkLog `json:"work_logs"`
+	// Seconds is the total time logged on the ticket
+	Seconds int `json:"seconds"`
+}
+
+type GetWorkTotalsResponse struct {
+	GroupBy store.WorkGroup   `json:"group_by"`
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Totals  []store.WorkTotal `json:"totals"`
+}
+
+// staffTicketFromPath loads the ticket named by the {id} path value for staff who can
+// access it, work logs are internal to the staff
+func (s *Server) staffTicketFromPath(r *http.Request, user *store.User) (*store.Ticket, error) {
+	if !user.HasRole(store.RoleStaff | store.RoleAdmin) {
+		return nil, NewApiError(http.StatusForbidden, errors.New("only staff can track time on tickets"))
+	}
+
+	ticketId, err := ticketIdFromPath(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ticketForUser(r.Context(), user, ticketId)
+}
+
+func (s *Server) getWorkLogsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		ticket, err := s.staffTicketFromPath(r, user)
+		if err != nil {
+			return err
+		}
+
+		logs, err := s.store.WorkLog.ByTicketId(r.Context(), ticket.Id)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		response := &GetWorkLogsResponse{
+			WorkLogs: logs,
+		}
+		for _, log := range logs {
+			response.Seconds += log.Seconds
+		}
+
+		if err := encode[ApiResponse[GetWorkLogsResponse]](w, http.StatusOK, ApiResponse[GetWorkLogsResponse]{
+			Data: response,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) logWorkHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		ticket, err := s.staffTicketFromPath(r, user)
+		if err != nil {
+			return err
+		}
+
+		req, err := decode[LogWorkRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		log, err := s.store.WorkLog.Log(r.Context(), req.params(ticket.Id, user.Id))
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[store.WorkLog]](w, http.StatusCreated, ApiResponse[store.WorkLog]{
+			Data: log,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// deleteWorkLogHandler removes a work log of the ticket, staff remove their own and admins any
+func (s *Server) deleteWorkLogHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		ticket, err := s.staffTicketFromPath(r, user)
+		if err != nil {
+			return err
+		}
+
+		logId, err := uuid.Parse(r.PathValue("logId"))
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, fmt.Errorf("invalid work log id: %w", err))
+		}
+
+		log, err := s.store.WorkLog.ById(r.Context(), logId)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if log.TicketId != ticket.Id {
+			return NewApiError(http.StatusNotFound, fmt.Errorf("work log %v is not on ticket %v", log.Id, ticket.Id))
+		}
+
+		if log.UserId != user.Id && !user.HasRole(store.RoleAdmin) {
+			return NewApiError(http.StatusForbidden, errors.New("you can only delete your own work logs"))
+		}
+
+		if err := s.store.WorkLog.Delete(r.Context(), log.Id); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[struct{}]](w, http.StatusOK, ApiResponse[struct{}]{
+			Message: "work log has been deleted",
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) getTimerHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		timer, err := s.store.WorkLog.Timer(r.Context(), user.Id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.WorkTimer]](w, http.StatusOK, ApiResponse[store.WorkTimer]{
+			Data: timer,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+func (s *Server) startTimerHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		ticket, err := s.staffTicketFromPath(r, user)
+		if err != nil {
+			return err
+		}
+
+		timer, err := s.store.WorkLog.StartTimer(r.Context(), user.Id, ticket.Id, time.Now())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrTimerRunning) {
+				status = http.StatusConflict
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.WorkTimer]](w, http.StatusCreated, ApiResponse[store.WorkTimer]{
+			Data: timer,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// stopTimerHandler stops the caller's running timer, wherever it was started, and logs the time
+func (s *Server) stopTimerHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		user := s.getUserFromContext(r.Context())
+
+		req, err := decode[StopTimerRequest](r)
+		if err != nil {
+			return NewApiError(http.StatusBadRequest, err)
+		}
+
+		log, err := s.store.WorkLog.StopTimer(r.Context(), user.Id, req.Note, time.Now())
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, sql.ErrNoRows) {
+				status = http.StatusNotFound
+			}
+			return NewApiError(status, err)
+		}
+
+		if err := encode[ApiResponse[store.WorkLog]](w, http.StatusCreated, ApiResponse[store.WorkLog]{
+			Data: log,
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
+
+// getWorkTotalsHandler reports the time logged per ?group_by=ticket|user|category over ?from= and ?to=
+func (s *Server) getWorkTotalsHandler() http.HandlerFunc {
+	return handler(func(w http.ResponseWriter, r *http.Request) error {
+		query := r.URL.Query()
+
+		group := store.WorkGroup(query.Get("group_by"))
+		if !group.Valid() {
+			return NewApiError(http.StatusBadRequest, errors.New("group_by must be ticket, user or category"))
+		}
+
+		from, to, err := parseReportRange(query)
+		if err != nil {
+			return err
+		}
+
+		totals, err := s.store.WorkLog.Totals(r.Context(), group, from, to)
+		if err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		if err := encode[ApiResponse[GetWorkTotalsResponse]](w, http.StatusOK, ApiResponse[GetWorkTotalsResponse]{
+			Data: &GetWorkTotalsResponse{
+				GroupBy: group,
+				From:    from,
+				To:      to,
+				Totals:  totals,
+			},
+		}); err != nil {
+			return NewApiError(http.StatusInternalServerError, err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type AssignmentStrategy string
+
+const (
+	// StrategyRoundRobin hands tickets to the available staff in turn
+	StrategyRoundRobin AssignmentStrategy = "round_robin"
+	// StrategyLeastOpen hands tickets to the available staff member with the fewest open tickets
+	StrategyLeastOpen AssignmentStrategy = "least_open"
+)
+
+func (s AssignmentStrategy) Valid() bool {
+	return s == StrategyRoundRobin || s == StrategyLeastOpen
+}
+
+const HistoryAutoAssigned = "auto_assigned"
+
+type AssignmentQueueStore struct {
+	db *sqlx.DB
+}
+
+func NewAssignmentQueueStore(db *sql.DB) *AssignmentQueueStore {
+	return &AssignmentQueueStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// AssignmentQueue assigns new unassigned tickets of its category to available staff,
+// a queue without category takes the tickets no other queue matched. A queue that
+// belongs to a team only assigns to the team members and puts the ticket in the team.
+type AssignmentQueue struct {
+	Id           uuid.UUID          `db:"id"`
+	Name         string             `db:"name"`
+	Category     string             `db:"category"`
+	TeamId       uuid.UUID          `db:"team_id"`
+	Strategy     AssignmentStrategy `db:"strategy"`
+	Enabled      bool               `db:"enabled"`
+	LastAssignee uuid.UUID          `db:"last_assignee"`
+	CreatedAt    time.Time          `db:"created_at"`
+	UpdatedAt    time.Time          `db:"updated_at"`
+}
+
+type AssignmentQueueParams struct {
+	Name     string
+	Category string
+	TeamId   uuid.UUID
+	Strategy AssignmentStrategy
+	Enabled  bool
+}
+
+func (p AssignmentQueueParams) team() uuid.NullUUID {
+	return uuid.NullUUID{UUID: p.TeamId, Valid: p.TeamId != uuid.Nil}
+}
+
+func (s *AssignmentQueueStore) Create(ctx context.Context, params AssignmentQueueParams) (*AssignmentQueue, error) {
+
+	const query = `
+	INSERT INTO assignment_queues (name, category, strategy, enabled, team_id) VALUES ($1, $2, $3, $4, $5) RETURNING *`
+
+	var queue AssignmentQueue
+	if err := s.db.GetContext(ctx, &queue, query, params.Name, params.Category, params.Strategy,
+		params.Enabled, params.team()); err != nil {
+		return nil, fmt.Errorf("failed to create assignment queue: %w", err)
+	}
+
+	return &queue, nil
+}
+
+func (s *AssignmentQueueStore) Update(ctx context.Context, queueId uuid.UUID, params AssignmentQueueParams) (*AssignmentQueue, error) {
+
+	const query = `
+	UPDATE assignment_queues SET name = $2, category = $3, strategy = $4, enabled = $5, team_id = $6, updated_at = $7
+	WHERE id = $1 RETURNING *`
+
+	var queue AssignmentQueue
+	if err := s.db.GetContext(ctx, &queue, query, queueId, params.Name, params.Category, params.Strategy,
+		params.Enabled, params.team(), time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update assignment queue with id %v: %w", queueId, err)
+	}
+
+	return &queue, nil
+}
+
+func (s *AssignmentQueueStore) Delete(ctx context.Context, queueId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM assignment_queues WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, queueId); err != nil {
+		return fmt.Errorf("failed to delete assignment queue with id %v: %w", queueId, err)
+	}
+
+	return nil
+}
+
+func (s *AssignmentQueueStore) All(ctx context.Context) ([]AssignmentQueue, error) {
+
+	const query = `
+	SELECT * FROM assignment_queues ORDER BY name ASC`
+
+	var queues []AssignmentQueue
+	if err := s.db.SelectContext(ctx, &queues, query); err != nil {
+		return nil, fmt.Errorf("failed to get assignment queues: %w", err)
+	}
+
+	return queues, nil
+}
+
+// autoAssign picks an available staff member for a new ticket of the category,
+// when the ticket already has a team only queues without a team or of that team are used.
+// The queue row stays locked until the surrounding transaction ends, so instances
+// assigning from the same queue take turns and always count each other's tickets.
+func autoAssign(ctx context.Context, tx *sqlx.Tx, category string, team uuid.NullUUID) (*AssignmentQueue, uuid.NullUUID, error) {
+
+	const queueQuery = `
+	SELECT * FROM assignment_queues
+	WHERE enabled AND (category = $1 OR category = '') AND ($2::UUID IS NULL OR team_id IS NULL OR team_id = $2)
+	ORDER BY team_id IS NOT DISTINCT FROM $2 DESC, category = '' ASC, created_at ASC LIMIT 1
+	FOR UPDATE`
+
+	var queue AssignmentQueue
+	if err := tx.GetContext(ctx, &queue, queueQuery, category, team); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, uuid.NullUUID{}, nil
+		}
+		return nil, uuid.NullUUID{}, fmt.Errorf("failed to get assignment queue for category %q: %w", category, err)
+	}
+
+	lastAssignee := uuid.NullUUID{UUID: queue.LastAssignee, Valid: queue.LastAssignee != uuid.Nil}
+	queueTeam := uuid.NullUUID{UUID: queue.TeamId, Valid: queue.TeamId != uuid.Nil}
+
+	var query string
+	var args []any
+	switch queue.Strategy {
+	case StrategyRoundRobin:
+		// the next available staff member after the last one, wrapping around
+		query = `
+		SELECT u.id FROM users u
+		WHERE u.available AND u.roles & $1 != 0
+		AND ($3::UUID IS NULL OR EXISTS (SELECT 1 FROM team_members m WHERE m.team_id = $3 AND m.user_id = u.id))
+		ORDER BY u.id <= COALESCE($2, '00000000-0000-0000-0000-000000000000'::UUID) ASC, u.id ASC
+		LIMIT 1`
+		args = []any{RoleStaff, lastAssignee, queueTeam}
+	case StrategyLeastOpen:
+		query = `
+		SELECT u.id FROM users u
+		LEFT JOIN tickets t ON t.current_assignee = u.id AND t.status IN ($2, $3)
+		WHERE u.available AND u.roles & $1 != 0
+		AND ($4::UUID IS NULL OR EXISTS (SELECT 1 FROM team_members m WHERE m.team_id = $4 AND m.user_id = u.id))
+		GROUP BY u.id
+		ORDER BY COUNT(t.id) ASC, u.id ASC
+		LIMIT 1`
+		args = []any{RoleStaff, TicketStatusCreated, TicketStatusInProgress, queueTeam}
+	default:
+		return nil, uuid.NullUUID{}, fmt.Errorf("unknown assignment strategy %q", queue.Strategy)
+	}
+
+	var assignee uuid.NullUUID
+	if err := tx.GetContext(ctx, &assignee, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &queue, uuid.NullUUID{}, nil
+		}
+		return nil, uuid.NullUUID{}, fmt.Errorf("failed to pick assignee from queue %q: %w", queue.Name, err)
+	}
+
+	const updateQuery = `
+	UPDATE assignment_queues SET last_assignee = $2 WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, updateQuery, queue.Id, assignee); err != nil {
+		return nil, uuid.NullUUID{}, fmt.Errorf("failed to update assignment queue %q: %w", queue.Name, err)
+	}
+
+	return &queue, assignee, nil
+}
@@ -0,0 +1,90 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestAssignmentQueueStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	queueStore := store.NewAssignmentQueueStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	var staff []*store.User
+	for _, email := range []string{"staff1@test.com", "staff2@test.com", "off@test.com"} {
+		user, err := userStore.CreateUser(ctx, email, "test")
+		require.NoError(t, err)
+
+		user, err = userStore.UpdateUserById(ctx, user.Id, user.Email, store.RoleStaff)
+		require.NoError(t, err)
+		staff = append(staff, user)
+	}
+
+	for _, user := range staff[:2] {
+		user, err = userStore.SetAvailability(ctx, user.Id, true)
+		require.NoError(t, err)
+		require.True(t, user.Available)
+	}
+
+	queue, err := queueStore.Create(ctx, store.AssignmentQueueParams{
+		Name:     "housekeeping",
+		Category: "housekeeping",
+		Strategy: store.StrategyRoundRobin,
+		Enabled:  true,
+	})
+	require.NoError(t, err)
+
+	create := func(category string) *store.Ticket {
+		ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+			Title:       "Extra towels",
+			Description: "Room 204",
+			Category:    category,
+			Creator:     guest.Id,
+			Priority:    store.TicketPriorityLow,
+		})
+		require.NoError(t, err)
+		return ticket
+	}
+
+	first := create("housekeeping")
+	second := create("housekeeping")
+	third := create("housekeeping")
+
+	require.NotEqual(t, first.CurrentAssignee, second.CurrentAssignee)
+	require.Equal(t, first.CurrentAssignee, third.CurrentAssignee)
+	require.NotEqual(t, staff[2].Id, first.CurrentAssignee)
+	require.NotEqual(t, staff[2].Id, second.CurrentAssignee)
+
+	// no queue for this category
+	require.Equal(t, uuid.Nil, create("billing").CurrentAssignee)
+
+	_, err = queueStore.Update(ctx, queue.Id, store.AssignmentQueueParams{
+		Name:     "housekeeping",
+		Category: "housekeeping",
+		Strategy: store.StrategyLeastOpen,
+		Enabled:  true,
+	})
+	require.NoError(t, err)
+
+	// the staff member who got two tickets so far is skipped
+	require.Equal(t, second.CurrentAssignee, create("housekeeping").CurrentAssignee)
+
+	require.NoError(t, queueStore.Delete(ctx, queue.Id))
+}
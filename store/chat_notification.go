@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// ChatNotification is a notification posted to the chat webhook of a user,
+// it is retried like an email
+type ChatNotification struct {
+	Id            uuid.UUID      `db:"id"`
+	UserId        uuid.UUID      `db:"user_id"`
+	Url           string         `db:"url"`
+	Text          string         `db:"text"`
+	Status        DeliveryStatus `db:"status"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt time.Time      `db:"next_attempt_at"`
+	LastError     string         `db:"last_error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	SentAt        *time.Time     `db:"sent_at"`
+}
+
+type ChatParams struct {
+	UserId uuid.UUID
+	Url    string
+	Text   string
+	// NotBefore delays posting, e.g. until the quiet hours of the recipient end
+	NotBefore time.Time
+}
+
+func queueChat(ctx context.Context, q sqlx.ExecerContext, params ChatParams) error {
+
+	const query = `
+	INSERT INTO chat_notifications (user_id, url, text, next_attempt_at) VALUES ($1, $2, $3, $4)`
+
+	notBefore := params.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	if _, err := q.ExecContext(ctx, query, params.UserId, params.Url, params.Text, notBefore); err != nil {
+		return fmt.Errorf("failed to queue chat notification for user %v: %w", params.UserId, err)
+	}
+
+	return nil
+}
+
+// ClaimDueChats hands out up to limit chat notifications that are due, leased like emails
+func (s *NotificationStore) ClaimDueChats(ctx context.Context, limit int, lease time.Duration) ([]ChatNotification, error) {
+
+	const query = `
+	WITH due AS (
+		SELECT id FROM chat_notifications
+		WHERE status = $1 AND next_attempt_at <= $2
+		ORDER BY next_attempt_at ASC LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE chat_notifications c SET next_attempt_at = $4
+	FROM due WHERE c.id = due.id RETURNING c.*`
+
+	now := time.Now()
+
+	chats := []ChatNotification{}
+	if err := s.db.SelectContext(ctx, &chats, query, DeliveryPending, now, limit, now.Add(lease)); err != nil {
+		return nil, fmt.Errorf("failed to claim chat notifications: %w", err)
+	}
+
+	return chats, nil
+}
+
+// RecordChatAttempt stores the outcome of posting a chat notification,
+// failed attempts are retried with EmailBackoff until EmailMaxAttempts
+func (s *NotificationStore) RecordChatAttempt(ctx context.Context, chat *ChatNotification, postErr error) error {
+	now := time.Now()
+	attempts := chat.Attempts + 1
+
+	if postErr == nil {
+		const query = `
+		UPDATE chat_notifications SET status = $2, attempts = $3, last_error = '', sent_at = $4 WHERE id = $1`
+
+		if _, err := s.db.ExecContext(ctx, query, chat.Id, DeliverySucceeded, attempts, now); err != nil {
+			return fmt.Errorf("failed to record chat notification %v: %w", chat.Id, err)
+		}
+
+		return nil
+	}
+
+	status := DeliveryPending
+	if attempts >= EmailMaxAttempts {
+		status = DeliveryFailed
+	}
+
+	const query = `
+	UPDATE chat_notifications SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5 WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, chat.Id, status, attempts, postErr.Error(),
+		now.Add(EmailBackoff(attempts))); err != nil {
+		return fmt.Errorf("failed to record chat notification %v: %w", chat.Id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrValidation is wrapped by store errors caused by invalid input rather than a failing database
+var ErrValidation = errors.New("validation failed")
+
+type CustomFieldType string
+
+const (
+	CustomFieldText   CustomFieldType = "text"
+	CustomFieldNumber CustomFieldType = "number"
+	CustomFieldEnum   CustomFieldType = "enum"
+	CustomFieldDate   CustomFieldType = "date"
+	CustomFieldUser   CustomFieldType = "user"
+)
+
+const CustomFieldDateLayout = "2006-01-02"
+
+func (t CustomFieldType) Valid() bool {
+	switch t {
+	case CustomFieldText, CustomFieldNumber, CustomFieldEnum, CustomFieldDate, CustomFieldUser:
+		return true
+	}
+	return false
+}
+
+// CustomFields holds the values of the custom fields of a ticket, stored as JSONB
+type CustomFields map[string]any
+
+func (f CustomFields) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	return jsonValue(f)
+}
+
+func (f *CustomFields) Scan(src any) error {
+	*f = CustomFields{}
+	return jsonScan(src, f)
+}
+
+type CustomFieldStore struct {
+	db *sqlx.DB
+}
+
+func NewCustomFieldStore(db *sql.DB) *CustomFieldStore {
+	return &CustomFieldStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+type CustomField struct {
+	Id        uuid.UUID       `db:"id"`
+	Category  string          `db:"category"`
+	Name      string          `db:"name"`
+	Label     string          `db:"label"`
+	Type      CustomFieldType `db:"type"`
+	Required  bool            `db:"required"`
+	Options   pq.StringArray  `db:"options"`
+	CreatedAt time.Time       `db:"created_at"`
+}
+
+type CustomFieldParams struct {
+	Category string
+	Name     string
+	Label    string
+	Type     CustomFieldType
+	Required bool
+	Options  []string
+}
+
+func (p CustomFieldParams) options() pq.StringArray {
+	if p.Options == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(p.Options)
+}
+
+func (s *CustomFieldStore) Create(ctx context.Context, params CustomFieldParams) (*CustomField, error) {
+
+	const query = `
+	INSERT INTO custom_fields (category, name, label, type, required, options)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`
+
+	var field CustomField
+	if err := s.db.GetContext(ctx, &field, query, params.Category, params.Name, params.Label,
+		params.Type, params.Required, params.options()); err != nil {
+		return nil, fmt.Errorf("failed to create custom field: %w", err)
+	}
+
+	return &field, nil
+}
+
+func (s *CustomFieldStore) Update(ctx context.Context, fieldId uuid.UUID, params CustomFieldParams) (*CustomField, error) {
+
+	const query = `
+	UPDATE custom_fields SET category = $2, name = $3, label = $4, type = $5, required = $6, options = $7
+	WHERE id = $1 RETURNING *`
+
+	var field CustomField
+	if err := s.db.GetContext(ctx, &field, query, fieldId, params.Category, params.Name, params.Label,
+		params.Type, params.Required, params.options()); err != nil {
+		return nil, fmt.Errorf("failed to update custom field with id %v: %w", fieldId, err)
+	}
+
+	return &field, nil
+}
+
+func (s *CustomFieldStore) Delete(ctx context.Context, fieldId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM custom_fields WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, fieldId); err != nil {
+		return fmt.Errorf("failed to delete custom field with id %v: %w", fieldId, err)
+	}
+
+	return nil
+}
+
+func (s *CustomFieldStore) ByCategory(ctx context.Context, category string) ([]CustomField, error) {
+
+	const query = `
+	SELECT * FROM custom_fields WHERE category = $1 ORDER BY name ASC`
+
+	var fields []CustomField
+	if err := s.db.SelectContext(ctx, &fields, query, category); err != nil {
+		return nil, fmt.Errorf("failed to get custom fields for category %q: %w", category, err)
+	}
+
+	return fields, nil
+}
+
+func (s *CustomFieldStore) All(ctx context.Context) ([]CustomField, error) {
+
+	const query = `
+	SELECT * FROM custom_fields ORDER BY category ASC, name ASC`
+
+	var fields []CustomField
+	if err := s.db.SelectContext(ctx, &fields, query); err != nil {
+		return nil, fmt.Errorf("failed to get all custom fields: %w", err)
+	}
+
+	return fields, nil
+}
+
+const (
+	CustomFieldMissing   = "required"
+	CustomFieldInvalid   = "invalid"
+	CustomFieldUndefined = "unknown"
+	CustomFieldNoUser    = "not_found"
+)
+
+// CustomFieldError is a problem with the value of one custom field, Reason is one of
+// CustomFieldMissing, CustomFieldInvalid, CustomFieldUndefined and CustomFieldNoUser
+type CustomFieldError struct {
+	Name    string
+	Reason  string
+	Message string
+}
+
+// CustomFieldErrors are all the problems with a set of custom field values, they wrap ErrValidation
+type CustomFieldErrors []CustomFieldError
+
+func (errs CustomFieldErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = fmt.Sprintf("field %q %s", err.Name, err.Message)
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(messages, "; "))
+}
+
+func (errs CustomFieldErrors) Unwrap() error {
+	return ErrValidation
+}
+
+// Validate checks the values against the custom field schema of the category.
+// Problems with the values themselves are returned as CustomFieldErrors.
+func (s *CustomFieldStore) Validate(ctx context.Context, category string, values CustomFields) error {
+	fields, err := s.ByCategory(ctx, category)
+	if err != nil {
+		return err
+	}
+
+	errs := validateCustomFields(fields, values)
+
+	const query = `
+	SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`
+
+	for _, field := range fields {
+		value, ok := values[field.Name]
+		if field.Type != CustomFieldUser || !ok || value == nil || field.check(value) != nil {
+			continue
+		}
+
+		var exists bool
+		if err := s.db.GetContext(ctx, &exists, query, value); err != nil {
+			return fmt.Errorf("failed to look up user for custom field %q: %w", field.Name, err)
+		}
+
+		if !exists {
+			errs = append(errs, CustomFieldError{Name: field.Name, Reason: CustomFieldNoUser, Message: "references an unknown user"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateCustomFields checks the shape of the values against the fields without touching the database
+func ValidateCustomFields(fields []CustomField, values CustomFields) error {
+	if errs := validateCustomFields(fields, values); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateCustomFields(fields []CustomField, values CustomFields) CustomFieldErrors {
+	var errs CustomFieldErrors
+
+	known := map[string]bool{}
+	for _, field := range fields {
+		known[field.Name] = true
+
+		value, ok := values[field.Name]
+		if !ok || value == nil {
+			if field.Required {
+				errs = append(errs, CustomFieldError{Name: field.Name, Reason: CustomFieldMissing, Message: "is required"})
+			}
+			continue
+		}
+
+		if err := field.check(value); err != nil {
+			errs = append(errs, CustomFieldError{Name: field.Name, Reason: CustomFieldInvalid, Message: err.Error()})
+		}
+	}
+
+	// sorted so that the errors come in the same order every time
+	names := slices.Sorted(maps.Keys(values))
+	for _, name := range names {
+		if !known[name] {
+			errs = append(errs, CustomFieldError{Name: name, Reason: CustomFieldUndefined, Message: "is not defined for this category"})
+		}
+	}
+
+	return errs
+}
+
+func (f *CustomField) check(value any) error {
+	if f.Type == CustomFieldNumber {
+		if _, ok := value.(float64); !ok {
+			return errors.New("must be a number")
+		}
+		return nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return errors.New("must be a string")
+	}
+
+	switch f.Type {
+	case CustomFieldEnum:
+		if !slices.Contains(f.Options, str) {
+			return fmt.Errorf("must be one of %v", []string(f.Options))
+		}
+	case CustomFieldDate:
+		if _, err := time.Parse(CustomFieldDateLayout, str); err != nil {
+			return fmt.Errorf("must be a date formatted as %s", CustomFieldDateLayout)
+		}
+	case CustomFieldUser:
+		if _, err := uuid.Parse(str); err != nil {
+			return errors.New("must be a user id")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestValidateCustomFields(t *testing.T) {
+	fields := []store.CustomField{
+		{Name: "room_number", Type: store.CustomFieldText, Required: true},
+		{Name: "amount", Type: store.CustomFieldNumber},
+		{Name: "kind", Type: store.CustomFieldEnum, Options: []string{"ac", "tv"}},
+		{Name: "noticed_on", Type: store.CustomFieldDate},
+	}
+
+	require.NoError(t, store.ValidateCustomFields(fields, store.CustomFields{
+		"room_number": "204",
+		"amount":      float64(12.5),
+		"kind":        "tv",
+		"noticed_on":  "2025-01-31",
+	}))
+
+	invalid := []store.CustomFields{
+		{},
+		{"room_number": "204", "amount": "12"},
+		{"room_number": "204", "kind": "fridge"},
+		{"room_number": "204", "noticed_on": "31/01/2025"},
+		{"room_number": "204", "unknown": "value"},
+	}
+
+	for _, values := range invalid {
+		require.ErrorIs(t, store.ValidateCustomFields(fields, values), store.ErrValidation)
+	}
+
+	// every problem is reported, not just the first
+	var errs store.CustomFieldErrors
+	require.ErrorAs(t, store.ValidateCustomFields(fields, store.CustomFields{"amount": "12", "unknown": "value"}), &errs)
+	require.Equal(t, []string{store.CustomFieldMissing, store.CustomFieldInvalid, store.CustomFieldUndefined}, []string{errs[0].Reason, errs[1].Reason, errs[2].Reason})
+	require.Equal(t, []string{"room_number", "amount", "unknown"}, []string{errs[0].Name, errs[1].Name, errs[2].Name})
+}
+
+func TestCustomFieldStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	fieldStore := store.NewCustomFieldStore(env.Db)
+
+	user, err := userStore.CreateUser(ctx, "test@test.com", "test")
+	require.NoError(t, err)
+
+	_, err = fieldStore.Create(ctx, store.CustomFieldParams{
+		Category: "maintenance",
+		Name:     "room_number",
+		Label:    "Room number",
+		Type:     store.CustomFieldText,
+		Required: true,
+	})
+	require.NoError(t, err)
+
+	field, err := fieldStore.Create(ctx, store.CustomFieldParams{
+		Category: "maintenance",
+		Name:     "technician",
+		Label:    "Technician",
+		Type:     store.CustomFieldUser,
+	})
+	require.NoError(t, err)
+
+	fields, err := fieldStore.ByCategory(ctx, "maintenance")
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+
+	require.NoError(t, fieldStore.Validate(ctx, "maintenance", store.CustomFields{
+		"room_number": "204",
+		"technician":  user.Id.String(),
+	}))
+
+	err = fieldStore.Validate(ctx, "maintenance", store.CustomFields{
+		"room_number": "204",
+		"technician":  "00000000-0000-0000-0000-000000000001",
+	})
+	require.ErrorIs(t, err, store.ErrValidation)
+
+	require.NoError(t, fieldStore.Delete(ctx, field.Id))
+
+	fields, err = fieldStore.All(ctx)
+	require.NoError(t, err)
+	require.Len(t, fields, 1)
+}
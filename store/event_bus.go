@@ -0,0 +1,244 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/tatucosmin/hotel-system/config"
+)
+
+// eventChannel is the postgres notification channel domain events are published on
+const eventChannel = "ticket_events"
+
+const maxNotifyPayload = 8000
+
+const (
+	EventTicketClosed = "ticket.closed"
+	// EventResync is sent to subscribers when notifications may have been missed,
+	// after the listener lost its connection, so they can catch up from the history
+	EventResync = "resync"
+)
+
+// Event is a domain event as published on the bus, HistoryId points at the history
+// entry it was recorded as and is 0 for events that are not recorded, such as chat events
+type Event struct {
+	Type      string    `json:"type"`
+	TicketId  uuid.UUID `json:"ticket_id"`
+	HistoryId int64     `json:"history_id,omitempty"`
+	Action    string    `json:"action,omitempty"`
+	Actor     uuid.UUID `json:"actor"`
+	// Details are the details of the history entry or what else the event carries
+	Details HistoryDetails `json:"details,omitempty"`
+	// Ticket is the archived ticket of a ticket.closed event, for webhooks and notifications.
+	// It is left out on the bus, subscribers look up what they need by id.
+	Ticket *Ticket   `json:"ticket,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+func (e Event) Value() (driver.Value, error) {
+	return jsonValue(e)
+}
+
+func (e *Event) Scan(src any) error {
+	return jsonScan(src, e)
+}
+
+// EventBus delivers domain events to every subscriber of every instance
+type EventBus interface {
+	Publish(ctx context.Context, event Event) error
+	// Subscribe returns a channel of events that is closed once ctx is done. Events are
+	// dropped for subscribers that fall behind, the history is the complete record.
+	Subscribe(ctx context.Context) <-chan Event
+}
+
+// notifyEvent publishes the event through postgres and queues it for the webhooks subscribed
+// to it and for notifications, when q is a transaction all only happen once it commits
+func notifyEvent(ctx context.Context, q sqlx.ExecerContext, event Event) error {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+
+	if err := queueWebhookDeliveries(ctx, q, event.Type, payload); err != nil {
+		return err
+	}
+
+	if err := queueNotificationEvent(ctx, q, event, payload); err != nil {
+		return err
+	}
+
+	notification, err := busPayload(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.ExecContext(ctx, `SELECT pg_notify($1, $2)`, eventChannel, notification); err != nil {
+		return fmt.Errorf("failed to publish %s event for ticket %v: %w", event.Type, event.TicketId, err)
+	}
+
+	return nil
+}
+
+// busPayload is the event as it goes on the bus, without the ticket. Postgres refuses
+// payloads of 8000 bytes and more, the details are left out too when they would not fit
+// and subscribers have to look them up themselves.
+func busPayload(event Event) (string, error) {
+	event.Ticket = nil
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+
+	if len(payload) >= maxNotifyPayload {
+		event.Details = nil
+		if payload, err = json.Marshal(event); err != nil {
+			return "", fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+	}
+
+	return string(payload), nil
+}
+
+const subscriberBuffer = 64
+
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func (b *broker) subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers == nil {
+		b.subscribers = map[chan Event]struct{}{}
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (b *broker) broadcast(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PgEventBus delivers the events published with pg_notify by any instance,
+// the listener reconnects on its own when the connection drops
+type PgEventBus struct {
+	db       *sqlx.DB
+	listener *pq.Listener
+	broker   broker
+}
+
+func NewPgEventBus(cfg *config.Config, db *sql.DB) (*PgEventBus, error) {
+	bus := &PgEventBus{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+
+	bus.listener = pq.NewListener(cfg.DatabaseUrl(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("event bus listener", "event", event, "error", err)
+		}
+	})
+
+	if err := bus.listener.Listen(eventChannel); err != nil {
+		bus.listener.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", eventChannel, err)
+	}
+
+	go bus.run()
+
+	return bus, nil
+}
+
+func (b *PgEventBus) run() {
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case notification, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+
+			// a nil notification means the connection was re-established
+			if notification == nil {
+				b.broker.broadcast(Event{Type: EventResync})
+				continue
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				slog.Error("failed to decode event", "payload", notification.Extra, "error", err)
+				continue
+			}
+			b.broker.broadcast(event)
+		case <-ping.C:
+			// detects dead connections that would otherwise go unnoticed
+			go b.listener.Ping()
+		}
+	}
+}
+
+func (b *PgEventBus) Publish(ctx context.Context, event Event) error {
+	return notifyEvent(ctx, b.db, event)
+}
+
+func (b *PgEventBus) Subscribe(ctx context.Context) <-chan Event {
+	return b.broker.subscribe(ctx)
+}
+
+func (b *PgEventBus) Close() error {
+	return b.listener.Close()
+}
+
+// MemoryEventBus delivers events within the process, for tests
+type MemoryEventBus struct {
+	broker broker
+}
+
+func NewMemoryEventBus() *MemoryEventBus {
+	return &MemoryEventBus{}
+}
+
+func (b *MemoryEventBus) Publish(ctx context.Context, event Event) error {
+	event.Ticket = nil
+	b.broker.broadcast(event)
+	return nil
+}
+
+func (b *MemoryEventBus) Subscribe(ctx context.Context) <-chan Event {
+	return b.broker.subscribe(ctx)
+}
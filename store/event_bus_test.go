@@ -0,0 +1,109 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func receiveEvent(t *testing.T, events <-chan store.Event) store.Event {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return store.Event{}
+	}
+}
+
+func TestMemoryEventBus(t *testing.T) {
+	bus := store.NewMemoryEventBus()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := bus.Subscribe(ctx)
+	second := bus.Subscribe(context.Background())
+
+	ticketId := uuid.New()
+	require.NoError(t, bus.Publish(ctx, store.Event{Type: store.EventTicketCreated, TicketId: ticketId}))
+
+	require.Equal(t, ticketId, receiveEvent(t, first).TicketId)
+	require.Equal(t, ticketId, receiveEvent(t, second).TicketId)
+
+	cancel()
+	_, ok := <-first
+	require.False(t, ok)
+}
+
+func TestPgEventBus(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	bus, err := store.NewPgEventBus(env.Config, env.Db)
+	require.NoError(t, err)
+	defer bus.Close()
+
+	events := bus.Subscribe(ctx)
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	event := receiveEvent(t, events)
+	require.Equal(t, store.EventTicketCreated, event.Type)
+	require.Equal(t, ticket.Id, event.TicketId)
+	require.NotZero(t, event.HistoryId)
+
+	_, err = replyStore.Create(ctx, ticket.Id, guest.Id, "it flickers too")
+	require.NoError(t, err)
+
+	event = receiveEvent(t, events)
+	require.Equal(t, store.EventTicketReplied, event.Type)
+	require.Equal(t, guest.Id, event.Actor)
+
+	require.NoError(t, ticketStore.Close(ctx, ticket.Id, guest.Id))
+
+	event = receiveEvent(t, events)
+	require.Equal(t, store.EventTicketClosed, event.Type)
+	require.Equal(t, ticket.Id, event.TicketId)
+	require.Nil(t, event.Ticket)
+
+	// tickets too large for a postgres notification still close, the bus only carries ids
+	large, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "inventory",
+		Description: strings.Repeat("minibar ", 2000),
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+	require.Equal(t, large.Id, receiveEvent(t, events).TicketId)
+
+	require.NoError(t, ticketStore.Close(ctx, large.Id, guest.Id))
+
+	event = receiveEvent(t, events)
+	require.Equal(t, store.EventTicketClosed, event.Type)
+	require.Equal(t, large.Id, event.TicketId)
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// InboundEmailStore remembers the emails taken in by their Message-Id
+type InboundEmailStore struct {
+	db *sqlx.DB
+}
+
+func NewInboundEmailStore(db *sql.DB) *InboundEmailStore {
+	return &InboundEmailStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// InboundEmail is what an email turned into, ReplyId is not valid when it opened a ticket
+type InboundEmail struct {
+	MessageId   string        `db:"message_id"`
+	TicketId    uuid.UUID     `db:"ticket_id"`
+	ReplyId     uuid.NullUUID `db:"reply_id"`
+	Attachments int           `db:"attachments"`
+	ReceivedAt  time.Time     `db:"received_at"`
+}
+
+func (s *InboundEmailStore) ByMessageId(ctx context.Context, messageId string) (*InboundEmail, error) {
+
+	const query = `
+	SELECT * FROM inbound_emails WHERE message_id = $1`
+
+	var email InboundEmail
+	if err := s.db.GetContext(ctx, &email, query, messageId); err != nil {
+		return nil, fmt.Errorf("failed to get inbound email %q: %w", messageId, err)
+	}
+
+	return &email, nil
+}
+
+// Record remembers the email, the first email with a message id is the one kept
+func (s *InboundEmailStore) Record(ctx context.Context, email InboundEmail) error {
+
+	const query = `
+	INSERT INTO inbound_emails (message_id, ticket_id, reply_id, attachments)
+	VALUES ($1, $2, $3, $4) ON CONFLICT (message_id) DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, query, email.MessageId, email.TicketId, email.ReplyId, email.Attachments); err != nil {
+		return fmt.Errorf("failed to record inbound email %q: %w", email.MessageId, err)
+	}
+
+	return nil
+}
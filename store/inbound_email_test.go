@@ -0,0 +1,44 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestInboundEmailStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	inboundStore := store.NewInboundEmailStore(env.Db)
+
+	_, err := inboundStore.ByMessageId(ctx, "<reply-1@example.com>")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	first := store.InboundEmail{
+		MessageId:   "<reply-1@example.com>",
+		TicketId:    uuid.New(),
+		ReplyId:     uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		Attachments: 1,
+	}
+	require.NoError(t, inboundStore.Record(ctx, first))
+
+	// recording the message id again keeps the first result
+	require.NoError(t, inboundStore.Record(ctx, store.InboundEmail{MessageId: first.MessageId, TicketId: uuid.New()}))
+
+	received, err := inboundStore.ByMessageId(ctx, first.MessageId)
+	require.NoError(t, err)
+	require.Equal(t, first.TicketId, received.TicketId)
+	require.Equal(t, first.ReplyId, received.ReplyId)
+	require.Equal(t, 1, received.Attachments)
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// InboxNotification is an entry of the in-app inbox of a user
+type InboxNotification struct {
+	Id        uuid.UUID     `db:"id"`
+	UserId    uuid.UUID     `db:"user_id"`
+	TicketId  uuid.UUID     `db:"ticket_id"`
+	Actor     uuid.NullUUID `db:"actor"`
+	Kind      string        `db:"kind"`
+	Title     string        `db:"title"`
+	Body      string        `db:"body"`
+	CreatedAt time.Time     `db:"created_at"`
+	ReadAt    *time.Time    `db:"read_at"`
+}
+
+type InboxParams struct {
+	UserId   uuid.UUID
+	TicketId uuid.UUID
+	Actor    uuid.UUID
+	Kind     string
+	Title    string
+	Body     string
+}
+
+type InboxQuery struct {
+	// After is the last entry of the previous page, uuid.Nil for the first page
+	After      uuid.UUID
+	UnreadOnly bool
+	Limit      int
+}
+
+// ticketVisibleTo is the one sql rule of ticket access, the same as ticketVisible in the server,
+// over the tickets t for the user whose id and roles are the sql expressions: admins see
+// everything, creators their tickets, and staff the tickets assigned to them, the tickets of
+// their teams and the tickets without a team
+func ticketVisibleTo(userId, admin, staff string) string {
+	return fmt.Sprintf(`(
+		%[2]s OR t.creator = %[1]s OR (%[3]s AND (
+			t.current_assignee = %[1]s OR t.team_id IS NULL OR
+			EXISTS (SELECT 1 FROM team_members m WHERE m.team_id = t.team_id AND m.user_id = %[1]s)
+		))
+	)`, userId, admin, staff)
+}
+
+// ticketVisibleToUser keeps the tickets t the user ($1) may access, $2 and $3 tell
+// whether they are an admin and staff
+var ticketVisibleToUser = ticketVisibleTo("$1", "$2", "$3")
+
+// accessTickets are the open and the closed tickets with what decides who may access them,
+// the inbox of a closed ticket is kept and shown by who could access it when it was closed
+const accessTickets = `(
+		SELECT id, creator, current_assignee, team_id FROM tickets
+		UNION ALL
+		SELECT id, creator, current_assignee, team_id FROM closed_tickets
+	)`
+
+// inboxVisible keeps the entries of the user ($1) about tickets t they may access
+var inboxVisible = `
+	n.user_id = $1 AND ` + ticketVisibleToUser
+
+func inboxRoles(user *User) (admin bool, staff bool) {
+	return user.HasRole(RoleAdmin), user.HasRole(RoleStaff)
+}
+
+// createInboxNotification adds the entry unless the ticket never existed, the entries
+// of a ticket outlive it as the notification of its closure has to
+func createInboxNotification(ctx context.Context, q sqlx.ExecerContext, params InboxParams) error {
+
+	const query = `
+	INSERT INTO notifications (user_id, ticket_id, actor, kind, title, body)
+	SELECT $1, t.id, (SELECT id FROM users WHERE id = $3), $4, $5, $6 FROM ` + accessTickets + ` t WHERE t.id = $2`
+
+	if _, err := q.ExecContext(ctx, query, params.UserId, params.TicketId, params.Actor, params.Kind,
+		params.Title, params.Body); err != nil {
+		return fmt.Errorf("failed to create notification for user %v: %w", params.UserId, err)
+	}
+
+	return nil
+}
+
+// Inbox returns a page of the inbox of the user, newest first
+func (s *NotificationStore) Inbox(ctx context.Context, user *User, q InboxQuery) ([]InboxNotification, error) {
+
+	query := `
+	SELECT n.* FROM notifications n JOIN ` + accessTickets + ` t ON t.id = n.ticket_id
+	WHERE ` + inboxVisible + `
+	AND (NOT $4 OR n.read_at IS NULL)
+	AND ($5::UUID IS NULL OR (n.created_at, n.id) < (SELECT created_at, id FROM notifications WHERE id = $5))
+	ORDER BY n.created_at DESC, n.id DESC LIMIT $6`
+
+	after := uuid.NullUUID{UUID: q.After, Valid: q.After != uuid.Nil}
+	admin, staff := inboxRoles(user)
+
+	notifications := []InboxNotification{}
+	if err := s.db.SelectContext(ctx, &notifications, query, user.Id, admin, staff, q.UnreadOnly, after, q.Limit); err != nil {
+		return nil, fmt.Errorf("failed to get inbox of user %v: %w", user.Id, err)
+	}
+
+	return notifications, nil
+}
+
+// UnreadCount counts the unread entries of the inbox of the user
+func (s *NotificationStore) UnreadCount(ctx context.Context, user *User) (int, error) {
+
+	query := `
+	SELECT COUNT(*) FROM notifications n JOIN ` + accessTickets + ` t ON t.id = n.ticket_id
+	WHERE ` + inboxVisible + ` AND n.read_at IS NULL`
+
+	admin, staff := inboxRoles(user)
+
+	var count int
+	if err := s.db.GetContext(ctx, &count, query, user.Id, admin, staff); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications of user %v: %w", user.Id, err)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the entries of the inbox of the user as read and returns how many were unread
+func (s *NotificationStore) MarkRead(ctx context.Context, user *User, ids []uuid.UUID) (int64, error) {
+
+	query := `
+	UPDATE notifications n SET read_at = $4 FROM ` + accessTickets + ` t
+	WHERE t.id = n.ticket_id AND ` + inboxVisible + `
+	AND n.read_at IS NULL AND n.id = ANY($5::uuid[])`
+
+	strIds := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		strIds[i] = id.String()
+	}
+
+	return s.markRead(ctx, user, query, strIds)
+}
+
+// MarkAllRead marks the whole inbox of the user as read and returns how many were unread
+func (s *NotificationStore) MarkAllRead(ctx context.Context, user *User) (int64, error) {
+
+	query := `
+	UPDATE notifications n SET read_at = $4 FROM ` + accessTickets + ` t
+	WHERE t.id = n.ticket_id AND ` + inboxVisible + `
+	AND n.read_at IS NULL`
+
+	return s.markRead(ctx, user, query)
+}
+
+func (s *NotificationStore) markRead(ctx context.Context, user *User, query string, args ...any) (int64, error) {
+	admin, staff := inboxRoles(user)
+
+	res, err := s.db.ExecContext(ctx, query, append([]any{user.Id, admin, staff, time.Now()}, args...)...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications of user %v as read: %w", user.Id, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications of user %v as read: %w", user.Id, err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestInbox(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	teamStore := store.NewTeamStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	notificationStore := store.NewNotificationStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	staff.AddRole(store.RoleStaff)
+	staff, err = userStore.UpdateUserById(ctx, staff.Id, staff.Email, staff.Roles)
+	require.NoError(t, err)
+
+	team, err := teamStore.Create(ctx, "Maintenance", "Repairs and equipment")
+	require.NoError(t, err)
+	require.NoError(t, teamStore.AddMember(ctx, team.Id, staff.Id, false))
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+		TeamId:      team.Id,
+	})
+	require.NoError(t, err)
+
+	entries := []store.InboxParams{}
+	for _, title := range []string{"first", "second", "third"} {
+		entries = append(entries, store.InboxParams{
+			UserId:   staff.Id,
+			TicketId: ticket.Id,
+			Actor:    guest.Id,
+			Kind:     store.NotifyReplied,
+			Title:    title,
+		})
+	}
+	// entries of tickets that never existed are dropped
+	entries = append(entries, store.InboxParams{UserId: staff.Id, TicketId: uuid.New(), Kind: store.NotifyReplied, Title: "gone"})
+
+	require.NoError(t, notificationStore.ProcessEvent(ctx, 0, store.Notifications{Inbox: entries}))
+
+	page, err := notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+
+	rest, err := notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 2, After: page[1].Id})
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+
+	unread, err := notificationStore.UnreadCount(ctx, staff)
+	require.NoError(t, err)
+	require.Equal(t, 3, unread)
+
+	// nobody marks the entries of someone else
+	marked, err := notificationStore.MarkRead(ctx, guest, []uuid.UUID{page[0].Id})
+	require.NoError(t, err)
+	require.Zero(t, marked)
+
+	marked, err = notificationStore.MarkRead(ctx, staff, []uuid.UUID{page[0].Id})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), marked)
+
+	unreadOnly, err := notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 10, UnreadOnly: true})
+	require.NoError(t, err)
+	require.Len(t, unreadOnly, 2)
+
+	// leaving the team of the ticket hides its entries
+	require.NoError(t, teamStore.RemoveMember(ctx, team.Id, staff.Id))
+
+	hidden, err := notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, hidden)
+
+	unread, err = notificationStore.UnreadCount(ctx, staff)
+	require.NoError(t, err)
+	require.Zero(t, unread)
+
+	require.NoError(t, teamStore.AddMember(ctx, team.Id, staff.Id, false))
+
+	marked, err = notificationStore.MarkAllRead(ctx, staff)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), marked)
+
+	// closing the ticket keeps its entries, for who could access it
+	require.NoError(t, ticketStore.Close(ctx, ticket.Id, staff.Id))
+	require.NoError(t, notificationStore.ProcessEvent(ctx, 0, store.Notifications{Inbox: []store.InboxParams{
+		{UserId: staff.Id, TicketId: ticket.Id, Actor: staff.Id, Kind: store.NotifyClosed, Title: "closed"},
+	}}))
+
+	closed, err := notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, closed, 4)
+	require.Equal(t, "closed", closed[0].Title)
+
+	unread, err = notificationStore.UnreadCount(ctx, staff)
+	require.NoError(t, err)
+	require.Equal(t, 1, unread)
+
+	require.NoError(t, teamStore.RemoveMember(ctx, team.Id, staff.Id))
+
+	hidden, err = notificationStore.Inbox(ctx, staff, store.InboxQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, hidden)
+}
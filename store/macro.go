@@ -0,0 +1,226 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type MacroStore struct {
+	db *sqlx.DB
+}
+
+func NewMacroStore(db *sql.DB) *MacroStore {
+	return &MacroStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// MacroVariables are the placeholders available in the reply of a macro
+var MacroVariables = []string{"guest_email", "ticket_title", "assignee"}
+
+type Macro struct {
+	Id          uuid.UUID       `db:"id"`
+	Name        string          `db:"name"`
+	Owner       uuid.UUID       `db:"owner"`
+	Shared      bool            `db:"shared"`
+	Reply       string          `db:"reply"`
+	SetStatus   *TicketStatus   `db:"set_status"`
+	SetPriority *TicketPriority `db:"set_priority"`
+	AddTags     pq.StringArray  `db:"add_tags"`
+	CreatedAt   time.Time       `db:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at"`
+}
+
+type MacroParams struct {
+	Name        string
+	Shared      bool
+	Reply       string
+	SetStatus   *TicketStatus
+	SetPriority *TicketPriority
+	AddTags     []string
+}
+
+func (p MacroParams) addTags() pq.StringArray {
+	if p.AddTags == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(p.AddTags)
+}
+
+func (s *MacroStore) Create(ctx context.Context, ownerId uuid.UUID, params MacroParams) (*Macro, error) {
+
+	const query = `
+	INSERT INTO macros (name, owner, shared, reply, set_status, set_priority, add_tags)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *`
+
+	var macro Macro
+	if err := s.db.GetContext(ctx, &macro, query, params.Name, ownerId, params.Shared, params.Reply,
+		params.SetStatus, params.SetPriority, params.addTags()); err != nil {
+		return nil, fmt.Errorf("failed to create macro: %w", err)
+	}
+
+	return &macro, nil
+}
+
+func (s *MacroStore) Update(ctx context.Context, macroId uuid.UUID, params MacroParams) (*Macro, error) {
+
+	const query = `
+	UPDATE macros SET name = $2, shared = $3, reply = $4, set_status = $5, set_priority = $6,
+	add_tags = $7, updated_at = $8 WHERE id = $1 RETURNING *`
+
+	var macro Macro
+	if err := s.db.GetContext(ctx, &macro, query, macroId, params.Name, params.Shared, params.Reply,
+		params.SetStatus, params.SetPriority, params.addTags(), time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update macro with id %v: %w", macroId, err)
+	}
+
+	return &macro, nil
+}
+
+func (s *MacroStore) Delete(ctx context.Context, macroId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM macros WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, macroId); err != nil {
+		return fmt.Errorf("failed to delete macro with id %v: %w", macroId, err)
+	}
+
+	return nil
+}
+
+func (s *MacroStore) ById(ctx context.Context, macroId uuid.UUID) (*Macro, error) {
+
+	const query = `
+	SELECT * FROM macros WHERE id = $1`
+
+	var macro Macro
+	if err := s.db.GetContext(ctx, &macro, query, macroId); err != nil {
+		return nil, fmt.Errorf("failed to get macro with id %v: %w", macroId, err)
+	}
+
+	return &macro, nil
+}
+
+// AvailableTo returns the personal macros of the user together with all shared macros
+func (s *MacroStore) AvailableTo(ctx context.Context, userId uuid.UUID) ([]Macro, error) {
+
+	const query = `
+	SELECT * FROM macros WHERE owner = $1 OR shared ORDER BY name ASC`
+
+	var macros []Macro
+	if err := s.db.SelectContext(ctx, &macros, query, userId); err != nil {
+		return nil, fmt.Errorf("failed to get macros for user %v: %w", userId, err)
+	}
+
+	return macros, nil
+}
+
+type MacroResult struct {
+	Ticket *Ticket
+	// Reply is nil when the macro has no reply text
+	Reply *TicketReply
+}
+
+// Apply posts the macro reply as the actor and applies its field changes to the ticket in one
+// transaction. Status and priority changes are recorded in the history as if made by hand.
+func (s *MacroStore) Apply(ctx context.Context, macro *Macro, ticketId uuid.UUID, actorId uuid.UUID) (*MacroResult, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const selectQuery = `
+	SELECT t.title, t.status, t.priority, COALESCE(c.email, '') AS guest_email, COALESCE(a.email, '') AS assignee
+	FROM tickets t
+	LEFT JOIN users c ON c.id = t.creator
+	LEFT JOIN users a ON a.id = t.current_assignee
+	WHERE t.id = $1
+	FOR UPDATE OF t`
+
+	var values struct {
+		Title      string         `db:"title"`
+		Status     TicketStatus   `db:"status"`
+		Priority   TicketPriority `db:"priority"`
+		GuestEmail string         `db:"guest_email"`
+		Assignee   string         `db:"assignee"`
+	}
+	if err := tx.GetContext(ctx, &values, selectQuery, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get ticket with id %v: %w", ticketId, err)
+	}
+
+	result := &MacroResult{}
+
+	if macro.Reply != "" {
+		message := Interpolate(macro.Reply, map[string]string{
+			"guest_email":  values.GuestEmail,
+			"ticket_title": values.Title,
+			"assignee":     values.Assignee,
+		})
+
+		reply, err := insertReply(ctx, tx, ticketId, actorId, message)
+		if err != nil {
+			return nil, err
+		}
+		result.Reply = reply
+	}
+
+	const updateQuery = `
+	UPDATE tickets SET
+		status = COALESCE($2, status),
+		priority = COALESCE($3, priority),
+		tags = ARRAY(SELECT DISTINCT unnest(tags || $4::TEXT[]) ORDER BY 1),
+		updated_at = $5,
+		resolved_at = CASE
+			WHEN $2 = $6 AND status != $6 THEN $5
+			WHEN $2 < $6 THEN NULL
+			ELSE resolved_at
+		END
+	WHERE id = $1 RETURNING *`
+
+	var ticket Ticket
+	if err := tx.GetContext(ctx, &ticket, updateQuery, ticketId, macro.SetStatus, macro.SetPriority,
+		macro.AddTags, time.Now(), TicketStatusDone); err != nil {
+		return nil, fmt.Errorf("failed to update ticket with id %v: %w", ticketId, err)
+	}
+	result.Ticket = &ticket
+
+	if err := insertHistory(ctx, tx, ticketId, actorId, HistoryMacroApplied, HistoryDetails{
+		"macro_id": macro.Id,
+		"name":     macro.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	// the changes are recorded like any other, so they notify and reach the event stream
+	if values.Status != ticket.Status {
+		if err := insertHistory(ctx, tx, ticketId, actorId, HistoryStatusChanged, HistoryDetails{
+			"from": values.Status.String(),
+			"to":   ticket.Status.String(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if values.Priority != ticket.Priority {
+		if err := insertHistory(ctx, tx, ticketId, actorId, HistoryPriorityChanged, HistoryDetails{
+			"from": values.Priority.String(),
+			"to":   ticket.Priority.String(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit macro: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestMacroStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	macroStore := store.NewMacroStore(env.Db)
+	historyStore := store.NewTicketHistoryStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	other, err := userStore.CreateUser(ctx, "other@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "Extra towels",
+		Description: "Room 204 needs towels",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+
+	status := store.TicketStatusInProgress
+	macro, err := macroStore.Create(ctx, staff.Id, store.MacroParams{
+		Name:      "Housekeeping on the way",
+		Reply:     "Hi {{guest_email}}, housekeeping is on the way for \"{{ticket_title}}\"",
+		SetStatus: &status,
+		AddTags:   []string{"housekeeping"},
+	})
+	require.NoError(t, err)
+
+	_, err = macroStore.Create(ctx, other.Id, store.MacroParams{
+		Name:  "Private",
+		Reply: "Only mine",
+	})
+	require.NoError(t, err)
+
+	macros, err := macroStore.AvailableTo(ctx, staff.Id)
+	require.NoError(t, err)
+	require.Len(t, macros, 1)
+
+	result, err := macroStore.Apply(ctx, macro, ticket.Id, staff.Id)
+	require.NoError(t, err)
+	require.NotNil(t, result.Reply)
+	require.Equal(t, "Hi guest@test.com, housekeeping is on the way for \"Extra towels\"", result.Reply.Message)
+	require.Equal(t, staff.Id, result.Reply.Creator)
+	require.Equal(t, store.TicketStatusInProgress, result.Ticket.Status)
+	require.Equal(t, store.TicketPriorityLow, result.Ticket.Priority)
+	require.Equal(t, []string{"housekeeping"}, []string(result.Ticket.Tags))
+
+	// the status change is recorded next to the macro, the unchanged priority isn't
+	history, err := historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	changed := history[len(history)-1]
+	require.Equal(t, store.HistoryStatusChanged, changed.Action)
+	require.Equal(t, store.TicketStatusCreated.String(), changed.Details["from"])
+	require.Equal(t, store.TicketStatusInProgress.String(), changed.Details["to"])
+	require.Equal(t, store.HistoryMacroApplied, history[len(history)-2].Action)
+
+	// applying twice doesn't duplicate tags, nor the status change
+	result, err = macroStore.Apply(ctx, macro, ticket.Id, staff.Id)
+	require.NoError(t, err)
+	require.Equal(t, []string{"housekeeping"}, []string(result.Ticket.Tags))
+
+	history, err = historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Equal(t, store.HistoryMacroApplied, history[len(history)-1].Action)
+
+	require.NoError(t, macroStore.Delete(ctx, macro.Id))
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Mention is a user mentioned in a reply, Mention is how they were written without the @
+type Mention struct {
+	ReplyId   uuid.UUID `db:"reply_id"`
+	TicketId  uuid.UUID `db:"ticket_id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Mention   string    `db:"mention"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// MentionedTicket is a ticket the user was mentioned on, with their latest mention
+type MentionedTicket struct {
+	TicketId    uuid.UUID `db:"ticket_id"`
+	Title       string    `db:"title"`
+	ReplyId     uuid.UUID `db:"reply_id"`
+	MentionedBy uuid.UUID `db:"mentioned_by"`
+	MentionedAt time.Time `db:"mentioned_at"`
+}
+
+// mentionPattern matches @ followed by an email address or a handle, the part of an
+// address before the @. The @ must not follow a word so addresses are not read as mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@+-])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// ParseMentions returns the mentions in the message, lowercased and each once
+func ParseMentions(message string) []string {
+	var mentions []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(message, -1) {
+		// a sentence may end right after a handle
+		mention := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if mention != "" && !slices.Contains(mentions, mention) {
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
+
+// insertMentions resolves the mentions in the reply to the users who may access its ticket
+// and makes them watchers of it. A mention is either an email address or a handle, which only
+// resolves when a single such user has it. The author is never mentioned.
+func insertMentions(ctx context.Context, tx *sqlx.Tx, reply *TicketReply) ([]Mention, error) {
+	written := ParseMentions(reply.Message)
+	if len(written) == 0 {
+		return nil, nil
+	}
+
+	candidatesQuery := `
+	SELECT u.id, lower(u.email) AS email FROM users u JOIN tickets t ON t.id = $1
+	WHERE u.id <> $2 AND (lower(u.email) = ANY($3) OR lower(split_part(u.email, '@', 1)) = ANY($3))
+	AND ` + ticketVisibleTo("u.id", "u.roles & $4 != 0", "u.roles & $5 != 0")
+
+	var candidates []struct {
+		Id    uuid.UUID `db:"id"`
+		Email string    `db:"email"`
+	}
+	if err := tx.SelectContext(ctx, &candidates, candidatesQuery, reply.TicketId, reply.Creator,
+		pq.StringArray(written), RoleAdmin, RoleStaff); err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions of reply %v: %w", reply.Id, err)
+	}
+
+	const insertQuery = `
+	INSERT INTO reply_mentions (reply_id, ticket_id, user_id, mention) VALUES ($1, $2, $3, $4) RETURNING *`
+
+	const watchQuery = `
+	INSERT INTO ticket_watchers (ticket_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	mentions := []Mention{}
+	seen := map[uuid.UUID]bool{}
+	for _, mention := range written {
+		var userId uuid.UUID
+		matches := 0
+		for _, candidate := range candidates {
+			handle, _, _ := strings.Cut(candidate.Email, "@")
+			if candidate.Email == mention || handle == mention {
+				userId = candidate.Id
+				matches++
+			}
+		}
+
+		if matches != 1 || seen[userId] {
+			continue
+		}
+		seen[userId] = true
+
+		var inserted Mention
+		if err := tx.GetContext(ctx, &inserted, insertQuery, reply.Id, reply.TicketId, userId, mention); err != nil {
+			return nil, fmt.Errorf("failed to record mention of user %v: %w", userId, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, watchQuery, reply.TicketId, userId); err != nil {
+			return nil, fmt.Errorf("failed to add watcher %v to ticket %v: %w", userId, reply.TicketId, err)
+		}
+
+		mentions = append(mentions, inserted)
+	}
+
+	return mentions, nil
+}
+
+// Mentions lists the mentions in the replies of a ticket, oldest first
+func (s *TicketReplyStore) Mentions(ctx context.Context, ticketId uuid.UUID) ([]Mention, error) {
+
+	const query = `
+	SELECT * FROM reply_mentions WHERE ticket_id = $1 ORDER BY created_at ASC, mention ASC`
+
+	mentions := []Mention{}
+	if err := s.db.SelectContext(ctx, &mentions, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get mentions of ticket %v: %w", ticketId, err)
+	}
+
+	return mentions, nil
+}
+
+func (s *TicketReplyStore) ReplyMentions(ctx context.Context, replyId uuid.UUID) ([]Mention, error) {
+
+	const query = `
+	SELECT * FROM reply_mentions WHERE reply_id = $1 ORDER BY mention ASC`
+
+	mentions := []Mention{}
+	if err := s.db.SelectContext(ctx, &mentions, query, replyId); err != nil {
+		return nil, fmt.Errorf("failed to get mentions of reply %v: %w", replyId, err)
+	}
+
+	return mentions, nil
+}
+
+// MentionedTickets lists the tickets the user was mentioned on and may still access,
+// most recently mentioned first
+func (s *TicketReplyStore) MentionedTickets(ctx context.Context, user *User, limit int) ([]MentionedTicket, error) {
+
+	query := `
+	SELECT * FROM (
+		SELECT DISTINCT ON (rm.ticket_id) rm.ticket_id, t.title, rm.reply_id,
+		r.creator AS mentioned_by, rm.created_at AS mentioned_at
+		FROM reply_mentions rm
+		JOIN tickets t ON t.id = rm.ticket_id
+		JOIN ticket_replies r ON r.id = rm.reply_id
+		WHERE rm.user_id = $1 AND ` + ticketVisibleToUser + `
+		ORDER BY rm.ticket_id, rm.created_at DESC
+	) latest ORDER BY mentioned_at DESC LIMIT $4`
+
+	admin, staff := inboxRoles(user)
+
+	tickets := []MentionedTicket{}
+	if err := s.db.SelectContext(ctx, &tickets, query, user.Id, admin, staff, limit); err != nil {
+		return nil, fmt.Errorf("failed to get tickets user %v was mentioned on: %w", user.Id, err)
+	}
+
+	return tickets, nil
+}
@@ -0,0 +1,130 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestParseMentions(t *testing.T) {
+	require.Equal(t, []string{"jane@hotel.com", "bob"},
+		store.ParseMentions("@Jane@hotel.com can you check with @bob? Thanks @jane@hotel.com."))
+	require.Equal(t, []string{"front.desk"}, store.ParseMentions("(@front.desk) please"))
+	require.Empty(t, store.ParseMentions("write to guest@example.com or call"))
+	require.Empty(t, store.ParseMentions("nothing to see @ all"))
+}
+
+func TestMentions(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+	notificationStore := store.NewNotificationStore(env.Db)
+	historyStore := store.NewTicketHistoryStore(env.Db)
+
+	staff := func(email string) *store.User {
+		user, err := userStore.CreateUser(ctx, email, "test")
+		require.NoError(t, err)
+		user.AddRole(store.RoleStaff)
+		user, err = userStore.UpdateUserById(ctx, user.Id, user.Email, user.Roles)
+		require.NoError(t, err)
+		return user
+	}
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	jane := staff("jane@hotel.com")
+	bob := staff("bob@hotel.com")
+	// two users share the handle "sam", so it only resolves by address
+	sam := staff("sam@hotel.com")
+	staff("sam@spa.com")
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken heater",
+		Description: "room 204 is cold",
+		Category:    "maintenance",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityHigh,
+	})
+	require.NoError(t, err)
+
+	reply, err := replyStore.Create(ctx, ticket.Id, jane.Id, "@bob can you take a look? cc @sam @sam@hotel.com @jane @other-guest")
+	require.NoError(t, err)
+
+	mentions, err := replyStore.ReplyMentions(ctx, reply.Id)
+	require.NoError(t, err)
+	require.Len(t, mentions, 2)
+	require.Equal(t, bob.Id, mentions[0].UserId)
+	require.Equal(t, "bob", mentions[0].Mention)
+	require.Equal(t, sam.Id, mentions[1].UserId)
+	require.Equal(t, "sam@hotel.com", mentions[1].Mention)
+
+	watchers, err := notificationStore.Watchers(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, watchers, 2)
+
+	tickets, err := replyStore.MentionedTickets(ctx, bob, 10)
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+	require.Equal(t, ticket.Id, tickets[0].TicketId)
+	require.Equal(t, jane.Id, tickets[0].MentionedBy)
+
+	tickets, err = replyStore.MentionedTickets(ctx, jane, 10)
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+
+	// an edit resolves the mentions again and only notifies the newly mentioned
+	ann := staff("ann@hotel.com")
+
+	_, err = replyStore.Edit(ctx, reply.Id, jane.Id, "@bob can you take a look? cc @ann")
+	require.NoError(t, err)
+
+	mentions, err = replyStore.ReplyMentions(ctx, reply.Id)
+	require.NoError(t, err)
+	require.Len(t, mentions, 2)
+	require.Equal(t, bob.Id, mentions[0].UserId)
+	require.Equal(t, ann.Id, mentions[1].UserId)
+
+	watchers, err = notificationStore.Watchers(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, watchers, 3)
+
+	history, err := historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	edited := history[len(history)-1]
+	require.Equal(t, store.HistoryReplyEdited, edited.Action)
+	require.Equal(t, []any{ann.Id.String()}, edited.Details["mentioned"])
+
+	_, err = replyStore.Edit(ctx, reply.Id, jane.Id, "@bob can you take a look?")
+	require.NoError(t, err)
+
+	history, err = historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.NotContains(t, history[len(history)-1].Details, "mentioned")
+
+	// guests can not pull other guests onto a ticket they can't see
+	other, err := userStore.CreateUser(ctx, "other@test.com", "test")
+	require.NoError(t, err)
+
+	reply, err = replyStore.Create(ctx, ticket.Id, guest.Id, "@other please have a look")
+	require.NoError(t, err)
+
+	mentions, err = replyStore.ReplyMentions(ctx, reply.Id)
+	require.NoError(t, err)
+	require.Empty(t, mentions)
+
+	tickets, err = replyStore.MentionedTickets(ctx, other, 10)
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+}
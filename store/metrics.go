@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// MetricsStore computes the helpdesk reports. Closed tickets are archived and
+// removed from the database, the reports count them from closed_tickets.
+type MetricsStore struct {
+	db *sqlx.DB
+}
+
+func NewMetricsStore(db *sql.DB) *MetricsStore {
+	return &MetricsStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// ReportGroup is what reports are split by, the empty group reports over all tickets
+type ReportGroup string
+
+const (
+	ReportGroupNone     ReportGroup = ""
+	ReportGroupAssignee ReportGroup = "assignee"
+	ReportGroupTeam     ReportGroup = "team"
+	ReportGroupCategory ReportGroup = "category"
+)
+
+func (g ReportGroup) Valid() bool {
+	return g == ReportGroupNone || g == ReportGroupAssignee || g == ReportGroupTeam || g == ReportGroupCategory
+}
+
+// reportGroupColumns are the key, display name and join used to split tickets aliased as t
+var reportGroupColumns = map[ReportGroup]struct {
+	key  string
+	name string
+	join string
+}{
+	ReportGroupNone: {key: "''", name: "''"},
+	ReportGroupAssignee: {
+		key:  "COALESCE(t.current_assignee::text, '')",
+		name: "COALESCE(g.email, '')",
+		join: "LEFT JOIN users g ON g.id = t.current_assignee",
+	},
+	ReportGroupTeam: {
+		key:  "COALESCE(t.team_id::text, '')",
+		name: "COALESCE(g.name, '')",
+		join: "LEFT JOIN teams g ON g.id = t.team_id",
+	},
+	ReportGroupCategory: {key: "t.category", name: "t.category"},
+}
+
+// DailyVolume is how many tickets of a group were created and resolved on a day (UTC)
+type DailyVolume struct {
+	Day      string `db:"day"`
+	Key      string `db:"key"`
+	Name     string `db:"name"`
+	Created  int    `db:"created"`
+	Resolved int    `db:"resolved"`
+}
+
+// ResponseTimes covers the tickets of a group created in the range, durations are in seconds
+// and nil when no ticket got that far. The first response is the first reply by someone
+// other than the creator, resolution is when the ticket last reached TicketStatusDone.
+type ResponseTimes struct {
+	Key                 string   `db:"key"`
+	Name                string   `db:"name"`
+	Tickets             int      `db:"tickets"`
+	Responded           int      `db:"responded"`
+	FirstResponseMedian *float64 `db:"first_response_median"`
+	FirstResponseP90    *float64 `db:"first_response_p90"`
+	Resolved            int      `db:"resolved"`
+	ResolutionMedian    *float64 `db:"resolution_median"`
+	ResolutionP90       *float64 `db:"resolution_p90"`
+}
+
+// BacklogCount is how many unresolved tickets of a group have the status and priority
+type BacklogCount struct {
+	Key      string         `db:"key"`
+	Name     string         `db:"name"`
+	Status   TicketStatus   `db:"status"`
+	Priority TicketPriority `db:"priority"`
+	Count    int            `db:"count"`
+}
+
+// reportTickets has the open and the closed tickets with the columns the reports use,
+// first_response_at is the first reply by someone other than the creator
+const reportTickets = `
+	WITH report_tickets AS (
+		SELECT t.creator, t.current_assignee, t.team_id, t.category, t.created_at, t.resolved_at,
+		(SELECT MIN(r.created_at) FROM ticket_replies r WHERE r.ticket_id = t.id AND r.creator IS DISTINCT FROM t.creator) AS first_response_at
+		FROM tickets t
+		UNION ALL
+		SELECT creator, current_assignee, team_id, category, created_at, resolved_at, first_response_at
+		FROM closed_tickets
+	)`
+
+func reportGroupSql(group ReportGroup) (key, name, join string, err error) {
+	columns, ok := reportGroupColumns[group]
+	if !ok {
+		return "", "", "", fmt.Errorf("%w: unknown report group %q", ErrValidation, group)
+	}
+	return columns.key, columns.name, columns.join, nil
+}
+
+// Volume counts the tickets created and resolved per day in [from, to)
+func (s *MetricsStore) Volume(ctx context.Context, group ReportGroup, from, to time.Time) ([]DailyVolume, error) {
+	key, name, join, err := reportGroupSql(group)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(reportTickets+`
+	SELECT to_char(day, 'YYYY-MM-DD') AS day, key, name,
+	SUM(created)::int AS created, SUM(resolved)::int AS resolved
+	FROM (
+		SELECT date_trunc('day', t.created_at AT TIME ZONE 'UTC') AS day, %[1]s AS key, %[2]s AS name,
+		1 AS created, 0 AS resolved
+		FROM report_tickets t %[3]s WHERE t.created_at >= $1 AND t.created_at < $2
+		UNION ALL
+		SELECT date_trunc('day', t.resolved_at AT TIME ZONE 'UTC') AS day, %[1]s AS key, %[2]s AS name,
+		0 AS created, 1 AS resolved
+		FROM report_tickets t %[3]s WHERE t.resolved_at >= $1 AND t.resolved_at < $2
+	) v
+	GROUP BY day, key, name ORDER BY day ASC, key ASC`, key, name, join)
+
+	volume := []DailyVolume{}
+	if err := s.db.SelectContext(ctx, &volume, query, from, to); err != nil {
+		return nil, fmt.Errorf("failed to get ticket volume: %w", err)
+	}
+
+	return volume, nil
+}
+
+// ResponseTimes reports the median and p90 time to first response and to resolution
+// of the tickets created in [from, to)
+func (s *MetricsStore) ResponseTimes(ctx context.Context, group ReportGroup, from, to time.Time) ([]ResponseTimes, error) {
+	key, name, join, err := reportGroupSql(group)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(reportTickets+`
+	SELECT %[1]s AS key, %[2]s AS name, COUNT(*) AS tickets,
+	COUNT(t.first_response_at) AS responded,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM t.first_response_at - t.created_at)) AS first_response_median,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM t.first_response_at - t.created_at)) AS first_response_p90,
+	COUNT(t.resolved_at) AS resolved,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM t.resolved_at - t.created_at)) AS resolution_median,
+	percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM t.resolved_at - t.created_at)) AS resolution_p90
+	FROM report_tickets t %[3]s
+	WHERE t.created_at >= $1 AND t.created_at < $2
+	GROUP BY 1, 2 ORDER BY 1 ASC`, key, name, join)
+
+	times := []ResponseTimes{}
+	if err := s.db.SelectContext(ctx, &times, query, from, to); err != nil {
+		return nil, fmt.Errorf("failed to get response times: %w", err)
+	}
+
+	return times, nil
+}
+
+// Backlog counts the tickets that are not resolved yet, as of now
+func (s *MetricsStore) Backlog(ctx context.Context, group ReportGroup) ([]BacklogCount, error) {
+	key, name, join, err := reportGroupSql(group)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %[1]s AS key, %[2]s AS name, t.status, t.priority, COUNT(*) AS count
+	FROM tickets t %[3]s
+	WHERE t.status < $1
+	GROUP BY 1, 2, t.status, t.priority ORDER BY 1 ASC, t.status ASC, t.priority ASC`, key, name, join)
+
+	backlog := []BacklogCount{}
+	if err := s.db.SelectContext(ctx, &backlog, query, TicketStatusDone); err != nil {
+		return nil, fmt.Errorf("failed to get backlog: %w", err)
+	}
+
+	return backlog, nil
+}
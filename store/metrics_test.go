@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestMetricsStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+	metricsStore := store.NewMetricsStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	resolved, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "no hot water",
+		Description: "room 204",
+		Category:    "maintenance",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityHigh,
+	})
+	require.NoError(t, err)
+
+	_, err = ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "extra towels",
+		Description: "room 305",
+		Category:    "housekeeping",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+
+	// the guest's own reply is not a response
+	_, err = replyStore.Create(ctx, resolved.Id, guest.Id, "any news?")
+	require.NoError(t, err)
+	_, err = replyStore.Create(ctx, resolved.Id, staff.Id, "on our way")
+	require.NoError(t, err)
+
+	require.NoError(t, ticketStore.Update(ctx, resolved.Id, store.UpdateTicketParams{
+		Actor:    staff.Id,
+		Priority: store.TicketPriorityHigh,
+		Status:   store.TicketStatusDone,
+	}))
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	volume, err := metricsStore.Volume(ctx, store.ReportGroupNone, from, to)
+	require.NoError(t, err)
+	require.Len(t, volume, 1)
+	require.Equal(t, 2, volume[0].Created)
+	require.Equal(t, 1, volume[0].Resolved)
+
+	times, err := metricsStore.ResponseTimes(ctx, store.ReportGroupCategory, from, to)
+	require.NoError(t, err)
+	require.Len(t, times, 2)
+	require.Equal(t, "housekeeping", times[0].Key)
+	require.Nil(t, times[0].FirstResponseMedian)
+	require.Equal(t, "maintenance", times[1].Key)
+	require.Equal(t, 1, times[1].Responded)
+	require.Equal(t, 1, times[1].Resolved)
+	require.NotNil(t, times[1].FirstResponseMedian)
+	require.NotNil(t, times[1].ResolutionP90)
+
+	backlog, err := metricsStore.Backlog(ctx, store.ReportGroupNone)
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+	require.Equal(t, store.TicketPriorityLow, backlog[0].Priority)
+	require.Equal(t, 1, backlog[0].Count)
+
+	_, err = metricsStore.Backlog(ctx, store.ReportGroup("floor"))
+	require.ErrorIs(t, err, store.ErrValidation)
+
+	// closing deletes the ticket, the reports still count it
+	require.NoError(t, ticketStore.Close(ctx, resolved.Id, staff.Id))
+
+	volume, err = metricsStore.Volume(ctx, store.ReportGroupNone, from, to)
+	require.NoError(t, err)
+	require.Len(t, volume, 1)
+	require.Equal(t, 2, volume[0].Created)
+	require.Equal(t, 1, volume[0].Resolved)
+
+	closedTimes, err := metricsStore.ResponseTimes(ctx, store.ReportGroupCategory, from, to)
+	require.NoError(t, err)
+	require.Equal(t, times, closedTimes)
+
+	backlog, err = metricsStore.Backlog(ctx, store.ReportGroupNone)
+	require.NoError(t, err)
+	require.Len(t, backlog, 1)
+}
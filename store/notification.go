@@ -0,0 +1,344 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type NotificationStore struct {
+	db *sqlx.DB
+}
+
+func NewNotificationStore(db *sql.DB) *NotificationStore {
+	return &NotificationStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// notifiedActions are the history actions people are notified of, the others
+// (rule_applied, priority_changed, ...) accompany one of these or are too noisy
+var notifiedActions = []string{
+	HistoryCreated,
+	HistoryReplied,
+	HistoryAssigned,
+	HistoryAutoAssigned,
+	HistoryStatusChanged,
+}
+
+// Notifies reports whether the event is turned into notifications. Edits of a reply only
+// notify the users they mention for the first time.
+func (e Event) Notifies() bool {
+	if e.Action == HistoryReplyEdited {
+		_, mentioned := e.Details["mentioned"]
+		return mentioned
+	}
+	return e.Type == EventTicketClosed || e.Type == EventTicketDueSoon || e.Type == EventTicketOverdue ||
+		slices.Contains(notifiedActions, e.Action)
+}
+
+const (
+	// EmailMaxAttempts is how often an email is tried before it is given up
+	EmailMaxAttempts = 8
+
+	emailBaseBackoff = time.Minute
+	emailMaxBackoff  = 2 * time.Hour
+)
+
+const (
+	// EmailHeld emails wait for the daily digest of their recipient
+	EmailHeld DeliveryStatus = "digest"
+	// EmailDigested emails have been sent as part of a digest
+	EmailDigested DeliveryStatus = "digested"
+)
+
+// EmailBackoff is how long to wait before the next try after the given number of attempts
+func EmailBackoff(attempts int) time.Duration {
+	return backoff(emailBaseBackoff, emailMaxBackoff, attempts)
+}
+
+type TicketWatcher struct {
+	TicketId  uuid.UUID `db:"ticket_id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email" json:"Email,omitempty"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NotificationEvent is a ticket event waiting to be turned into notifications
+type NotificationEvent struct {
+	Id           int64      `db:"id"`
+	Event        Event      `db:"payload"`
+	ClaimedUntil time.Time  `db:"claimed_until"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ProcessedAt  *time.Time `db:"processed_at"`
+}
+
+type Email struct {
+	Id            uuid.UUID      `db:"id"`
+	UserId        uuid.NullUUID  `db:"user_id"`
+	TicketId      uuid.NullUUID  `db:"ticket_id"`
+	ToAddress     string         `db:"to_address"`
+	Subject       string         `db:"subject"`
+	TextBody      string         `db:"text_body"`
+	HtmlBody      string         `db:"html_body"`
+	Status        DeliveryStatus `db:"status"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt time.Time      `db:"next_attempt_at"`
+	LastError     string         `db:"last_error"`
+	CreatedAt     time.Time      `db:"created_at"`
+	SentAt        *time.Time     `db:"sent_at"`
+}
+
+type EmailParams struct {
+	// UserId is the recipient when they have an account, it may be uuid.Nil
+	UserId uuid.UUID
+	// TicketId is the ticket the email is about, uuid.Nil for none
+	TicketId uuid.UUID
+	To       string
+	Subject  string
+	TextBody string
+	HtmlBody string
+	// Digest holds the email for the daily digest of the recipient
+	Digest bool
+	// NotBefore delays sending, e.g. until the quiet hours of the recipient end
+	NotBefore time.Time
+}
+
+// Notifications are what an event causes on each channel
+type Notifications struct {
+	Emails []EmailParams
+	Chats  []ChatParams
+	Inbox  []InboxParams
+}
+
+// queueNotificationEvent keeps the event for the notification worker if anyone is notified of it
+func queueNotificationEvent(ctx context.Context, q sqlx.ExecerContext, event Event, payload []byte) error {
+	if !event.Notifies() {
+		return nil
+	}
+
+	const query = `
+	INSERT INTO notification_events (payload) VALUES ($1)`
+
+	if _, err := q.ExecContext(ctx, query, string(payload)); err != nil {
+		return fmt.Errorf("failed to queue %s notifications for ticket %v: %w", event.Type, event.TicketId, err)
+	}
+
+	return nil
+}
+
+// Watch subscribes the user to the notifications of the ticket, watching twice is a no-op
+func (s *NotificationStore) Watch(ctx context.Context, ticketId, userId uuid.UUID) error {
+
+	const query = `
+	INSERT INTO ticket_watchers (ticket_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+	if _, err := s.db.ExecContext(ctx, query, ticketId, userId); err != nil {
+		return fmt.Errorf("failed to add watcher %v to ticket %v: %w", userId, ticketId, err)
+	}
+
+	return nil
+}
+
+func (s *NotificationStore) Unwatch(ctx context.Context, ticketId, userId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM ticket_watchers WHERE ticket_id = $1 AND user_id = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, ticketId, userId); err != nil {
+		return fmt.Errorf("failed to remove watcher %v from ticket %v: %w", userId, ticketId, err)
+	}
+
+	return nil
+}
+
+func (s *NotificationStore) Watchers(ctx context.Context, ticketId uuid.UUID) ([]TicketWatcher, error) {
+
+	const query = `
+	SELECT w.ticket_id, w.user_id, u.email, w.created_at
+	FROM ticket_watchers w JOIN users u ON u.id = w.user_id
+	WHERE w.ticket_id = $1 ORDER BY w.created_at ASC`
+
+	watchers := []TicketWatcher{}
+	if err := s.db.SelectContext(ctx, &watchers, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get watchers of ticket %v: %w", ticketId, err)
+	}
+
+	return watchers, nil
+}
+
+// ClaimEvents hands out up to limit unprocessed events, oldest first, each is leased
+// for the given duration so other workers skip it until it is processed
+func (s *NotificationStore) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]NotificationEvent, error) {
+
+	const query = `
+	WITH due AS (
+		SELECT id FROM notification_events
+		WHERE processed_at IS NULL AND claimed_until <= $1
+		ORDER BY id ASC LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE notification_events e SET claimed_until = $3
+	FROM due WHERE e.id = due.id RETURNING e.*`
+
+	now := time.Now()
+
+	events := []NotificationEvent{}
+	if err := s.db.SelectContext(ctx, &events, query, now, limit, now.Add(lease)); err != nil {
+		return nil, fmt.Errorf("failed to claim notification events: %w", err)
+	}
+
+	slices.SortFunc(events, func(a, b NotificationEvent) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return events, nil
+}
+
+// ProcessEvent queues the notifications of the event and marks it as processed, together
+func (s *NotificationStore) ProcessEvent(ctx context.Context, eventId int64, notifications Notifications) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, email := range notifications.Emails {
+		if _, err := queueEmail(ctx, tx, email); err != nil {
+			return err
+		}
+	}
+
+	for _, chat := range notifications.Chats {
+		if err := queueChat(ctx, tx, chat); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range notifications.Inbox {
+		if err := createInboxNotification(ctx, tx, entry); err != nil {
+			return err
+		}
+	}
+
+	const query = `
+	UPDATE notification_events SET processed_at = $2 WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, eventId, time.Now()); err != nil {
+		return fmt.Errorf("failed to mark notification event %d as processed: %w", eventId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit notification event %d: %w", eventId, err)
+	}
+
+	return nil
+}
+
+// QueueEmail queues an email for the mail worker
+func (s *NotificationStore) QueueEmail(ctx context.Context, params EmailParams) (*Email, error) {
+	return queueEmail(ctx, s.db, params)
+}
+
+func queueEmail(ctx context.Context, q sqlx.QueryerContext, params EmailParams) (*Email, error) {
+
+	const query = `
+	INSERT INTO emails (user_id, ticket_id, to_address, subject, text_body, html_body, status, next_attempt_at)
+	VALUES ((SELECT id FROM users WHERE id = $1), $2, $3, $4, $5, $6, $7, $8) RETURNING *`
+
+	ticketId := uuid.NullUUID{UUID: params.TicketId, Valid: params.TicketId != uuid.Nil}
+
+	status := DeliveryPending
+	if params.Digest {
+		status = EmailHeld
+	}
+
+	notBefore := params.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	var email Email
+	if err := sqlx.GetContext(ctx, q, &email, query, params.UserId, ticketId, params.To, params.Subject,
+		params.TextBody, params.HtmlBody, status, notBefore); err != nil {
+		return nil, fmt.Errorf("failed to queue email to %s: %w", params.To, err)
+	}
+
+	return &email, nil
+}
+
+// ClaimDueEmails hands out up to limit emails that are due, leased like webhook deliveries
+func (s *NotificationStore) ClaimDueEmails(ctx context.Context, limit int, lease time.Duration) ([]Email, error) {
+
+	const query = `
+	WITH due AS (
+		SELECT id FROM emails
+		WHERE status = $1 AND next_attempt_at <= $2
+		ORDER BY next_attempt_at ASC LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	UPDATE emails e SET next_attempt_at = $4
+	FROM due WHERE e.id = due.id RETURNING e.*`
+
+	now := time.Now()
+
+	emails := []Email{}
+	if err := s.db.SelectContext(ctx, &emails, query, DeliveryPending, now, limit, now.Add(lease)); err != nil {
+		return nil, fmt.Errorf("failed to claim emails: %w", err)
+	}
+
+	return emails, nil
+}
+
+// RecordEmailAttempt stores the outcome of sending an email, failed
+// attempts are retried with exponential backoff until EmailMaxAttempts
+func (s *NotificationStore) RecordEmailAttempt(ctx context.Context, email *Email, sendErr error) error {
+	now := time.Now()
+	attempts := email.Attempts + 1
+
+	if sendErr == nil {
+		const query = `
+		UPDATE emails SET status = $2, attempts = $3, last_error = '', sent_at = $4 WHERE id = $1`
+
+		if _, err := s.db.ExecContext(ctx, query, email.Id, DeliverySucceeded, attempts, now); err != nil {
+			return fmt.Errorf("failed to record email %v: %w", email.Id, err)
+		}
+
+		return nil
+	}
+
+	status := DeliveryPending
+	if attempts >= EmailMaxAttempts {
+		status = DeliveryFailed
+	}
+
+	const query = `
+	UPDATE emails SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5 WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, email.Id, status, attempts, sendErr.Error(),
+		now.Add(EmailBackoff(attempts))); err != nil {
+		return fmt.Errorf("failed to record email %v: %w", email.Id, err)
+	}
+
+	return nil
+}
+
+// Emails returns the latest emails sent to the address, newest first
+func (s *NotificationStore) Emails(ctx context.Context, to string, limit int) ([]Email, error) {
+
+	const query = `
+	SELECT * FROM emails WHERE to_address = $1 ORDER BY created_at DESC LIMIT $2`
+
+	emails := []Email{}
+	if err := s.db.SelectContext(ctx, &emails, query, to, limit); err != nil {
+		return nil, fmt.Errorf("failed to get emails to %s: %w", to, err)
+	}
+
+	return emails, nil
+}
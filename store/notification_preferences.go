@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// the kinds of notifications users choose channels for
+const (
+	NotifyCreated       = "created"
+	NotifyAssigned      = "assigned"
+	NotifyReplied       = "replied"
+	NotifyMentioned     = "mentioned"
+	NotifyStatusChanged = "status_changed"
+	NotifyClosed        = "closed"
+	NotifyDueSoon       = "due_soon"
+	NotifySlaBreach     = "sla_breach"
+)
+
+var NotificationKinds = []string{
+	NotifyCreated,
+	NotifyAssigned,
+	NotifyReplied,
+	NotifyMentioned,
+	NotifyStatusChanged,
+	NotifyClosed,
+	NotifyDueSoon,
+	NotifySlaBreach,
+}
+
+const (
+	ChannelEmail = "email"
+	// ChannelChat posts to the incoming webhook of a chat tool (slack, mattermost, teams, ...)
+	ChannelChat  = "chat"
+	ChannelInApp = "in_app"
+)
+
+var NotificationChannels = []string{ChannelEmail, ChannelChat, ChannelInApp}
+
+// defaultChannels are used for the kinds a user has not chosen channels for
+var defaultChannels = []string{ChannelEmail, ChannelInApp}
+
+const defaultDigestHour = 8
+
+// ChannelPreferences maps notification kinds to the channels they are sent on, an empty list means none
+type ChannelPreferences map[string][]string
+
+func (c ChannelPreferences) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	return jsonValue(c)
+}
+
+func (c *ChannelPreferences) Scan(src any) error {
+	*c = ChannelPreferences{}
+	return jsonScan(src, c)
+}
+
+type NotificationPreferences struct {
+	UserId   uuid.UUID          `db:"user_id"`
+	Channels ChannelPreferences `db:"channels"`
+	Timezone string             `db:"timezone"`
+	// QuietStart and QuietEnd are HH:MM in the timezone, the quiet hours may span midnight
+	QuietStart     string     `db:"quiet_start"`
+	QuietEnd       string     `db:"quiet_end"`
+	Digest         bool       `db:"digest"`
+	DigestHour     int        `db:"digest_hour"`
+	LastDigestAt   *time.Time `db:"last_digest_at"`
+	ChatWebhookUrl string     `db:"chat_webhook_url"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// DefaultNotificationPreferences are the preferences of users who have not set any
+func DefaultNotificationPreferences(userId uuid.UUID) *NotificationPreferences {
+	return &NotificationPreferences{
+		UserId:     userId,
+		Channels:   ChannelPreferences{},
+		Timezone:   "UTC",
+		DigestHour: defaultDigestHour,
+	}
+}
+
+// ChannelsFor returns the channels the kind of notification is sent on
+func (p *NotificationPreferences) ChannelsFor(kind string) []string {
+	if channels, ok := p.Channels[kind]; ok {
+		return channels
+	}
+	return defaultChannels
+}
+
+func (p *NotificationPreferences) location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseClock parses HH:MM into minutes since midnight
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// QuietUntil returns when the quiet hours now falls into end, the zero time when it is not quiet
+func (p *NotificationPreferences) QuietUntil(now time.Time) time.Time {
+	start, err := ParseClock(p.QuietStart)
+	if err != nil {
+		return time.Time{}
+	}
+
+	end, err := ParseClock(p.QuietEnd)
+	if err != nil || start == end {
+		return time.Time{}
+	}
+
+	loc := p.location()
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	quiet := minute >= start && minute < end
+	if start > end {
+		quiet = minute >= start || minute < end
+	}
+
+	if !quiet {
+		return time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until
+}
+
+// DigestDue reports whether the daily digest has not been sent since the digest hour last passed
+func (p *NotificationPreferences) DigestDue(now time.Time) bool {
+	loc := p.location()
+	local := now.In(loc)
+
+	at := time.Date(local.Year(), local.Month(), local.Day(), p.DigestHour, 0, 0, 0, loc)
+	if local.Before(at) {
+		at = at.AddDate(0, 0, -1)
+	}
+
+	return p.LastDigestAt == nil || p.LastDigestAt.Before(at)
+}
+
+// Preferences returns the notification preferences of the user, the defaults when they have none
+func (s *NotificationStore) Preferences(ctx context.Context, userId uuid.UUID) (*NotificationPreferences, error) {
+
+	const query = `
+	SELECT * FROM notification_preferences WHERE user_id = $1`
+
+	var prefs NotificationPreferences
+	if err := s.db.GetContext(ctx, &prefs, query, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return DefaultNotificationPreferences(userId), nil
+		}
+		return nil, fmt.Errorf("failed to get notification preferences of user %v: %w", userId, err)
+	}
+
+	return &prefs, nil
+}
+
+func (s *NotificationStore) SetPreferences(ctx context.Context, prefs *NotificationPreferences) (*NotificationPreferences, error) {
+
+	const query = `
+	INSERT INTO notification_preferences (user_id, channels, timezone, quiet_start, quiet_end, digest, digest_hour, chat_webhook_url, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (user_id) DO UPDATE SET channels = $2, timezone = $3, quiet_start = $4, quiet_end = $5,
+	digest = $6, digest_hour = $7, chat_webhook_url = $8, updated_at = $9
+	RETURNING *`
+
+	var updated NotificationPreferences
+	if err := s.db.GetContext(ctx, &updated, query, prefs.UserId, prefs.Channels, prefs.Timezone, prefs.QuietStart,
+		prefs.QuietEnd, prefs.Digest, prefs.DigestHour, prefs.ChatWebhookUrl, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to set notification preferences of user %v: %w", prefs.UserId, err)
+	}
+
+	return &updated, nil
+}
+
+// HeldPreferences returns the preferences of the users who have emails held for a digest
+func (s *NotificationStore) HeldPreferences(ctx context.Context) ([]NotificationPreferences, error) {
+
+	const query = `
+	SELECT p.* FROM notification_preferences p
+	WHERE EXISTS (SELECT 1 FROM emails e WHERE e.user_id = p.user_id AND e.status = $1)`
+
+	prefs := []NotificationPreferences{}
+	if err := s.db.SelectContext(ctx, &prefs, query, EmailHeld); err != nil {
+		return nil, fmt.Errorf("failed to get users with held emails: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// HeldEmails returns the emails held for the digest of the user, oldest first
+func (s *NotificationStore) HeldEmails(ctx context.Context, userId uuid.UUID) ([]Email, error) {
+
+	const query = `
+	SELECT * FROM emails WHERE user_id = $1 AND status = $2 ORDER BY created_at ASC`
+
+	emails := []Email{}
+	if err := s.db.SelectContext(ctx, &emails, query, userId, EmailHeld); err != nil {
+		return nil, fmt.Errorf("failed to get held emails of user %v: %w", userId, err)
+	}
+
+	return emails, nil
+}
+
+// ErrDigestTaken is returned when another worker sent some of the held emails first
+var ErrDigestTaken = errors.New("held emails have already been digested")
+
+// SendDigest queues the digest of the held emails in their place and remembers when it was sent
+func (s *NotificationStore) SendDigest(ctx context.Context, userId uuid.UUID, held []Email, digest EmailParams) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make(pq.StringArray, len(held))
+	for i, email := range held {
+		ids[i] = email.Id.String()
+	}
+
+	res, err := tx.ExecContext(ctx, `UPDATE emails SET status = $2 WHERE id = ANY($1::uuid[]) AND status = $3`,
+		ids, EmailDigested, EmailHeld)
+	if err != nil {
+		return fmt.Errorf("failed to mark held emails of user %v as digested: %w", userId, err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != int64(len(held)) {
+		return ErrDigestTaken
+	}
+
+	if _, err := queueEmail(ctx, tx, digest); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE notification_preferences SET last_digest_at = $2 WHERE user_id = $1`,
+		userId, time.Now()); err != nil {
+		return fmt.Errorf("failed to record digest of user %v: %w", userId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit digest of user %v: %w", userId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestQuietUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	prefs := store.DefaultNotificationPreferences(uuid.New())
+	prefs.Timezone = "Europe/Berlin"
+	prefs.QuietStart, prefs.QuietEnd = "22:00", "07:30"
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected time.Time
+	}{
+		{
+			name:     "before midnight",
+			now:      time.Date(2024, 3, 1, 23, 15, 0, 0, berlin),
+			expected: time.Date(2024, 3, 2, 7, 30, 0, 0, berlin),
+		},
+		{
+			name:     "after midnight",
+			now:      time.Date(2024, 3, 2, 3, 0, 0, 0, berlin),
+			expected: time.Date(2024, 3, 2, 7, 30, 0, 0, berlin),
+		},
+		{
+			name: "during the day",
+			now:  time.Date(2024, 3, 2, 12, 0, 0, 0, berlin),
+		},
+		{
+			name: "quiet hours just ended",
+			now:  time.Date(2024, 3, 2, 7, 30, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.True(t, tt.expected.Equal(prefs.QuietUntil(tt.now.UTC())), "got %v", prefs.QuietUntil(tt.now))
+		})
+	}
+
+	prefs.QuietStart, prefs.QuietEnd = "", ""
+	require.True(t, prefs.QuietUntil(time.Date(2024, 3, 1, 23, 15, 0, 0, berlin)).IsZero())
+}
+
+func TestDigestDue(t *testing.T) {
+	prefs := store.DefaultNotificationPreferences(uuid.New())
+	prefs.Digest = true
+	prefs.DigestHour = 8
+
+	now := time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)
+	require.True(t, prefs.DigestDue(now))
+
+	sent := time.Date(2024, 3, 2, 8, 1, 0, 0, time.UTC)
+	prefs.LastDigestAt = &sent
+	require.False(t, prefs.DigestDue(now))
+	require.False(t, prefs.DigestDue(time.Date(2024, 3, 3, 7, 59, 0, 0, time.UTC)))
+	require.True(t, prefs.DigestDue(time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC)))
+
+	// yesterday's digest is still due before today's digest hour
+	sent = time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)
+	require.True(t, prefs.DigestDue(time.Date(2024, 3, 2, 7, 0, 0, 0, time.UTC)))
+}
+
+func TestNotificationPreferencesStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	notificationStore := store.NewNotificationStore(env.Db)
+
+	manager, err := userStore.CreateUser(ctx, "manager@test.com", "test")
+	require.NoError(t, err)
+
+	prefs, err := notificationStore.Preferences(ctx, manager.Id)
+	require.NoError(t, err)
+	require.Equal(t, []string{store.ChannelEmail, store.ChannelInApp}, prefs.ChannelsFor(store.NotifyReplied))
+
+	prefs.Channels[store.NotifyStatusChanged] = []string{}
+	prefs.Digest = true
+	prefs, err = notificationStore.SetPreferences(ctx, prefs)
+	require.NoError(t, err)
+	require.Empty(t, prefs.ChannelsFor(store.NotifyStatusChanged))
+	require.True(t, prefs.Digest)
+
+	require.NoError(t, notificationStore.ProcessEvent(ctx, 0, store.Notifications{
+		Emails: []store.EmailParams{
+			{UserId: manager.Id, To: manager.Email, Subject: "first", TextBody: "1", Digest: true},
+			{UserId: manager.Id, To: manager.Email, Subject: "second", TextBody: "2", Digest: true},
+		},
+		Chats: []store.ChatParams{
+			{UserId: manager.Id, Url: "https://chat.test/hook", Text: "first", NotBefore: time.Now().Add(time.Hour)},
+		},
+	}))
+
+	// held emails and chats delayed by quiet hours are not due
+	emails, err := notificationStore.ClaimDueEmails(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, emails)
+
+	chats, err := notificationStore.ClaimDueChats(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, chats)
+
+	held, err := notificationStore.HeldPreferences(ctx)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	require.Equal(t, manager.Id, held[0].UserId)
+
+	heldEmails, err := notificationStore.HeldEmails(ctx, manager.Id)
+	require.NoError(t, err)
+	require.Len(t, heldEmails, 2)
+	require.Equal(t, "first", heldEmails[0].Subject)
+
+	digest := store.EmailParams{UserId: manager.Id, To: manager.Email, Subject: "digest", TextBody: "1\n2"}
+	require.NoError(t, notificationStore.SendDigest(ctx, manager.Id, heldEmails, digest))
+	require.ErrorIs(t, notificationStore.SendDigest(ctx, manager.Id, heldEmails, digest), store.ErrDigestTaken)
+
+	emails, err = notificationStore.ClaimDueEmails(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, "digest", emails[0].Subject)
+
+	prefs, err = notificationStore.Preferences(ctx, manager.Id)
+	require.NoError(t, err)
+	require.NotNil(t, prefs.LastDigestAt)
+}
@@ -0,0 +1,117 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestEmailBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, store.EmailBackoff(1))
+	require.Equal(t, 4*time.Minute, store.EmailBackoff(3))
+	require.Equal(t, 2*time.Hour, store.EmailBackoff(store.EmailMaxAttempts+5))
+}
+
+func TestEventNotifies(t *testing.T) {
+	require.True(t, store.Event{Action: store.HistoryReplied}.Notifies())
+	require.False(t, store.Event{Action: store.HistoryReplyEdited, Details: store.HistoryDetails{}}.Notifies())
+	require.True(t, store.Event{Action: store.HistoryReplyEdited, Details: store.HistoryDetails{
+		"mentioned": []uuid.UUID{uuid.New()},
+	}}.Notifies())
+}
+
+func TestNotificationStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	notificationStore := store.NewNotificationStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	manager, err := userStore.CreateUser(ctx, "manager@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, notificationStore.Watch(ctx, ticket.Id, manager.Id))
+	require.NoError(t, notificationStore.Watch(ctx, ticket.Id, manager.Id))
+
+	watchers, err := notificationStore.Watchers(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, watchers, 1)
+	require.Equal(t, manager.Email, watchers[0].Email)
+
+	// a priority change alone notifies no one
+	require.NoError(t, ticketStore.Update(ctx, ticket.Id, store.UpdateTicketParams{
+		Priority: store.TicketPriorityHigh,
+		Status:   store.TicketStatusCreated,
+		Actor:    manager.Id,
+	}))
+
+	events, err := notificationStore.ClaimEvents(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, store.EventTicketCreated, events[0].Event.Type)
+	require.Equal(t, ticket.Id, events[0].Event.TicketId)
+
+	// claimed events are leased
+	again, err := notificationStore.ClaimEvents(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, again)
+
+	require.NoError(t, notificationStore.ProcessEvent(ctx, events[0].Id, store.Notifications{
+		Emails: []store.EmailParams{{
+			UserId:   manager.Id,
+			To:       manager.Email,
+			Subject:  "New ticket: broken lamp",
+			TextBody: "room 101",
+		}},
+	}))
+
+	emails, err := notificationStore.ClaimDueEmails(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, manager.Id, emails[0].UserId.UUID)
+
+	require.NoError(t, notificationStore.RecordEmailAttempt(ctx, &emails[0], errors.New("connection refused")))
+
+	sent, err := notificationStore.Emails(ctx, manager.Email, 10)
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	require.Equal(t, store.DeliveryPending, sent[0].Status)
+	require.Equal(t, 1, sent[0].Attempts)
+	require.Equal(t, "connection refused", sent[0].LastError)
+
+	require.NoError(t, notificationStore.RecordEmailAttempt(ctx, &sent[0], nil))
+
+	sent, err = notificationStore.Emails(ctx, manager.Email, 10)
+	require.NoError(t, err)
+	require.Equal(t, store.DeliverySucceeded, sent[0].Status)
+	require.NotNil(t, sent[0].SentAt)
+
+	require.NoError(t, notificationStore.Unwatch(ctx, ticket.Id, manager.Id))
+
+	watchers, err = notificationStore.Watchers(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Empty(t, watchers)
+}
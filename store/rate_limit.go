@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tatucosmin/hotel-system/config"
+)
+
+const (
+	RateLimitMemory   = "memory"
+	RateLimitPostgres = "postgres"
+)
+
+// RateLimitMaxWindow is the longest window a rate limit can have, buckets left alone
+// for longer are full again and are forgotten
+const RateLimitMaxWindow = 24 * time.Hour
+
+const rateLimitPruneInterval = 10 * time.Minute
+
+// RateLimit allows Limit requests per Window as a token bucket, which holds up to
+// Limit tokens and gets them back at an even pace over the window
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Limit, l.Window)
+}
+
+// refill is how many tokens the bucket gets back per second
+func (l RateLimit) refill() float64 {
+	return float64(l.Limit) / l.Window.Seconds()
+}
+
+// RateLimitResult is the state of a bucket after a request took a token from it, or tried to
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     RateLimit
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a refused request would be allowed
+	RetryAfter time.Duration
+}
+
+// RateLimiter keeps the token buckets of the rate limits, each key has its own bucket.
+// Refund gives back a token that was taken for a request refused by another limit.
+type RateLimiter interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+	Refund(ctx context.Context, key string, limit RateLimit) error
+}
+
+func NewRateLimiter(cfg *config.Config, db *sql.DB) (RateLimiter, error) {
+	switch cfg.RateLimitStore {
+	case RateLimitMemory:
+		return NewMemoryRateLimiter(), nil
+	case RateLimitPostgres:
+		return NewPgRateLimiter(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q, expected %s or %s", cfg.RateLimitStore, RateLimitMemory, RateLimitPostgres)
+	}
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// take refills the bucket for the time that passed and takes a token when there is one
+func (b bucket) take(limit RateLimit, now time.Time) (bucket, RateLimitResult) {
+	refill := limit.refill()
+
+	elapsed := max(now.Sub(b.updatedAt).Seconds(), 0)
+	b.tokens = min(float64(limit.Limit), b.tokens+elapsed*refill)
+	b.updatedAt = now
+
+	result := RateLimitResult{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / refill)
+	}
+
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = seconds((float64(limit.Limit) - b.tokens) / refill)
+	return b, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Ceil(s)) * time.Second
+}
+
+// MemoryRateLimiter keeps the buckets in the process, each server instance limits on its own
+type MemoryRateLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]bucket
+	prunedAt time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets: map[string]bucket{},
+	}
+}
+
+func (l *MemoryRateLimiter) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.prunedAt) > rateLimitPruneInterval {
+		for k, b := range l.buckets {
+			if now.Sub(b.updatedAt) > RateLimitMaxWindow {
+				delete(l.buckets, k)
+			}
+		}
+		l.prunedAt = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = bucket{tokens: float64(limit.Limit), updatedAt: now}
+	}
+
+	b, result := b.take(limit, now)
+	l.buckets[key] = b
+	return result, nil
+}
+
+func (l *MemoryRateLimiter) Refund(ctx context.Context, key string, limit RateLimit) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = min(float64(limit.Limit), b.tokens+1)
+		l.buckets[key] = b
+	}
+	return nil
+}
+
+// PgRateLimiter keeps the buckets in postgres so that all server instances share them
+type PgRateLimiter struct {
+	db *sqlx.DB
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+func NewPgRateLimiter(db *sql.DB) *PgRateLimiter {
+	return &PgRateLimiter{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+func (l *PgRateLimiter) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	if err := l.prune(ctx, now); err != nil {
+		return RateLimitResult{}, err
+	}
+
+	tx, err := l.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// a new bucket starts full, the no-op update locks an existing one so that
+	// concurrent requests take their tokens in turn
+	const query = `
+	INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3)
+	ON CONFLICT (key) DO UPDATE SET key = EXCLUDED.key
+	RETURNING tokens, updated_at`
+
+	var row struct {
+		Tokens    float64   `db:"tokens"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	if err := tx.GetContext(ctx, &row, query, key, limit.Limit, now); err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to get rate limit bucket %s: %w", key, err)
+	}
+
+	b, result := bucket{tokens: row.Tokens, updatedAt: row.UpdatedAt}.take(limit, now)
+
+	if _, err := tx.ExecContext(ctx, `UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1`,
+		key, b.tokens, b.updatedAt); err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to update rate limit bucket %s: %w", key, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to commit rate limit bucket %s: %w", key, err)
+	}
+
+	return result, nil
+}
+
+func (l *PgRateLimiter) Refund(ctx context.Context, key string, limit RateLimit) error {
+	if _, err := l.db.ExecContext(ctx, `UPDATE rate_limits SET tokens = LEAST(tokens + 1, $2) WHERE key = $1`,
+		key, limit.Limit); err != nil {
+		return fmt.Errorf("failed to refund rate limit bucket %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// prune forgets the buckets that have been full for a while, at most every rateLimitPruneInterval
+func (l *PgRateLimiter) prune(ctx context.Context, now time.Time) error {
+	l.mu.Lock()
+	due := now.Sub(l.prunedAt) > rateLimitPruneInterval
+	if due {
+		l.prunedAt = now
+	}
+	l.mu.Unlock()
+
+	if !due {
+		return nil
+	}
+
+	if _, err := l.db.ExecContext(ctx, `DELETE FROM rate_limits WHERE updated_at < $1`, now.Add(-RateLimitMaxWindow)); err != nil {
+		return fmt.Errorf("failed to prune rate limit buckets: %w", err)
+	}
+
+	return nil
+}
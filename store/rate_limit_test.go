@@ -0,0 +1,69 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func testRateLimiter(t *testing.T, limiter store.RateLimiter) {
+	ctx := context.Background()
+	limit := store.RateLimit{Limit: 3, Window: time.Minute}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := limiter.Take(ctx, "signin|ip:10.0.0.1", limit, now)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := limiter.Take(ctx, "signin|ip:10.0.0.1", limit, now)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 20*time.Second, result.RetryAfter)
+	require.Equal(t, time.Minute, result.Reset)
+
+	// a refunded token can be taken again
+	require.NoError(t, limiter.Refund(ctx, "signin|ip:10.0.0.1", limit))
+
+	result, err = limiter.Take(ctx, "signin|ip:10.0.0.1", limit, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	// other keys have their own bucket
+	result, err = limiter.Take(ctx, "signin|ip:10.0.0.2", limit, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// a token comes back every 20 seconds
+	result, err = limiter.Take(ctx, "signin|ip:10.0.0.1", limit, now.Add(20*time.Second))
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	// and the bucket never holds more than the limit
+	result, err = limiter.Take(ctx, "signin|ip:10.0.0.1", limit, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 2, result.Remaining)
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	testRateLimiter(t, store.NewMemoryRateLimiter())
+}
+
+func TestPgRateLimiter(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	testRateLimiter(t, store.NewPgRateLimiter(env.Db))
+}
@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	HistoryReplyEdited     = "reply_edited"
+	HistoryReplyDeleted    = "reply_deleted"
+	HistoryRevisionsPurged = "revisions_purged"
+)
+
+var ErrReplyDeleted = errors.New("reply has been deleted")
+
+// ReplyRevision is a message a reply had before it was edited or deleted
+type ReplyRevision struct {
+	Id         uuid.UUID     `db:"id"`
+	ReplyId    uuid.UUID     `db:"reply_id"`
+	Message    string        `db:"message"`
+	ReplacedBy uuid.NullUUID `db:"replaced_by"`
+	ReplacedAt time.Time     `db:"replaced_at"`
+}
+
+// Edit replaces the message of a reply and keeps the previous one as a revision.
+// The mentions are resolved again for the new message, users it mentions for the first
+// time become watchers and are notified. Deleted replies can't be edited, they return
+// ErrReplyDeleted.
+func (s *TicketReplyStore) Edit(ctx context.Context, replyId, actorId uuid.UUID, message string) (*TicketReply, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := keepRevision(ctx, tx, replyId, actorId); err != nil {
+		return nil, err
+	}
+
+	const query = `
+	UPDATE ticket_replies SET message = $2, edited_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING *`
+
+	var reply TicketReply
+	if err := tx.GetContext(ctx, &reply, query, replyId, message); err != nil {
+		return nil, fmt.Errorf("failed to edit ticket reply %v: %w", replyId, err)
+	}
+
+	var mentionedBefore []uuid.UUID
+	if err := tx.SelectContext(ctx, &mentionedBefore, `DELETE FROM reply_mentions WHERE reply_id = $1 RETURNING user_id`, replyId); err != nil {
+		return nil, fmt.Errorf("failed to remove mentions of reply %v: %w", replyId, err)
+	}
+
+	mentions, err := insertMentions(ctx, tx, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	details := HistoryDetails{
+		"reply_id": reply.Id,
+	}
+
+	// only the users the edit mentions for the first time are notified
+	var mentioned []uuid.UUID
+	for _, mention := range mentions {
+		if !slices.Contains(mentionedBefore, mention.UserId) {
+			mentioned = append(mentioned, mention.UserId)
+		}
+	}
+	if len(mentioned) > 0 {
+		details["mentioned"] = mentioned
+	}
+
+	if err := insertHistory(ctx, tx, reply.TicketId, actorId, HistoryReplyEdited, details); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ticket reply: %w", err)
+	}
+
+	return &reply, nil
+}
+
+// Delete leaves a tombstone of the reply in the thread, without its message or mentions.
+// The message is kept as a revision.
+func (s *TicketReplyStore) Delete(ctx context.Context, replyId, actorId uuid.UUID) (*TicketReply, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := keepRevision(ctx, tx, replyId, actorId); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM reply_mentions WHERE reply_id = $1`, replyId); err != nil {
+		return nil, fmt.Errorf("failed to remove mentions of reply %v: %w", replyId, err)
+	}
+
+	const query = `
+	UPDATE ticket_replies SET message = '', deleted_at = CURRENT_TIMESTAMP WHERE id = $1 RETURNING *`
+
+	var reply TicketReply
+	if err := tx.GetContext(ctx, &reply, query, replyId); err != nil {
+		return nil, fmt.Errorf("failed to delete ticket reply %v: %w", replyId, err)
+	}
+
+	if err := insertHistory(ctx, tx, reply.TicketId, actorId, HistoryReplyDeleted, HistoryDetails{
+		"reply_id": reply.Id,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ticket reply: %w", err)
+	}
+
+	return &reply, nil
+}
+
+// keepRevision locks the reply for the change and stores its current message as a revision
+func keepRevision(ctx context.Context, tx *sqlx.Tx, replyId, actorId uuid.UUID) error {
+	var current TicketReply
+	if err := tx.GetContext(ctx, &current, `SELECT * FROM ticket_replies WHERE id = $1 FOR UPDATE`, replyId); err != nil {
+		return fmt.Errorf("failed to get ticket reply with id %v: %w", replyId, err)
+	}
+
+	if current.DeletedAt != nil {
+		return ErrReplyDeleted
+	}
+
+	const query = `
+	INSERT INTO reply_revisions (reply_id, message, replaced_by) VALUES ($1, $2, (SELECT id FROM users WHERE id = $3))`
+
+	if _, err := tx.ExecContext(ctx, query, replyId, current.Message, actorId); err != nil {
+		return fmt.Errorf("failed to keep revision of reply %v: %w", replyId, err)
+	}
+
+	return nil
+}
+
+// Revisions lists the previous messages of a reply, oldest first
+func (s *TicketReplyStore) Revisions(ctx context.Context, replyId uuid.UUID) ([]ReplyRevision, error) {
+
+	const query = `
+	SELECT * FROM reply_revisions WHERE reply_id = $1 ORDER BY replaced_at ASC, id ASC`
+
+	revisions := []ReplyRevision{}
+	if err := s.db.SelectContext(ctx, &revisions, query, replyId); err != nil {
+		return nil, fmt.Errorf("failed to get revisions of reply %v: %w", replyId, err)
+	}
+
+	return revisions, nil
+}
+
+// PurgeRevisions removes every previous message of a reply, for messages that must not be
+// kept such as card numbers, and reports how many there were
+func (s *TicketReplyStore) PurgeRevisions(ctx context.Context, replyId, actorId uuid.UUID) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ticketId uuid.UUID
+	if err := tx.GetContext(ctx, &ticketId, `SELECT ticket_id FROM ticket_replies WHERE id = $1 FOR UPDATE`, replyId); err != nil {
+		return 0, fmt.Errorf("failed to get ticket reply with id %v: %w", replyId, err)
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM reply_revisions WHERE reply_id = $1`, replyId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge revisions of reply %v: %w", replyId, err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged revisions of reply %v: %w", replyId, err)
+	}
+
+	if err := insertHistory(ctx, tx, ticketId, actorId, HistoryRevisionsPurged, HistoryDetails{
+		"reply_id": replyId,
+		"purged":   purged,
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purged revisions: %w", err)
+	}
+
+	return purged, nil
+}
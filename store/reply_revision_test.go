@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestReplyRevisions(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+	historyStore := store.NewTicketHistoryStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "late checkout",
+		Description: "can we stay until 14:00?",
+		Category:    "front desk",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+
+	reply, err := replyStore.Create(ctx, ticket.Id, guest.Id, "my card number is 4111 1111 1111 1111")
+	require.NoError(t, err)
+	require.Nil(t, reply.EditedAt)
+
+	edited, err := replyStore.Edit(ctx, reply.Id, guest.Id, "my card ends in 1111")
+	require.NoError(t, err)
+	require.Equal(t, "my card ends in 1111", edited.Message)
+	require.NotNil(t, edited.EditedAt)
+
+	deleted, err := replyStore.Delete(ctx, reply.Id, guest.Id)
+	require.NoError(t, err)
+	require.Empty(t, deleted.Message)
+	require.NotNil(t, deleted.DeletedAt)
+
+	_, err = replyStore.Edit(ctx, reply.Id, guest.Id, "again")
+	require.ErrorIs(t, err, store.ErrReplyDeleted)
+
+	_, err = replyStore.Delete(ctx, reply.Id, guest.Id)
+	require.ErrorIs(t, err, store.ErrReplyDeleted)
+
+	// the tombstone stays in the thread
+	replies, err := replyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, *replies, 1)
+	require.NotNil(t, (*replies)[0].DeletedAt)
+
+	revisions, err := replyStore.Revisions(ctx, reply.Id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, "my card number is 4111 1111 1111 1111", revisions[0].Message)
+	require.Equal(t, "my card ends in 1111", revisions[1].Message)
+	require.Equal(t, guest.Id, revisions[1].ReplacedBy.UUID)
+
+	history, err := historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Equal(t, store.HistoryReplyDeleted, history[len(history)-1].Action)
+	require.Equal(t, store.HistoryReplyEdited, history[len(history)-2].Action)
+
+	// the card number must not be kept, admins purge the revisions
+	purged, err := replyStore.PurgeRevisions(ctx, reply.Id, guest.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, purged)
+
+	revisions, err = replyStore.Revisions(ctx, reply.Id)
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+
+	history, err = historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Equal(t, store.HistoryRevisionsPurged, history[len(history)-1].Action)
+
+	_, err = replyStore.PurgeRevisions(ctx, uuid.New(), guest.Id)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
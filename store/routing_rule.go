@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type RoutingRuleStore struct {
+	db *sqlx.DB
+}
+
+func NewRoutingRuleStore(db *sql.DB) *RoutingRuleStore {
+	return &RoutingRuleStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+const RuleTimeLayout = "15:04"
+
+// RuleConditions must all hold for a rule to match, empty conditions are ignored
+type RuleConditions struct {
+	// Keywords match when any of them appears in the title or description, ignoring case
+	Keywords   []string         `json:"keywords,omitempty"`
+	Categories []string         `json:"categories,omitempty"`
+	Priorities []TicketPriority `json:"priorities,omitempty"`
+	// CreatorRoles matches when the creator has any of the roles
+	CreatorRoles UserRole `json:"creator_roles,omitempty"`
+	// TimeFrom and TimeTo bound the time of day in the hotel's timezone formatted as
+	// RuleTimeLayout, a window where TimeFrom is after TimeTo spans midnight
+	TimeFrom string `json:"time_from,omitempty"`
+	TimeTo   string `json:"time_to,omitempty"`
+}
+
+func (c RuleConditions) Value() (driver.Value, error) {
+	return jsonValue(c)
+}
+
+func (c *RuleConditions) Scan(src any) error {
+	return jsonScan(src, c)
+}
+
+func (c RuleConditions) Validate() error {
+	if (c.TimeFrom == "") != (c.TimeTo == "") {
+		return errors.New("time_from and time_to have to be set together")
+	}
+
+	for _, value := range []string{c.TimeFrom, c.TimeTo} {
+		if _, err := time.Parse(RuleTimeLayout, value); value != "" && err != nil {
+			return fmt.Errorf("time %q must be formatted as HH:MM", value)
+		}
+	}
+
+	for _, priority := range c.Priorities {
+		if !priority.WithinBounds() {
+			return errors.New("priorities contain an invalid priority")
+		}
+	}
+
+	return nil
+}
+
+type RuleActions struct {
+	AssignUser  *uuid.UUID      `json:"assign_user,omitempty"`
+	AssignTeam  *uuid.UUID      `json:"assign_team,omitempty"`
+	SetPriority *TicketPriority `json:"set_priority,omitempty"`
+	AddTags     []string        `json:"add_tags,omitempty"`
+}
+
+func (a RuleActions) Value() (driver.Value, error) {
+	return jsonValue(a)
+}
+
+func (a *RuleActions) Scan(src any) error {
+	return jsonScan(src, a)
+}
+
+func (a RuleActions) Validate() error {
+	if a.AssignUser == nil && a.AssignTeam == nil && a.SetPriority == nil && len(a.AddTags) == 0 {
+		return errors.New("a rule needs at least one action")
+	}
+
+	if a.SetPriority != nil && !a.SetPriority.WithinBounds() {
+		return errors.New("set_priority is invalid")
+	}
+
+	return nil
+}
+
+type RoutingRule struct {
+	Id             uuid.UUID      `db:"id"`
+	Name           string         `db:"name"`
+	Position       int            `db:"position"`
+	Enabled        bool           `db:"enabled"`
+	StopProcessing bool           `db:"stop_processing"`
+	Conditions     RuleConditions `db:"conditions"`
+	Actions        RuleActions    `db:"actions"`
+	CreatedAt      time.Time      `db:"created_at"`
+	UpdatedAt      time.Time      `db:"updated_at"`
+}
+
+type RoutingRuleParams struct {
+	Name           string
+	Position       int
+	Enabled        bool
+	StopProcessing bool
+	Conditions     RuleConditions
+	Actions        RuleActions
+}
+
+// RuleInput is what the rules are evaluated against when a ticket is created
+type RuleInput struct {
+	Title        string
+	Description  string
+	Category     string
+	Priority     TicketPriority
+	CreatorRoles UserRole
+	// At is when the ticket is created, in the timezone the time windows are in
+	At time.Time
+}
+
+func (r *RoutingRule) Matches(input RuleInput) bool {
+	c := r.Conditions
+
+	if len(c.Keywords) > 0 {
+		text := strings.ToLower(input.Title + "\n" + input.Description)
+		if !slices.ContainsFunc(c.Keywords, func(keyword string) bool {
+			return strings.Contains(text, strings.ToLower(keyword))
+		}) {
+			return false
+		}
+	}
+
+	if len(c.Categories) > 0 && !slices.Contains(c.Categories, input.Category) {
+		return false
+	}
+
+	if len(c.Priorities) > 0 && !slices.Contains(c.Priorities, input.Priority) {
+		return false
+	}
+
+	if c.CreatorRoles != 0 && input.CreatorRoles&c.CreatorRoles == 0 {
+		return false
+	}
+
+	if c.TimeFrom != "" && c.TimeTo != "" {
+		from, errFrom := time.Parse(RuleTimeLayout, c.TimeFrom)
+		to, errTo := time.Parse(RuleTimeLayout, c.TimeTo)
+		if errFrom != nil || errTo != nil {
+			return false
+		}
+
+		minute := input.At.Hour()*60 + input.At.Minute()
+		start := from.Hour()*60 + from.Minute()
+		end := to.Hour()*60 + to.Minute()
+
+		if start <= end && (minute < start || minute >= end) {
+			return false
+		}
+
+		if start > end && minute < start && minute >= end {
+			return false
+		}
+	}
+
+	return true
+}
+
+type RuleOutcome struct {
+	Matched  []RoutingRule
+	Assignee uuid.NullUUID
+	Team     uuid.NullUUID
+	Priority TicketPriority
+	Tags     []string
+}
+
+// EvaluateRules runs the rules in order, every matching rule applies its actions
+// on top of the previous ones and later rules see the updated priority
+func EvaluateRules(rules []RoutingRule, input RuleInput) RuleOutcome {
+	outcome := RuleOutcome{
+		Priority: input.Priority,
+		Tags:     []string{},
+	}
+
+	for _, rule := range rules {
+		if !rule.Enabled || !rule.Matches(input) {
+			continue
+		}
+
+		outcome.Matched = append(outcome.Matched, rule)
+
+		if rule.Actions.AssignUser != nil {
+			outcome.Assignee = uuid.NullUUID{UUID: *rule.Actions.AssignUser, Valid: true}
+		}
+
+		if rule.Actions.AssignTeam != nil {
+			outcome.Team = uuid.NullUUID{UUID: *rule.Actions.AssignTeam, Valid: true}
+		}
+
+		if rule.Actions.SetPriority != nil {
+			outcome.Priority = *rule.Actions.SetPriority
+			input.Priority = outcome.Priority
+		}
+
+		for _, tag := range rule.Actions.AddTags {
+			if !slices.Contains(outcome.Tags, tag) {
+				outcome.Tags = append(outcome.Tags, tag)
+			}
+		}
+
+		if rule.StopProcessing {
+			break
+		}
+	}
+
+	return outcome
+}
+
+func (s *RoutingRuleStore) Create(ctx context.Context, params RoutingRuleParams) (*RoutingRule, error) {
+
+	const query = `
+	INSERT INTO routing_rules (name, position, enabled, stop_processing, conditions, actions)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`
+
+	var rule RoutingRule
+	if err := s.db.GetContext(ctx, &rule, query, params.Name, params.Position, params.Enabled,
+		params.StopProcessing, params.Conditions, params.Actions); err != nil {
+		return nil, fmt.Errorf("failed to create routing rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+func (s *RoutingRuleStore) Update(ctx context.Context, ruleId uuid.UUID, params RoutingRuleParams) (*RoutingRule, error) {
+
+	const query = `
+	UPDATE routing_rules SET name = $2, position = $3, enabled = $4, stop_processing = $5,
+	conditions = $6, actions = $7, updated_at = $8 WHERE id = $1 RETURNING *`
+
+	var rule RoutingRule
+	if err := s.db.GetContext(ctx, &rule, query, ruleId, params.Name, params.Position, params.Enabled,
+		params.StopProcessing, params.Conditions, params.Actions, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update routing rule with id %v: %w", ruleId, err)
+	}
+
+	return &rule, nil
+}
+
+func (s *RoutingRuleStore) Delete(ctx context.Context, ruleId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM routing_rules WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, ruleId); err != nil {
+		return fmt.Errorf("failed to delete routing rule with id %v: %w", ruleId, err)
+	}
+
+	return nil
+}
+
+// All returns the rules in the order they are evaluated in
+func (s *RoutingRuleStore) All(ctx context.Context) ([]RoutingRule, error) {
+	return allRoutingRules(ctx, s.db, false)
+}
+
+func allRoutingRules(ctx context.Context, q sqlx.QueryerContext, enabledOnly bool) ([]RoutingRule, error) {
+
+	const query = `
+	SELECT * FROM routing_rules WHERE enabled OR NOT $1 ORDER BY position ASC, created_at ASC`
+
+	var rules []RoutingRule
+	if err := sqlx.SelectContext(ctx, q, &rules, query, enabledOnly); err != nil {
+		return nil, fmt.Errorf("failed to get routing rules: %w", err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,122 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestEvaluateRules(t *testing.T) {
+	assignee := uuid.New()
+	urgent := store.TicketPriorityUrgent
+
+	rules := []store.RoutingRule{
+		{
+			Name:       "leaks are urgent",
+			Enabled:    true,
+			Conditions: store.RuleConditions{Keywords: []string{"leak", "flood"}},
+			Actions:    store.RuleActions{SetPriority: &urgent, AddTags: []string{"water"}},
+		},
+		{
+			Name:       "night shift takes urgent maintenance",
+			Enabled:    true,
+			Conditions: store.RuleConditions{Priorities: []store.TicketPriority{urgent}, TimeFrom: "22:00", TimeTo: "06:00"},
+			Actions:    store.RuleActions{AssignUser: &assignee},
+		},
+		{
+			Name:       "disabled",
+			Enabled:    false,
+			Conditions: store.RuleConditions{},
+			Actions:    store.RuleActions{AddTags: []string{"never"}},
+		},
+	}
+
+	night := time.Date(2025, 1, 1, 23, 30, 0, 0, time.UTC)
+	outcome := store.EvaluateRules(rules, store.RuleInput{
+		Title:        "Water LEAK in bathroom",
+		Priority:     store.TicketPriorityLow,
+		CreatorRoles: store.RoleCustomer,
+		At:           night,
+	})
+	require.Len(t, outcome.Matched, 2)
+	require.Equal(t, store.TicketPriorityUrgent, outcome.Priority)
+	require.Equal(t, []string{"water"}, outcome.Tags)
+	require.True(t, outcome.Assignee.Valid)
+	require.Equal(t, assignee, outcome.Assignee.UUID)
+
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	outcome = store.EvaluateRules(rules, store.RuleInput{
+		Title:    "Water leak in bathroom",
+		Priority: store.TicketPriorityLow,
+		At:       day,
+	})
+	require.Len(t, outcome.Matched, 1)
+	require.False(t, outcome.Assignee.Valid)
+
+	rules[0].StopProcessing = true
+	outcome = store.EvaluateRules(rules, store.RuleInput{Title: "leak", At: night})
+	require.Len(t, outcome.Matched, 1)
+
+	outcome = store.EvaluateRules(rules, store.RuleInput{Title: "Extra towels", Priority: store.TicketPriorityLow, At: night})
+	require.Empty(t, outcome.Matched)
+	require.Equal(t, store.TicketPriorityLow, outcome.Priority)
+}
+
+func TestRoutingRuleStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	ruleStore := store.NewRoutingRuleStore(env.Db)
+	historyStore := store.NewTicketHistoryStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	rule, err := ruleStore.Create(ctx, store.RoutingRuleParams{
+		Name:       "housekeeping",
+		Enabled:    true,
+		Conditions: store.RuleConditions{Categories: []string{"housekeeping"}, CreatorRoles: store.RoleCustomer},
+		Actions:    store.RuleActions{AssignUser: &staff.Id, AddTags: []string{"hk"}},
+	})
+	require.NoError(t, err)
+
+	rules, err := ruleStore.All(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, []string{"housekeeping"}, rules[0].Conditions.Categories)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "Extra towels",
+		Description: "Room 204",
+		Category:    "housekeeping",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+	require.Equal(t, staff.Id, ticket.CurrentAssignee)
+	require.Equal(t, []string{"hk"}, []string(ticket.Tags))
+
+	history, err := historyStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, store.HistoryCreated, history[0].Action)
+	require.Equal(t, store.HistoryRuleApplied, history[1].Action)
+	require.Equal(t, rule.Id.String(), history[1].Details["rule_id"])
+
+	require.NoError(t, ruleStore.Delete(ctx, rule.Id))
+}
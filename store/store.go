@@ -1,19 +1,53 @@
 package store
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+)
 
 type Store struct {
 	User         *UserStore
 	RefreshToken *RefreshTokenStore
 	Ticket       *TicketStore
 	TicketReply  *TicketReplyStore
+	Template     *TicketTemplateStore
+	CustomField  *CustomFieldStore
+	Macro        *MacroStore
+	RoutingRule  *RoutingRuleStore
+	History      *TicketHistoryStore
+	Queue        *AssignmentQueueStore
+	Team         *TeamStore
+	Rating       *TicketRatingStore
+	Metrics      *MetricsStore
+	Webhook      *WebhookStore
+	Notification *NotificationStore
+	Attachment   *TicketAttachmentStore
+	Schedule     *TicketScheduleStore
+	WorkLog      *WorkLogStore
+	InboundEmail *InboundEmailStore
 }
 
-func New(db *sql.DB) *Store {
+// New creates the stores, location is the timezone of the hotel
+func New(db *sql.DB, location *time.Location) *Store {
 	return &Store{
 		User:         NewUserStore(db),
 		RefreshToken: NewRefreshTokenStore(db),
-		Ticket:       NewTicketStore(db),
+		Ticket:       NewTicketStore(db, location),
 		TicketReply:  NewTicketReplyStore(db),
+		Template:     NewTicketTemplateStore(db),
+		CustomField:  NewCustomFieldStore(db),
+		Macro:        NewMacroStore(db),
+		RoutingRule:  NewRoutingRuleStore(db),
+		History:      NewTicketHistoryStore(db),
+		Queue:        NewAssignmentQueueStore(db),
+		Team:         NewTeamStore(db),
+		Rating:       NewTicketRatingStore(db),
+		Metrics:      NewMetricsStore(db),
+		Webhook:      NewWebhookStore(db),
+		Notification: NewNotificationStore(db),
+		Attachment:   NewTicketAttachmentStore(db),
+		Schedule:     NewTicketScheduleStore(db, location),
+		WorkLog:      NewWorkLogStore(db),
+		InboundEmail: NewInboundEmailStore(db),
 	}
 }
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TeamStore struct {
+	db *sqlx.DB
+}
+
+func NewTeamStore(db *sql.DB) *TeamStore {
+	return &TeamStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+type Team struct {
+	Id          uuid.UUID `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type TeamMember struct {
+	TeamId    uuid.UUID `db:"team_id"`
+	UserId    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email"`
+	Lead      bool      `db:"lead"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (s *TeamStore) Create(ctx context.Context, name, description string) (*Team, error) {
+
+	const query = `
+	INSERT INTO teams (name, description) VALUES ($1, $2) RETURNING *`
+
+	var team Team
+	if err := s.db.GetContext(ctx, &team, query, name, description); err != nil {
+		return nil, fmt.Errorf("failed to create team: %w", err)
+	}
+
+	return &team, nil
+}
+
+func (s *TeamStore) Update(ctx context.Context, teamId uuid.UUID, name, description string) (*Team, error) {
+
+	const query = `
+	UPDATE teams SET name = $2, description = $3 WHERE id = $1 RETURNING *`
+
+	var team Team
+	if err := s.db.GetContext(ctx, &team, query, teamId, name, description); err != nil {
+		return nil, fmt.Errorf("failed to update team with id %v: %w", teamId, err)
+	}
+
+	return &team, nil
+}
+
+func (s *TeamStore) Delete(ctx context.Context, teamId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM teams WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, teamId); err != nil {
+		return fmt.Errorf("failed to delete team with id %v: %w", teamId, err)
+	}
+
+	return nil
+}
+
+func (s *TeamStore) ById(ctx context.Context, teamId uuid.UUID) (*Team, error) {
+
+	const query = `
+	SELECT * FROM teams WHERE id = $1`
+
+	var team Team
+	if err := s.db.GetContext(ctx, &team, query, teamId); err != nil {
+		return nil, fmt.Errorf("failed to get team with id %v: %w", teamId, err)
+	}
+
+	return &team, nil
+}
+
+func (s *TeamStore) All(ctx context.Context) ([]Team, error) {
+
+	const query = `
+	SELECT * FROM teams ORDER BY name ASC`
+
+	var teams []Team
+	if err := s.db.SelectContext(ctx, &teams, query); err != nil {
+		return nil, fmt.Errorf("failed to get all teams: %w", err)
+	}
+
+	return teams, nil
+}
+
+// ByUser returns the teams the user is a member of
+func (s *TeamStore) ByUser(ctx context.Context, userId uuid.UUID) ([]Team, error) {
+
+	const query = `
+	SELECT t.* FROM teams t JOIN team_members m ON m.team_id = t.id
+	WHERE m.user_id = $1 ORDER BY t.name ASC`
+
+	var teams []Team
+	if err := s.db.SelectContext(ctx, &teams, query, userId); err != nil {
+		return nil, fmt.Errorf("failed to get teams of user %v: %w", userId, err)
+	}
+
+	return teams, nil
+}
+
+// AddMember adds the user to the team or updates whether they lead it
+func (s *TeamStore) AddMember(ctx context.Context, teamId, userId uuid.UUID, lead bool) error {
+
+	const query = `
+	INSERT INTO team_members (team_id, user_id, lead) VALUES ($1, $2, $3)
+	ON CONFLICT (team_id, user_id) DO UPDATE SET lead = EXCLUDED.lead`
+
+	if _, err := s.db.ExecContext(ctx, query, teamId, userId, lead); err != nil {
+		return fmt.Errorf("failed to add user %v to team %v: %w", userId, teamId, err)
+	}
+
+	return nil
+}
+
+func (s *TeamStore) RemoveMember(ctx context.Context, teamId, userId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, teamId, userId); err != nil {
+		return fmt.Errorf("failed to remove user %v from team %v: %w", userId, teamId, err)
+	}
+
+	return nil
+}
+
+func (s *TeamStore) Members(ctx context.Context, teamId uuid.UUID) ([]TeamMember, error) {
+
+	const query = `
+	SELECT m.team_id, m.user_id, u.email, m.lead, m.created_at
+	FROM team_members m JOIN users u ON u.id = m.user_id
+	WHERE m.team_id = $1 ORDER BY m.lead DESC, u.email ASC`
+
+	var members []TeamMember
+	if err := s.db.SelectContext(ctx, &members, query, teamId); err != nil {
+		return nil, fmt.Errorf("failed to get members of team %v: %w", teamId, err)
+	}
+
+	return members, nil
+}
+
+// Membership reports whether the user belongs to the team and whether they lead it
+func (s *TeamStore) Membership(ctx context.Context, teamId, userId uuid.UUID) (member bool, lead bool, err error) {
+
+	const query = `
+	SELECT lead FROM team_members WHERE team_id = $1 AND user_id = $2`
+
+	if err := s.db.GetContext(ctx, &lead, query, teamId, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("failed to get membership of user %v in team %v: %w", userId, teamId, err)
+	}
+
+	return true, lead, nil
+}
@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTeamStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	teamStore := store.NewTeamStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	ruleStore := store.NewRoutingRuleStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	team, err := teamStore.Create(ctx, "Maintenance", "Repairs and equipment")
+	require.NoError(t, err)
+
+	require.NoError(t, teamStore.AddMember(ctx, team.Id, staff.Id, false))
+	require.NoError(t, teamStore.AddMember(ctx, team.Id, staff.Id, true))
+
+	member, lead, err := teamStore.Membership(ctx, team.Id, staff.Id)
+	require.NoError(t, err)
+	require.True(t, member)
+	require.True(t, lead)
+
+	member, _, err = teamStore.Membership(ctx, team.Id, guest.Id)
+	require.NoError(t, err)
+	require.False(t, member)
+
+	members, err := teamStore.Members(ctx, team.Id)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	require.Equal(t, "staff@test.com", members[0].Email)
+
+	teams, err := teamStore.ByUser(ctx, staff.Id)
+	require.NoError(t, err)
+	require.Len(t, teams, 1)
+
+	_, err = ruleStore.Create(ctx, store.RoutingRuleParams{
+		Name:       "repairs go to maintenance",
+		Enabled:    true,
+		Conditions: store.RuleConditions{Keywords: []string{"broken"}},
+		Actions:    store.RuleActions{AssignTeam: &team.Id},
+	})
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "Broken AC",
+		Description: "Room 204",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityHigh,
+	})
+	require.NoError(t, err)
+	require.Equal(t, team.Id, ticket.TeamId)
+
+	ticket, err = ticketStore.Assign(ctx, ticket.Id, staff.Id, staff.Id, team.Id, false)
+	require.NoError(t, err)
+	require.Equal(t, staff.Id, ticket.CurrentAssignee)
+
+	// reassigning without a team keeps the ticket on its team
+	ticket, err = ticketStore.Assign(ctx, ticket.Id, staff.Id, staff.Id, uuid.Nil, false)
+	require.NoError(t, err)
+	require.Equal(t, team.Id, ticket.TeamId)
+
+	tickets, err := ticketStore.All(ctx, store.TicketFilter{TeamId: team.Id})
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+
+	cleared, err := ticketStore.Assign(ctx, ticket.Id, staff.Id, staff.Id, uuid.Nil, true)
+	require.NoError(t, err)
+	require.Equal(t, uuid.Nil, cleared.TeamId)
+
+	_, err = ticketStore.Assign(ctx, ticket.Id, staff.Id, staff.Id, team.Id, false)
+	require.NoError(t, err)
+
+	require.NoError(t, teamStore.RemoveMember(ctx, team.Id, staff.Id))
+	require.NoError(t, teamStore.Delete(ctx, team.Id))
+
+	ticket, err = ticketStore.ById(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Equal(t, uuid.Nil, ticket.TeamId)
+}
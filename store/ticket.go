@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type TicketPriority int
@@ -46,6 +47,8 @@ func (s TicketStatus) WithinBounds() bool {
 
 type TicketStore struct {
 	db *sqlx.DB
+	// location is the timezone of the hotel, routing rules match the time of day in it
+	location *time.Location
 }
 
 type Ticket struct {
@@ -58,24 +61,195 @@ type Ticket struct {
 	UpdatedAt       time.Time      `db:"updated_at"`
 	Priority        TicketPriority `db:"priority"`
 	Status          TicketStatus   `db:"status"`
+	Category        string         `db:"category"`
+	CustomFields    CustomFields   `db:"custom_fields"`
+	Tags            pq.StringArray `db:"tags"`
+	TeamId          uuid.UUID      `db:"team_id"`
+	ResolvedAt      *time.Time     `db:"resolved_at"`
+	DueAt           *time.Time     `db:"due_at"`
 }
 
-func NewTicketStore(db *sql.DB) *TicketStore {
+// Overdue reports whether the ticket is past its due date without being done
+func (t *Ticket) Overdue(now time.Time) bool {
+	return t.DueAt != nil && t.DueAt.Before(now) && t.Status < TicketStatusDone
+}
+
+type CreateTicketParams struct {
+	Title        string
+	Description  string
+	Category     string
+	Creator      uuid.UUID
+	Priority     TicketPriority
+	CustomFields CustomFields
+	// TeamId is optional, routing rules and assignment queues may also pick the team
+	TeamId uuid.UUID
+	// Assignee is optional, it takes precedence over the assignee picked by routing rules and queues
+	Assignee uuid.UUID
+	DueAt    *time.Time
+	// FixedPriority and FixedTeam are optional and take precedence over the priority and team
+	// set by routing rules, scheduled tickets keep the ones of their schedule with them
+	FixedPriority *TicketPriority
+	FixedTeam     uuid.UUID
+}
+
+type UpdateTicketParams struct {
+	// Actor is who made the change, it ends up in the ticket history
+	Actor    uuid.UUID
+	Priority TicketPriority
+	Status   TicketStatus
+	// CustomFields replaces the stored values when it isn't nil
+	CustomFields CustomFields
+	// DueAt replaces the due date when it isn't nil, ClearDueAt removes it
+	DueAt      *time.Time
+	ClearDueAt bool
+}
+
+type TicketFilter struct {
+	Category     string
+	CustomFields map[string]string
+	TeamId       uuid.UUID
+	// DueBefore and DueAfter keep the tickets due in the range, either end may be left open
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// Overdue keeps the tickets past their due date that are not done
+	Overdue bool
+	// Sort is one of TicketSorts, created_at by default
+	Sort string
+}
+
+// TicketSorts are the orders tickets can be listed in, a leading - reverses them.
+// Tickets without a due date come last when sorting by it.
+var TicketSorts = map[string]string{
+	"created_at":  "created_at ASC",
+	"-created_at": "created_at DESC",
+	"due_at":      "due_at ASC NULLS LAST, created_at ASC",
+	"-due_at":     "due_at DESC NULLS LAST, created_at ASC",
+}
+
+func NewTicketStore(db *sql.DB, location *time.Location) *TicketStore {
 	return &TicketStore{
-		db: sqlx.NewDb(db, "postgres"),
+		db:       sqlx.NewDb(db, "postgres"),
+		location: location,
 	}
 }
 
-func (s *TicketStore) Create(ctx context.Context, title, description string, creatorId uuid.UUID, priority TicketPriority) (*Ticket, error) {
+// Create inserts the ticket after running it through the enabled routing rules and,
+// when no rule assigned it, the assignment queues. Both are recorded in the ticket history.
+func (s *TicketStore) Create(ctx context.Context, params CreateTicketParams) (*Ticket, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ticket, err := createTicket(ctx, tx, params, time.Now().In(s.location))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ticket: %w", err)
+	}
+
+	return ticket, nil
+}
+
+// createTicket runs the ticket through the rules as created at now, in the timezone of the hotel
+func createTicket(ctx context.Context, tx *sqlx.Tx, params CreateTicketParams, now time.Time) (*Ticket, error) {
+	var creatorRoles UserRole
+	if err := tx.GetContext(ctx, &creatorRoles, `SELECT roles FROM users WHERE id = $1`, params.Creator); err != nil {
+		return nil, fmt.Errorf("failed to get roles of creator %v: %w", params.Creator, err)
+	}
+
+	rules, err := allRoutingRules(ctx, tx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := EvaluateRules(rules, RuleInput{
+		Title:        params.Title,
+		Description:  params.Description,
+		Category:     params.Category,
+		Priority:     params.Priority,
+		CreatorRoles: creatorRoles,
+		At:           now,
+	})
+
+	if !outcome.Team.Valid && params.TeamId != uuid.Nil {
+		outcome.Team = uuid.NullUUID{UUID: params.TeamId, Valid: true}
+	}
+
+	if params.FixedPriority != nil {
+		outcome.Priority = *params.FixedPriority
+	}
+
+	if params.FixedTeam != uuid.Nil {
+		outcome.Team = uuid.NullUUID{UUID: params.FixedTeam, Valid: true}
+	}
+
+	if params.Assignee != uuid.Nil {
+		outcome.Assignee = uuid.NullUUID{UUID: params.Assignee, Valid: true}
+	}
 
+	// tickets the rules left unassigned go through the assignment queues
+	var queue *AssignmentQueue
+	if !outcome.Assignee.Valid {
+		queue, outcome.Assignee, err = autoAssign(ctx, tx, params.Category, outcome.Team)
+		if err != nil {
+			return nil, err
+		}
+
+		if queue != nil && !outcome.Team.Valid && queue.TeamId != uuid.Nil {
+			outcome.Team = uuid.NullUUID{UUID: queue.TeamId, Valid: true}
+		}
+	}
+
+	// rules may point at users or teams that were removed since, those assignments are dropped
 	const query = `
-	INSERT INTO tickets (title, description, creator, priority) VALUES ($1, $2, $3, $4) RETURNING *`
+	INSERT INTO tickets (title, description, creator, priority, category, custom_fields, tags, current_assignee, team_id, due_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, (SELECT id FROM users WHERE id = $8), (SELECT id FROM teams WHERE id = $9), $10)
+	RETURNING *`
 
 	var ticket Ticket
-	if err := s.db.GetContext(ctx, &ticket, query, title, description, creatorId, priority); err != nil {
+	if err := tx.GetContext(ctx, &ticket, query, params.Title, params.Description, params.Creator, outcome.Priority,
+		params.Category, params.CustomFields, pq.StringArray(outcome.Tags), outcome.Assignee, outcome.Team, params.DueAt); err != nil {
 		return nil, fmt.Errorf("failed to create ticket: %w", err)
 	}
 
+	if err := insertHistory(ctx, tx, ticket.Id, params.Creator, HistoryCreated, nil); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range outcome.Matched {
+		if err := insertHistory(ctx, tx, ticket.Id, uuid.Nil, HistoryRuleApplied, HistoryDetails{
+			"rule_id":   rule.Id,
+			"rule_name": rule.Name,
+			"actions":   rule.Actions,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if queue != nil && outcome.Assignee.Valid {
+		if err := insertHistory(ctx, tx, ticket.Id, uuid.Nil, HistoryAutoAssigned, HistoryDetails{
+			"queue_id":   queue.Id,
+			"queue_name": queue.Name,
+			"strategy":   queue.Strategy,
+			"assignee":   outcome.Assignee.UUID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Assignee != uuid.Nil && ticket.CurrentAssignee == params.Assignee {
+		if err := insertHistory(ctx, tx, ticket.Id, uuid.Nil, HistoryAssigned, HistoryDetails{
+			"assignee": outcome.Assignee,
+			"team":     outcome.Team,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
 	return &ticket, nil
 }
 
@@ -91,20 +265,191 @@ func (s *TicketStore) Delete(ctx context.Context, ticketId uuid.UUID) error {
 	return nil
 }
 
-func (s *TicketStore) Update(ctx context.Context, ticketId uuid.UUID, priority TicketPriority, status TicketStatus) error {
+// Close removes a ticket that has been archived and publishes EventTicketClosed with its last state.
+// What the reports need of it is kept in closed_tickets, its history and work logs stay and the
+// timers running on it are stopped.
+func (s *TicketStore) Close(ctx context.Context, ticketId uuid.UUID, actorId uuid.UUID) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var ticket Ticket
+	if err := tx.GetContext(ctx, &ticket, `SELECT * FROM tickets WHERE id = $1 FOR UPDATE`, ticketId); err != nil {
+		return fmt.Errorf("failed to close ticket with id %v: %w", ticketId, err)
+	}
+	ticket.Status = TicketStatusClosed
+
+	const closedQuery = `
+	INSERT INTO closed_tickets (id, creator, current_assignee, team_id, category, priority, created_at, resolved_at, first_response_at)
+	SELECT t.id, t.creator, t.current_assignee, t.team_id, t.category, t.priority, t.created_at, t.resolved_at,
+	(SELECT MIN(r.created_at) FROM ticket_replies r WHERE r.ticket_id = t.id AND r.creator IS DISTINCT FROM t.creator)
+	FROM tickets t WHERE t.id = $1`
+
+	if _, err := tx.ExecContext(ctx, closedQuery, ticketId); err != nil {
+		return fmt.Errorf("failed to keep closed ticket with id %v: %w", ticketId, err)
+	}
+
+	// timers still running on the ticket log their time before it goes
+	if err := stopTicketTimers(ctx, tx, ticketId, "timer stopped when the ticket was closed", time.Now()); err != nil {
+		return err
+	}
+
+	// the watchers go with the ticket, the event keeps them for the closed notifications
+	var watchers []uuid.UUID
+	if err := tx.SelectContext(ctx, &watchers, `SELECT user_id FROM ticket_watchers WHERE ticket_id = $1 ORDER BY created_at`, ticketId); err != nil {
+		return fmt.Errorf("failed to get watchers of ticket with id %v: %w", ticketId, err)
+	}
+
+	if err := insertHistoryEvent(ctx, tx, Event{
+		Type:     EventTicketClosed,
+		TicketId: ticketId,
+		Action:   HistoryClosed,
+		Actor:    actorId,
+		Details:  HistoryDetails{"watchers": watchers},
+		Ticket:   &ticket,
+	}); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tickets WHERE id = $1`, ticketId); err != nil {
+		return fmt.Errorf("failed to close ticket with id %v: %w", ticketId, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ticket close: %w", err)
+	}
+
+	return nil
+}
+
+const (
+	HistoryStatusChanged   = "status_changed"
+	HistoryPriorityChanged = "priority_changed"
+	HistoryDueChanged      = "due_changed"
+)
+
+// Update changes the ticket and records status, priority and due date changes in the history.
+// Reaching TicketStatusDone stamps resolved_at, going back before it clears it again.
+func (s *TicketStore) Update(ctx context.Context, ticketId uuid.UUID, params UpdateTicketParams) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current struct {
+		Priority TicketPriority `db:"priority"`
+		Status   TicketStatus   `db:"status"`
+		DueAt    *time.Time     `db:"due_at"`
+	}
+	if err := tx.GetContext(ctx, &current, `SELECT priority, status, due_at FROM tickets WHERE id = $1 FOR UPDATE`, ticketId); err != nil {
+		return fmt.Errorf("failed to get ticket with id %v: %w", ticketId, err)
+	}
 
 	const query = `
-	UPDATE tickets SET priority = $2, status = $3, updated_at = $4 WHERE id = $1`
+	UPDATE tickets SET priority = $2, status = $3, updated_at = $4, custom_fields = COALESCE($5, custom_fields),
+	resolved_at = CASE
+		WHEN $3 < $6 THEN NULL
+		WHEN $3 = $6 AND status != $6 THEN $4
+		ELSE resolved_at
+	END,
+	due_at = $7
+	WHERE id = $1`
 
 	now := time.Now()
 
-	if _, err := s.db.ExecContext(ctx, query, ticketId, priority, status, now); err != nil {
+	var customFields any
+	if params.CustomFields != nil {
+		customFields = params.CustomFields
+	}
+
+	dueAt := current.DueAt
+	if params.ClearDueAt {
+		dueAt = nil
+	} else if params.DueAt != nil {
+		dueAt = params.DueAt
+	}
+
+	if _, err := tx.ExecContext(ctx, query, ticketId, params.Priority, params.Status, now, customFields, TicketStatusDone, dueAt); err != nil {
 		return fmt.Errorf("failed to update ticket with id %v: %w", ticketId, err)
 	}
 
+	if !sameTime(current.DueAt, dueAt) {
+		if err := insertHistory(ctx, tx, ticketId, params.Actor, HistoryDueChanged, HistoryDetails{
+			"from": current.DueAt,
+			"to":   dueAt,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if current.Status != params.Status {
+		if err := insertHistory(ctx, tx, ticketId, params.Actor, HistoryStatusChanged, HistoryDetails{
+			"from": current.Status.String(),
+			"to":   params.Status.String(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if current.Priority != params.Priority {
+		if err := insertHistory(ctx, tx, ticketId, params.Actor, HistoryPriorityChanged, HistoryDetails{
+			"from": current.Priority.String(),
+			"to":   params.Priority.String(),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ticket update: %w", err)
+	}
+
 	return nil
 }
 
+const HistoryAssigned = "assigned"
+
+// Assign sets the user and team responsible for the ticket. The assignee is cleared with
+// uuid.Nil, the team stays unless one is given or clearTeam is set.
+func (s *TicketStore) Assign(ctx context.Context, ticketId, actorId, assigneeId, teamId uuid.UUID, clearTeam bool) (*Ticket, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+	UPDATE tickets SET
+		current_assignee = $2,
+		team_id = CASE WHEN $4 THEN NULL ELSE COALESCE($3, team_id) END,
+		updated_at = $5
+	WHERE id = $1 RETURNING *`
+
+	assignee := uuid.NullUUID{UUID: assigneeId, Valid: assigneeId != uuid.Nil}
+	team := uuid.NullUUID{UUID: teamId, Valid: teamId != uuid.Nil}
+
+	var ticket Ticket
+	if err := tx.GetContext(ctx, &ticket, query, ticketId, assignee, team, clearTeam, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to assign ticket with id %v: %w", ticketId, err)
+	}
+
+	if err := insertHistory(ctx, tx, ticketId, actorId, HistoryAssigned, HistoryDetails{
+		"assignee": assignee,
+		"team":     uuid.NullUUID{UUID: ticket.TeamId, Valid: ticket.TeamId != uuid.Nil},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit assignment: %w", err)
+	}
+
+	return &ticket, nil
+}
+
 func (s *TicketStore) ById(ctx context.Context, ticketId uuid.UUID) (*Ticket, error) {
 
 	const query = `
@@ -131,15 +476,59 @@ func (s *TicketStore) ByAssignee(ctx context.Context, currentAssignee uuid.UUID)
 	return &ticket, nil
 }
 
-func (s *TicketStore) All(ctx context.Context) ([]Ticket, error) {
+func (s *TicketStore) All(ctx context.Context, filter TicketFilter) ([]Ticket, error) {
 
-	const query = `
-	SELECT * FROM tickets`
+	query := `
+	SELECT * FROM tickets WHERE TRUE`
+
+	var args []any
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		query += fmt.Sprintf(" AND category = $%d", len(args))
+	}
+
+	if filter.TeamId != uuid.Nil {
+		args = append(args, filter.TeamId)
+		query += fmt.Sprintf(" AND team_id = $%d", len(args))
+	}
+
+	if filter.DueBefore != nil {
+		args = append(args, *filter.DueBefore)
+		query += fmt.Sprintf(" AND due_at < $%d", len(args))
+	}
+
+	if filter.DueAfter != nil {
+		args = append(args, *filter.DueAfter)
+		query += fmt.Sprintf(" AND due_at >= $%d", len(args))
+	}
+
+	if filter.Overdue {
+		args = append(args, time.Now(), TicketStatusDone)
+		query += fmt.Sprintf(" AND due_at < $%d AND status < $%d", len(args)-1, len(args))
+	}
+
+	for name, value := range filter.CustomFields {
+		args = append(args, name, value)
+		query += fmt.Sprintf(" AND custom_fields ->> $%d = $%d", len(args)-1, len(args))
+	}
+
+	order, ok := TicketSorts[filter.Sort]
+	if !ok {
+		order = TicketSorts["created_at"]
+	}
+	query += " ORDER BY " + order
 
 	var tickets []Ticket
-	if err := s.db.SelectContext(ctx, &tickets, query); err != nil {
+	if err := s.db.SelectContext(ctx, &tickets, query, args...); err != nil {
 		return nil, fmt.Errorf("failed to get all tickets: %w", err)
 	}
 
 	return tickets, nil
 }
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TicketAttachmentStore struct {
+	db *sqlx.DB
+}
+
+func NewTicketAttachmentStore(db *sql.DB) *TicketAttachmentStore {
+	return &TicketAttachmentStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// TicketAttachment is a file of a ticket or one of its replies, the content is kept in s3
+type TicketAttachment struct {
+	Id          uuid.UUID     `db:"id"`
+	TicketId    uuid.UUID     `db:"ticket_id"`
+	ReplyId     uuid.NullUUID `db:"reply_id"`
+	Uploader    uuid.UUID     `db:"uploader"`
+	Filename    string        `db:"filename"`
+	ContentType string        `db:"content_type"`
+	Size        int64         `db:"size"`
+	S3Key       string        `db:"s3_key"`
+	CreatedAt   time.Time     `db:"created_at"`
+}
+
+type CreateAttachmentParams struct {
+	TicketId uuid.UUID
+	// ReplyId is uuid.Nil for attachments of the ticket itself
+	ReplyId     uuid.UUID
+	Uploader    uuid.UUID
+	Filename    string
+	ContentType string
+	Size        int64
+	S3Key       string
+}
+
+// NewAttachmentKey returns a new s3 key to store the content of an attachment of the ticket at
+func NewAttachmentKey(ticketId uuid.UUID) string {
+	return fmt.Sprintf("attachments/%s/%s", ticketId, uuid.New())
+}
+
+func (s *TicketAttachmentStore) Create(ctx context.Context, params CreateAttachmentParams) (*TicketAttachment, error) {
+
+	const query = `
+	INSERT INTO ticket_attachments (ticket_id, reply_id, uploader, filename, content_type, size, s3_key)
+	VALUES ($1, $2, (SELECT id FROM users WHERE id = $3), $4, $5, $6, $7) RETURNING *`
+
+	replyId := uuid.NullUUID{UUID: params.ReplyId, Valid: params.ReplyId != uuid.Nil}
+
+	var attachment TicketAttachment
+	if err := s.db.GetContext(ctx, &attachment, query, params.TicketId, replyId, params.Uploader, params.Filename,
+		params.ContentType, params.Size, params.S3Key); err != nil {
+		return nil, fmt.Errorf("failed to create attachment for ticket %v: %w", params.TicketId, err)
+	}
+
+	return &attachment, nil
+}
+
+func (s *TicketAttachmentStore) ById(ctx context.Context, attachmentId uuid.UUID) (*TicketAttachment, error) {
+
+	const query = `
+	SELECT * FROM ticket_attachments WHERE id = $1`
+
+	var attachment TicketAttachment
+	if err := s.db.GetContext(ctx, &attachment, query, attachmentId); err != nil {
+		return nil, fmt.Errorf("failed to get attachment with id %v: %w", attachmentId, err)
+	}
+
+	return &attachment, nil
+}
+
+func (s *TicketAttachmentStore) ByTicketId(ctx context.Context, ticketId uuid.UUID) ([]TicketAttachment, error) {
+
+	const query = `
+	SELECT * FROM ticket_attachments WHERE ticket_id = $1 ORDER BY created_at ASC`
+
+	attachments := []TicketAttachment{}
+	if err := s.db.SelectContext(ctx, &attachments, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get attachments of ticket %v: %w", ticketId, err)
+	}
+
+	return attachments, nil
+}
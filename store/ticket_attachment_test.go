@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketAttachmentStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+	attachmentStore := store.NewTicketAttachmentStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	reply, err := replyStore.Create(ctx, ticket.Id, guest.Id, "see the photo")
+	require.NoError(t, err)
+
+	created, err := attachmentStore.Create(ctx, store.CreateAttachmentParams{
+		TicketId:    ticket.Id,
+		ReplyId:     reply.Id,
+		Uploader:    guest.Id,
+		Filename:    "lamp.png",
+		ContentType: "image/png",
+		Size:        1024,
+		S3Key:       store.NewAttachmentKey(ticket.Id),
+	})
+	require.NoError(t, err)
+	require.Equal(t, reply.Id, created.ReplyId.UUID)
+
+	attachment, err := attachmentStore.ById(ctx, created.Id)
+	require.NoError(t, err)
+	require.Equal(t, "lamp.png", attachment.Filename)
+	require.Equal(t, guest.Id, attachment.Uploader)
+
+	attachments, err := attachmentStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, attachments, 1)
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TicketHistoryStore struct {
+	db *sqlx.DB
+}
+
+func NewTicketHistoryStore(db *sql.DB) *TicketHistoryStore {
+	return &TicketHistoryStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+const (
+	HistoryCreated      = "created"
+	HistoryRuleApplied  = "rule_applied"
+	HistoryReplied      = "replied"
+	HistoryMacroApplied = "macro_applied"
+	HistoryClosed       = "closed"
+)
+
+type HistoryDetails map[string]any
+
+func (d HistoryDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	return jsonValue(d)
+}
+
+func (d *HistoryDetails) Scan(src any) error {
+	*d = HistoryDetails{}
+	return jsonScan(src, d)
+}
+
+type TicketHistory struct {
+	Id        int64          `db:"id"`
+	TicketId  uuid.UUID      `db:"ticket_id"`
+	Actor     uuid.UUID      `db:"actor"`
+	Action    string         `db:"action"`
+	Details   HistoryDetails `db:"details"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// insertHistory records an entry for the ticket and publishes it as an event on the bus,
+// a nil actor means the system did it
+func insertHistory(ctx context.Context, q sqlx.ExtContext, ticketId uuid.UUID, actor uuid.UUID, action string, details HistoryDetails) error {
+	return insertHistoryEvent(ctx, q, Event{
+		Type:     EventType(action),
+		TicketId: ticketId,
+		Action:   action,
+		Actor:    actor,
+		Details:  details,
+	})
+}
+
+// insertHistoryEvent records the action of the event, together with who can see the ticket
+// as of now, and publishes the event pointing at the entry
+func insertHistoryEvent(ctx context.Context, q sqlx.ExtContext, event Event) error {
+
+	const query = `
+	INSERT INTO ticket_history (ticket_id, actor, action, details, creator, current_assignee, team_id)
+	SELECT t.id, $2::uuid, $3::text, $4::jsonb, t.creator, t.current_assignee, t.team_id FROM tickets t WHERE t.id = $1
+	RETURNING id`
+
+	actorId := uuid.NullUUID{UUID: event.Actor, Valid: event.Actor != uuid.Nil}
+	if err := sqlx.GetContext(ctx, q, &event.HistoryId, query, event.TicketId, actorId, event.Action, event.Details); err != nil {
+		return fmt.Errorf("failed to record %s history for ticket %v: %w", event.Action, event.TicketId, err)
+	}
+
+	return notifyEvent(ctx, q, event)
+}
+
+func (s *TicketHistoryStore) ByTicketId(ctx context.Context, ticketId uuid.UUID) ([]TicketHistory, error) {
+
+	const query = `
+	SELECT id, ticket_id, actor, action, details, created_at FROM ticket_history WHERE ticket_id = $1 ORDER BY id ASC`
+
+	var history []TicketHistory
+	if err := s.db.SelectContext(ctx, &history, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get history of ticket %v: %w", ticketId, err)
+	}
+
+	return history, nil
+}
+
+const (
+	EventTicketCreated  = "ticket.created"
+	EventTicketUpdated  = "ticket.updated"
+	EventTicketReplied  = "ticket.replied"
+	EventTicketAssigned = "ticket.assigned"
+)
+
+// EventType maps a history action to the event it is published as
+func EventType(action string) string {
+	switch action {
+	case HistoryCreated:
+		return EventTicketCreated
+	case HistoryReplied:
+		return EventTicketReplied
+	case HistoryAssigned, HistoryAutoAssigned:
+		return EventTicketAssigned
+	case HistoryClosed:
+		return EventTicketClosed
+	default:
+		return EventTicketUpdated
+	}
+}
+
+// TicketEvent is a history entry with its position in the event stream and who could
+// see the ticket when it was recorded
+type TicketEvent struct {
+	TicketHistory
+	Position int64     `db:"position"`
+	Creator  uuid.UUID `db:"creator"`
+	Assignee uuid.UUID `db:"current_assignee"`
+	TeamId   uuid.UUID `db:"team_id"`
+}
+
+// Since returns up to limit history entries of every ticket after the position, oldest first.
+// Positions are given out in the order transactions commit, so an entry never shows up
+// behind one that was already returned.
+func (s *TicketHistoryStore) Since(ctx context.Context, afterPosition int64, limit int) ([]TicketEvent, error) {
+
+	const query = `
+	SELECT * FROM ticket_history WHERE position > $1 ORDER BY position ASC LIMIT $2`
+
+	var events []TicketEvent
+	if err := s.db.SelectContext(ctx, &events, query, afterPosition, limit); err != nil {
+		return nil, fmt.Errorf("failed to get history after %d: %w", afterPosition, err)
+	}
+
+	return events, nil
+}
+
+// LatestPosition returns the position of the newest history entry, 0 when there is none
+func (s *TicketHistoryStore) LatestPosition(ctx context.Context) (int64, error) {
+
+	const query = `
+	SELECT COALESCE(MAX(position), 0) FROM ticket_history`
+
+	var position int64
+	if err := s.db.GetContext(ctx, &position, query); err != nil {
+		return 0, fmt.Errorf("failed to get latest history position: %w", err)
+	}
+
+	return position, nil
+}
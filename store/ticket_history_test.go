@@ -0,0 +1,74 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketHistoryEvents(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+	historyStore := store.NewTicketHistoryStore(env.Db)
+
+	latest, err := historyStore.LatestPosition(ctx)
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	created, err := historyStore.LatestPosition(ctx)
+	require.NoError(t, err)
+
+	_, err = replyStore.Create(ctx, ticket.Id, guest.Id, "it flickers too")
+	require.NoError(t, err)
+
+	events, err := historyStore.Since(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, store.EventTicketCreated, store.EventType(events[0].Action))
+	require.Equal(t, guest.Id, events[0].Creator)
+
+	// resuming after the created event only returns the reply
+	events, err = historyStore.Since(ctx, created, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, store.EventTicketReplied, store.EventType(events[0].Action))
+	require.Equal(t, ticket.Id, events[0].TicketId)
+	replied := events[0].Position
+
+	// the history of a closed ticket stays in the stream, with who could see it
+	require.NoError(t, ticketStore.Close(ctx, ticket.Id, guest.Id))
+
+	events, err = historyStore.Since(ctx, replied, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, store.EventTicketClosed, store.EventType(events[0].Action))
+	require.Equal(t, guest.Id, events[0].Creator)
+
+	events, err = historyStore.Since(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type TicketRatingStore struct {
+	db *sqlx.DB
+}
+
+func NewTicketRatingStore(db *sql.DB) *TicketRatingStore {
+	return &TicketRatingStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+var ErrAlreadyRated = errors.New("ticket has already been rated")
+
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+type TicketRating struct {
+	TicketId  uuid.UUID `db:"ticket_id"`
+	Creator   uuid.UUID `db:"creator"`
+	Assignee  uuid.UUID `db:"assignee"`
+	TeamId    uuid.UUID `db:"team_id"`
+	Category  string    `db:"category"`
+	Rating    int       `db:"rating"`
+	Comment   string    `db:"comment"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// RatingSummary aggregates the ratings of one assignee, team or category. Key is the
+// id or category name and is empty for unassigned tickets, Name is meant for display.
+type RatingSummary struct {
+	Key     string  `db:"key"`
+	Name    string  `db:"name"`
+	Count   int     `db:"count"`
+	Average float64 `db:"average"`
+}
+
+// Create rates the ticket, the assignee, team and category are copied from the ticket
+// as it is now. Returns ErrAlreadyRated when the ticket was rated before.
+func (s *TicketRatingStore) Create(ctx context.Context, ticket *Ticket, rating int, comment string) (*TicketRating, error) {
+
+	const query = `
+	INSERT INTO ticket_ratings (ticket_id, creator, assignee, team_id, category, rating, comment)
+	VALUES ($1, (SELECT id FROM users WHERE id = $2), (SELECT id FROM users WHERE id = $3),
+	(SELECT id FROM teams WHERE id = $4), $5, $6, $7)
+	ON CONFLICT (ticket_id) DO NOTHING RETURNING *`
+
+	var r TicketRating
+	if err := s.db.GetContext(ctx, &r, query, ticket.Id, ticket.Creator, ticket.CurrentAssignee,
+		ticket.TeamId, ticket.Category, rating, comment); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAlreadyRated
+		}
+		return nil, fmt.Errorf("failed to rate ticket with id %v: %w", ticket.Id, err)
+	}
+
+	return &r, nil
+}
+
+func (s *TicketRatingStore) ByTicketId(ctx context.Context, ticketId uuid.UUID) (*TicketRating, error) {
+
+	const query = `
+	SELECT * FROM ticket_ratings WHERE ticket_id = $1`
+
+	var r TicketRating
+	if err := s.db.GetContext(ctx, &r, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get rating of ticket with id %v: %w", ticketId, err)
+	}
+
+	return &r, nil
+}
+
+var ratingSummaryQueries = map[ReportGroup]string{
+	ReportGroupAssignee: `
+	SELECT COALESCE(r.assignee::text, '') AS key, COALESCE(u.email, '') AS name,
+	COUNT(*) AS count, AVG(r.rating)::float8 AS average
+	FROM ticket_ratings r LEFT JOIN users u ON u.id = r.assignee
+	WHERE r.created_at >= $1 AND r.created_at < $2
+	GROUP BY r.assignee, u.email ORDER BY average DESC, count DESC`,
+	ReportGroupTeam: `
+	SELECT COALESCE(r.team_id::text, '') AS key, COALESCE(t.name, '') AS name,
+	COUNT(*) AS count, AVG(r.rating)::float8 AS average
+	FROM ticket_ratings r LEFT JOIN teams t ON t.id = r.team_id
+	WHERE r.created_at >= $1 AND r.created_at < $2
+	GROUP BY r.team_id, t.name ORDER BY average DESC, count DESC`,
+	ReportGroupCategory: `
+	SELECT r.category AS key, r.category AS name,
+	COUNT(*) AS count, AVG(r.rating)::float8 AS average
+	FROM ticket_ratings r
+	WHERE r.created_at >= $1 AND r.created_at < $2
+	GROUP BY r.category ORDER BY average DESC, count DESC`,
+}
+
+// Summary aggregates the ratings given in [from, to) by the group
+func (s *TicketRatingStore) Summary(ctx context.Context, group ReportGroup, from, to time.Time) ([]RatingSummary, error) {
+	query, ok := ratingSummaryQueries[group]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown report group %q", ErrValidation, group)
+	}
+
+	summaries := []RatingSummary{}
+	if err := s.db.SelectContext(ctx, &summaries, query, from, to); err != nil {
+		return nil, fmt.Errorf("failed to summarize ratings by %v: %w", group, err)
+	}
+
+	return summaries, nil
+}
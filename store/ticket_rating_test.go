@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketRatingStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	ratingStore := store.NewTicketRatingStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "no hot water",
+		Description: "room 204",
+		Category:    "maintenance",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityHigh,
+	})
+	require.NoError(t, err)
+	require.Nil(t, ticket.ResolvedAt)
+
+	require.NoError(t, ticketStore.Update(ctx, ticket.Id, store.UpdateTicketParams{
+		Actor:    guest.Id,
+		Priority: store.TicketPriorityHigh,
+		Status:   store.TicketStatusDone,
+	}))
+
+	ticket, err = ticketStore.ById(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.NotNil(t, ticket.ResolvedAt)
+
+	rating, err := ratingStore.Create(ctx, ticket, 4, "fixed quickly")
+	require.NoError(t, err)
+	require.Equal(t, 4, rating.Rating)
+	require.Equal(t, "maintenance", rating.Category)
+
+	_, err = ratingStore.Create(ctx, ticket, 1, "changed my mind")
+	require.ErrorIs(t, err, store.ErrAlreadyRated)
+
+	rating, err = ratingStore.ByTicketId(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Equal(t, "fixed quickly", rating.Comment)
+
+	// the rating stays in the summaries after the ticket is archived
+	require.NoError(t, ticketStore.Delete(ctx, ticket.Id))
+
+	summaries, err := ratingStore.Summary(ctx, store.ReportGroupCategory, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Equal(t, "maintenance", summaries[0].Key)
+	require.Equal(t, 1, summaries[0].Count)
+	require.InDelta(t, 4.0, summaries[0].Average, 0.001)
+
+	summaries, err = ratingStore.Summary(ctx, store.ReportGroupAssignee, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	require.Equal(t, "", summaries[0].Key)
+}
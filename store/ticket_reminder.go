@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventTicketDueSoon is published when a reminder of the due date of a ticket is due,
+// it carries the due date and how long before it the reminder is
+const EventTicketDueSoon = "ticket.due_soon"
+
+// EventTicketOverdue is published once when a ticket passes its due date without being done,
+// it carries the due date
+const EventTicketOverdue = "ticket.overdue"
+
+// QueueDueReminders publishes EventTicketDueSoon for the assigned tickets that are not done
+// and due within one of the offsets. Each due date is reminded of once per offset, and only
+// with the shortest offset that applies, so a ticket due in 30 minutes does not also get the
+// reminder a day before. It returns how many reminders it published.
+func (s *TicketStore) QueueDueReminders(ctx context.Context, now time.Time, offsets []time.Duration) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+	INSERT INTO ticket_reminders (ticket_id, due_at, before_seconds)
+	SELECT t.id, t.due_at, $2 FROM tickets t
+	WHERE t.due_at > $1 AND t.due_at <= $1 + make_interval(secs => $2)
+	AND t.current_assignee IS NOT NULL AND t.status < $3
+	AND NOT EXISTS (
+		SELECT 1 FROM ticket_reminders r WHERE r.ticket_id = t.id AND r.due_at = t.due_at AND r.before_seconds <= $2
+	)
+	ON CONFLICT DO NOTHING RETURNING ticket_id, due_at`
+
+	offsets = slices.Clone(offsets)
+	slices.Sort(offsets)
+
+	queued := 0
+	for _, offset := range offsets {
+		var reminders []struct {
+			TicketId uuid.UUID `db:"ticket_id"`
+			DueAt    time.Time `db:"due_at"`
+		}
+		if err := tx.SelectContext(ctx, &reminders, query, now, int64(offset.Seconds()), TicketStatusDone); err != nil {
+			return 0, fmt.Errorf("failed to record due reminders: %w", err)
+		}
+
+		for _, reminder := range reminders {
+			if err := notifyEvent(ctx, tx, Event{
+				Type:     EventTicketDueSoon,
+				TicketId: reminder.TicketId,
+				Details: HistoryDetails{
+					"due_at": reminder.DueAt,
+					"before": FormatOffset(offset),
+				},
+			}); err != nil {
+				return 0, err
+			}
+		}
+
+		queued += len(reminders)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit due reminders: %w", err)
+	}
+
+	return queued, nil
+}
+
+// QueueOverdue publishes EventTicketOverdue for the tickets that are past their due date and not
+// done. Each due date is only published once, it is recorded as a reminder 0 seconds before it.
+// It returns how many tickets it published.
+func (s *TicketStore) QueueOverdue(ctx context.Context, now time.Time) (int, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+	INSERT INTO ticket_reminders (ticket_id, due_at, before_seconds)
+	SELECT t.id, t.due_at, 0 FROM tickets t
+	WHERE t.due_at <= $1 AND t.status < $2
+	ON CONFLICT DO NOTHING RETURNING ticket_id, due_at`
+
+	var overdue []struct {
+		TicketId uuid.UUID `db:"ticket_id"`
+		DueAt    time.Time `db:"due_at"`
+	}
+	if err := tx.SelectContext(ctx, &overdue, query, now, TicketStatusDone); err != nil {
+		return 0, fmt.Errorf("failed to record overdue tickets: %w", err)
+	}
+
+	for _, ticket := range overdue {
+		if err := notifyEvent(ctx, tx, Event{
+			Type:     EventTicketOverdue,
+			TicketId: ticket.TicketId,
+			Details: HistoryDetails{
+				"due_at": ticket.DueAt,
+			},
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit overdue tickets: %w", err)
+	}
+
+	return len(overdue), nil
+}
+
+// FormatOffset writes a reminder offset the way people say it, e.g. "1 day" or "90 minutes"
+func FormatOffset(d time.Duration) string {
+	unit, n := "minute", int64(d/time.Minute)
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		unit, n = "day", int64(d/(24*time.Hour))
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, n = "hour", int64(d/time.Hour)
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
@@ -0,0 +1,134 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketOverdue(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	require.False(t, (&store.Ticket{}).Overdue(now))
+	require.False(t, (&store.Ticket{DueAt: &future}).Overdue(now))
+	require.True(t, (&store.Ticket{DueAt: &past, Status: store.TicketStatusInProgress}).Overdue(now))
+	require.False(t, (&store.Ticket{DueAt: &past, Status: store.TicketStatusDone}).Overdue(now))
+}
+
+func TestFormatOffset(t *testing.T) {
+	require.Equal(t, "1 day", store.FormatOffset(24*time.Hour))
+	require.Equal(t, "2 days", store.FormatOffset(48*time.Hour))
+	require.Equal(t, "1 hour", store.FormatOffset(time.Hour))
+	require.Equal(t, "36 hours", store.FormatOffset(36*time.Hour))
+	require.Equal(t, "90 minutes", store.FormatOffset(90*time.Minute))
+}
+
+func TestQueueDueReminders(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	now := time.Now()
+	soon, later, undated := now.Add(30*time.Minute), now.Add(5*time.Hour), now.Add(72*time.Hour)
+
+	create := func(title string, dueAt *time.Time) *store.Ticket {
+		ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+			Title:       title,
+			Description: "test description",
+			Category:    "housekeeping",
+			Creator:     guest.Id,
+			Priority:    store.TicketPriorityMedium,
+			Assignee:    staff.Id,
+			DueAt:       dueAt,
+		})
+		require.NoError(t, err)
+		return ticket
+	}
+
+	crib := create("crib by 18:00", &soon)
+	checkout := create("late checkout at 14:00", &later)
+	create("far away", &undated)
+	create("no due date", nil)
+
+	tickets, err := ticketStore.All(ctx, store.TicketFilter{Sort: "due_at"})
+	require.NoError(t, err)
+	require.Len(t, tickets, 4)
+	require.Equal(t, crib.Id, tickets[0].Id)
+	require.Equal(t, checkout.Id, tickets[1].Id)
+	require.Nil(t, tickets[3].DueAt)
+
+	before := now.Add(6 * time.Hour)
+	tickets, err = ticketStore.All(ctx, store.TicketFilter{DueBefore: &before})
+	require.NoError(t, err)
+	require.Len(t, tickets, 2)
+
+	offsets := []time.Duration{24 * time.Hour, time.Hour}
+
+	// the crib is only reminded of an hour before, the checkout a day before
+	queued, err := ticketStore.QueueDueReminders(ctx, now, offsets)
+	require.NoError(t, err)
+	require.Equal(t, 2, queued)
+
+	queued, err = ticketStore.QueueDueReminders(ctx, now, offsets)
+	require.NoError(t, err)
+	require.Zero(t, queued)
+
+	// moving the due date reminds of it again
+	moved := now.Add(45 * time.Minute)
+	require.NoError(t, ticketStore.Update(ctx, checkout.Id, store.UpdateTicketParams{
+		Actor:    staff.Id,
+		Priority: store.TicketPriorityMedium,
+		Status:   store.TicketStatusInProgress,
+		DueAt:    &moved,
+	}))
+
+	queued, err = ticketStore.QueueDueReminders(ctx, now, offsets)
+	require.NoError(t, err)
+	require.Equal(t, 1, queued)
+
+	tickets, err = ticketStore.All(ctx, store.TicketFilter{Overdue: true})
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+
+	overdue, err := ticketStore.QueueOverdue(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, overdue)
+
+	// an hour later the crib and the moved checkout breach their due date, once
+	overdue, err = ticketStore.QueueOverdue(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, overdue)
+
+	overdue, err = ticketStore.QueueOverdue(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, overdue)
+
+	// done tickets do not breach
+	require.NoError(t, ticketStore.Update(ctx, crib.Id, store.UpdateTicketParams{
+		Actor:    staff.Id,
+		Priority: store.TicketPriorityMedium,
+		Status:   store.TicketStatusDone,
+		DueAt:    &later,
+	}))
+
+	overdue, err = ticketStore.QueueOverdue(ctx, now.Add(6*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, overdue)
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -21,23 +22,69 @@ func NewTicketReplyStore(db *sql.DB) *TicketReplyStore {
 }
 
 type TicketReply struct {
-	Id        uuid.UUID `db:"id"`
-	TicketId  uuid.UUID `db:"ticket_id"`
-	Creator   uuid.UUID `db:"creator"`
-	Message   string    `db:"message"`
-	CreatedAt time.Time `db:"created_at"`
+	Id        uuid.UUID  `db:"id"`
+	TicketId  uuid.UUID  `db:"ticket_id"`
+	Creator   uuid.UUID  `db:"creator"`
+	Message   string     `db:"message"`
+	CreatedAt time.Time  `db:"created_at"`
+	EditedAt  *time.Time `db:"edited_at"`
+	// DeletedAt is set on the tombstones of deleted replies, their message is empty
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
+// Create posts the reply and records it in the ticket history. Users mentioned in the
+// message become watchers of the ticket.
 func (s *TicketReplyStore) Create(ctx context.Context, ticketId uuid.UUID, creatorId uuid.UUID, message string) (*TicketReply, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ticketReply, err := insertReply(ctx, tx, ticketId, creatorId, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit ticket reply: %w", err)
+	}
+
+	return ticketReply, nil
+}
+
+func insertReply(ctx context.Context, tx *sqlx.Tx, ticketId uuid.UUID, creatorId uuid.UUID, message string) (*TicketReply, error) {
 
 	const query = `
 	INSERT INTO ticket_replies (ticket_id, creator, message) VALUES ($1, $2, $3) RETURNING *`
 
 	var ticketReply TicketReply
-	if err := s.db.GetContext(ctx, &ticketReply, query, ticketId, creatorId, message); err != nil {
+	if err := tx.GetContext(ctx, &ticketReply, query, ticketId, creatorId, message); err != nil {
 		return nil, fmt.Errorf("failed to create ticket reply: %w", err)
 	}
 
+	mentions, err := insertMentions(ctx, tx, &ticketReply)
+	if err != nil {
+		return nil, err
+	}
+
+	details := HistoryDetails{
+		"reply_id": ticketReply.Id,
+	}
+
+	// mentioned users are notified of the reply as a mention
+	if len(mentions) > 0 {
+		mentioned := make([]uuid.UUID, 0, len(mentions))
+		for _, mention := range mentions {
+			mentioned = append(mentioned, mention.UserId)
+		}
+		details["mentioned"] = mentioned
+	}
+
+	if err := insertHistory(ctx, tx, ticketId, creatorId, HistoryReplied, details); err != nil {
+		return nil, err
+	}
+
 	return &ticketReply, nil
 }
 
@@ -52,3 +99,60 @@ func (s *TicketReplyStore) ByTicketId(ctx context.Context, ticketId uuid.UUID) (
 
 	return &ticketReplies, nil
 }
+
+func (s *TicketReplyStore) ById(ctx context.Context, replyId uuid.UUID) (*TicketReply, error) {
+
+	const query = `
+	SELECT * FROM ticket_replies WHERE id = $1`
+
+	var ticketReply TicketReply
+	if err := s.db.GetContext(ctx, &ticketReply, query, replyId); err != nil {
+		return nil, fmt.Errorf("failed to get ticket reply with id %v: %w", replyId, err)
+	}
+
+	return &ticketReply, nil
+}
+
+// TicketRead is the last reply of a ticket a user has read
+type TicketRead struct {
+	TicketId uuid.UUID `db:"ticket_id"`
+	UserId   uuid.UUID `db:"user_id"`
+	ReplyId  uuid.UUID `db:"reply_id"`
+	ReadAt   time.Time `db:"read_at"`
+}
+
+// MarkRead records that the user has read the ticket up to the reply. It reports false
+// when nothing changed because the reply is not part of the ticket or older than the last read one.
+func (s *TicketReplyStore) MarkRead(ctx context.Context, ticketId, userId, replyId uuid.UUID) (bool, error) {
+
+	const query = `
+	INSERT INTO ticket_reads (ticket_id, user_id, reply_id)
+	SELECT $1, $2, $3 WHERE EXISTS (SELECT 1 FROM ticket_replies WHERE id = $3 AND ticket_id = $1)
+	ON CONFLICT (ticket_id, user_id) DO UPDATE SET reply_id = EXCLUDED.reply_id, read_at = EXCLUDED.read_at
+	WHERE (SELECT created_at FROM ticket_replies WHERE id = EXCLUDED.reply_id)
+		> (SELECT created_at FROM ticket_replies WHERE id = ticket_reads.reply_id)
+	RETURNING reply_id`
+
+	var id uuid.UUID
+	if err := s.db.GetContext(ctx, &id, query, ticketId, userId, replyId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to mark ticket %v as read: %w", ticketId, err)
+	}
+
+	return true, nil
+}
+
+func (s *TicketReplyStore) Reads(ctx context.Context, ticketId uuid.UUID) ([]TicketRead, error) {
+
+	const query = `
+	SELECT * FROM ticket_reads WHERE ticket_id = $1 ORDER BY read_at ASC`
+
+	reads := []TicketRead{}
+	if err := s.db.SelectContext(ctx, &reads, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get reads of ticket %v: %w", ticketId, err)
+	}
+
+	return reads, nil
+}
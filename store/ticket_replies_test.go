@@ -0,0 +1,75 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketReplyReads(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	replyStore := store.NewTicketReplyStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "door won't lock",
+		Description: "room 412",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityUrgent,
+	})
+	require.NoError(t, err)
+
+	other, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "late checkout",
+		Description: "room 412",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityLow,
+	})
+	require.NoError(t, err)
+
+	first, err := replyStore.Create(ctx, ticket.Id, staff.Id, "someone is on the way")
+	require.NoError(t, err)
+
+	second, err := replyStore.Create(ctx, ticket.Id, staff.Id, "two minutes")
+	require.NoError(t, err)
+
+	unrelated, err := replyStore.Create(ctx, other.Id, staff.Id, "sure")
+	require.NoError(t, err)
+
+	changed, err := replyStore.MarkRead(ctx, ticket.Id, guest.Id, second.Id)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	// reading an older reply does not move the receipt back
+	changed, err = replyStore.MarkRead(ctx, ticket.Id, guest.Id, first.Id)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	changed, err = replyStore.MarkRead(ctx, ticket.Id, guest.Id, unrelated.Id)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	reads, err := replyStore.Reads(ctx, ticket.Id)
+	require.NoError(t, err)
+	require.Len(t, reads, 1)
+	require.Equal(t, second.Id, reads[0].ReplyId)
+	require.Equal(t, guest.Id, reads[0].UserId)
+}
@@ -0,0 +1,542 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+type TicketScheduleStore struct {
+	db *sqlx.DB
+	// location is the timezone of the hotel the tickets are routed in, see TicketStore
+	location *time.Location
+}
+
+func NewTicketScheduleStore(db *sql.DB, location *time.Location) *TicketScheduleStore {
+	return &TicketScheduleStore{
+		db:       sqlx.NewDb(db, "postgres"),
+		location: location,
+	}
+}
+
+const (
+	// ScheduleCron schedules are standard five field cron expressions, or descriptors like @daily
+	ScheduleCron = "cron"
+	// ScheduleRRule schedules are RFC 5545 recurrence rules, e.g. FREQ=WEEKLY;BYDAY=MO;BYHOUR=9
+	ScheduleRRule = "rrule"
+)
+
+var ScheduleKinds = []string{ScheduleCron, ScheduleRRule}
+
+// what happens to the runs the scheduler got to later than ScheduleGracePeriod
+const (
+	// CatchUpSkip drops them
+	CatchUpSkip = "skip"
+	// CatchUpOnce opens one ticket for all of them
+	CatchUpOnce = "once"
+	// CatchUpAll opens a ticket for each of them, ScheduleMaxCatchUp at a time
+	CatchUpAll = "all"
+)
+
+var CatchUpPolicies = []string{CatchUpSkip, CatchUpOnce, CatchUpAll}
+
+const (
+	// ScheduleGracePeriod is how late a run may be and still count as on time
+	ScheduleGracePeriod = 10 * time.Minute
+	// ScheduleMaxCatchUp caps the runs made up for by one call of RunDue
+	ScheduleMaxCatchUp = 50
+)
+
+const (
+	ScheduleRunCreated = "created"
+	ScheduleRunSkipped = "skipped"
+	ScheduleRunFailed  = "failed"
+)
+
+// TemplateValues are the values of the variables of a ticket template
+type TemplateValues map[string]string
+
+func (v TemplateValues) Value() (driver.Value, error) {
+	if v == nil {
+		return "{}", nil
+	}
+	return jsonValue(v)
+}
+
+func (v *TemplateValues) Scan(src any) error {
+	*v = TemplateValues{}
+	return jsonScan(src, v)
+}
+
+type TicketSchedule struct {
+	Id         uuid.UUID      `db:"id"`
+	Name       string         `db:"name"`
+	TemplateId uuid.UUID      `db:"template_id"`
+	Variables  TemplateValues `db:"variables"`
+	Kind       string         `db:"kind"`
+	Expression string         `db:"expression"`
+	Timezone   string         `db:"timezone"`
+	StartsAt   time.Time      `db:"starts_at"`
+	CatchUp    string         `db:"catch_up"`
+	// Priority and TeamId override the ones of the template and the routing rules when set
+	Priority  *TicketPriority `db:"priority"`
+	Assignee  uuid.NullUUID   `db:"assignee"`
+	TeamId    uuid.NullUUID   `db:"team_id"`
+	Enabled   bool            `db:"enabled"`
+	NextRunAt *time.Time      `db:"next_run_at"`
+	LastRunAt *time.Time      `db:"last_run_at"`
+	Creator   uuid.UUID       `db:"creator"`
+	CreatedAt time.Time       `db:"created_at"`
+	UpdatedAt time.Time       `db:"updated_at"`
+}
+
+type TicketScheduleRun struct {
+	Id         int64         `db:"id"`
+	ScheduleId uuid.UUID     `db:"schedule_id"`
+	RunAt      time.Time     `db:"run_at"`
+	TicketId   uuid.NullUUID `db:"ticket_id"`
+	Outcome    string        `db:"outcome"`
+	Error      string        `db:"error"`
+	CreatedAt  time.Time     `db:"created_at"`
+}
+
+type TicketScheduleParams struct {
+	Name       string
+	TemplateId uuid.UUID
+	Variables  TemplateValues
+	Kind       string
+	Expression string
+	Timezone   string
+	StartsAt   time.Time
+	CatchUp    string
+	Priority   *TicketPriority
+	Assignee   uuid.UUID
+	TeamId     uuid.UUID
+	Enabled    bool
+}
+
+// Recurrence yields the runs of a schedule
+type Recurrence interface {
+	// Next returns the first run after the time, the zero time when there is none
+	Next(after time.Time) time.Time
+}
+
+type rruleRecurrence struct {
+	rule *rrule.RRule
+}
+
+func (r rruleRecurrence) Next(after time.Time) time.Time {
+	return r.rule.After(after, false)
+}
+
+// ParseRecurrence parses the expression of a schedule of the kind. Runs are computed in the
+// timezone, rrules without a DTSTART start at startsAt.
+func ParseRecurrence(kind, expression, timezone string, startsAt time.Time) (Recurrence, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	switch kind {
+	case ScheduleCron:
+		if strings.Contains(expression, "TZ=") {
+			return nil, errors.New("set the timezone of the schedule instead of CRON_TZ")
+		}
+
+		schedule, err := cron.ParseStandard(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %w", err)
+		}
+
+		if spec, ok := schedule.(*cron.SpecSchedule); ok {
+			spec.Location = loc
+		}
+
+		return schedule, nil
+
+	case ScheduleRRule:
+		opt, err := rrule.StrToROptionInLocation(expression, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %w", err)
+		}
+
+		if opt.Dtstart.IsZero() {
+			opt.Dtstart = startsAt.In(loc)
+		}
+
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rrule: %w", err)
+		}
+
+		return rruleRecurrence{rule: rule}, nil
+	}
+
+	return nil, fmt.Errorf("unknown schedule kind %q", kind)
+}
+
+// Recurrence parses the expression of the schedule
+func (s *TicketSchedule) Recurrence() (Recurrence, error) {
+	return ParseRecurrence(s.Kind, s.Expression, s.Timezone, s.StartsAt)
+}
+
+// FirstRun returns the first run of the recurrence at or after the time
+func FirstRun(r Recurrence, from time.Time) time.Time {
+	return r.Next(from.Add(-time.Nanosecond))
+}
+
+// DueRuns returns the runs that are due at now, starting with from, and the run after them.
+// It returns at most limit runs, the next run is then the first one left out.
+func DueRuns(r Recurrence, from, now time.Time, limit int) ([]time.Time, time.Time) {
+	var runs []time.Time
+
+	next := from
+	for !next.IsZero() && !next.After(now) && len(runs) < limit {
+		runs = append(runs, next)
+		next = r.Next(next)
+	}
+
+	return runs, next
+}
+
+// CatchUp splits the due runs into the ones to open tickets for and the ones to skip,
+// following the catch up policy for the runs that are too late
+func CatchUp(policy string, runs []time.Time, now time.Time) (open []time.Time, skip []time.Time) {
+	var late []time.Time
+	for _, run := range runs {
+		if now.Sub(run) <= ScheduleGracePeriod {
+			open = append(open, run)
+		} else {
+			late = append(late, run)
+		}
+	}
+
+	switch policy {
+	case CatchUpAll:
+		return runs, nil
+	case CatchUpOnce:
+		if len(late) > 0 && len(open) == 0 {
+			return late[len(late)-1:], late[:len(late)-1]
+		}
+	}
+
+	return open, late
+}
+
+func (p TicketScheduleParams) nextRun(now time.Time) (*time.Time, error) {
+	recurrence, err := ParseRecurrence(p.Kind, p.Expression, p.Timezone, p.StartsAt)
+	if err != nil {
+		return nil, err
+	}
+
+	from := p.StartsAt
+	if from.Before(now) {
+		from = now
+	}
+
+	next := FirstRun(recurrence, from)
+	if next.IsZero() {
+		return nil, nil
+	}
+
+	return &next, nil
+}
+
+func (s *TicketScheduleStore) Create(ctx context.Context, creatorId uuid.UUID, params TicketScheduleParams) (*TicketSchedule, error) {
+
+	const query = `
+	INSERT INTO ticket_schedules (name, template_id, variables, kind, expression, timezone, starts_at, catch_up,
+	priority, assignee, team_id, enabled, next_run_at, creator)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING *`
+
+	nextRun, err := params.nextRun(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule TicketSchedule
+	if err := s.db.GetContext(ctx, &schedule, query, params.Name, params.TemplateId, params.Variables, params.Kind,
+		params.Expression, params.Timezone, params.StartsAt, params.CatchUp, params.Priority, nullUUID(params.Assignee),
+		nullUUID(params.TeamId), params.Enabled, nextRun, creatorId); err != nil {
+		return nil, fmt.Errorf("failed to create ticket schedule: %w", err)
+	}
+
+	return &schedule, nil
+}
+
+// Update replaces the schedule, its next run is computed from scratch so runs missed
+// while it was disabled are not made up for
+func (s *TicketScheduleStore) Update(ctx context.Context, scheduleId uuid.UUID, params TicketScheduleParams) (*TicketSchedule, error) {
+
+	const query = `
+	UPDATE ticket_schedules SET name = $2, template_id = $3, variables = $4, kind = $5, expression = $6, timezone = $7,
+	starts_at = $8, catch_up = $9, priority = $10, assignee = $11, team_id = $12, enabled = $13, next_run_at = $14,
+	updated_at = $15 WHERE id = $1 RETURNING *`
+
+	now := time.Now()
+	nextRun, err := params.nextRun(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule TicketSchedule
+	if err := s.db.GetContext(ctx, &schedule, query, scheduleId, params.Name, params.TemplateId, params.Variables,
+		params.Kind, params.Expression, params.Timezone, params.StartsAt, params.CatchUp, params.Priority,
+		nullUUID(params.Assignee), nullUUID(params.TeamId), params.Enabled, nextRun, now); err != nil {
+		return nil, fmt.Errorf("failed to update ticket schedule with id %v: %w", scheduleId, err)
+	}
+
+	return &schedule, nil
+}
+
+func (s *TicketScheduleStore) Delete(ctx context.Context, scheduleId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM ticket_schedules WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, scheduleId); err != nil {
+		return fmt.Errorf("failed to delete ticket schedule with id %v: %w", scheduleId, err)
+	}
+
+	return nil
+}
+
+func (s *TicketScheduleStore) ById(ctx context.Context, scheduleId uuid.UUID) (*TicketSchedule, error) {
+
+	const query = `
+	SELECT * FROM ticket_schedules WHERE id = $1`
+
+	var schedule TicketSchedule
+	if err := s.db.GetContext(ctx, &schedule, query, scheduleId); err != nil {
+		return nil, fmt.Errorf("failed to get ticket schedule with id %v: %w", scheduleId, err)
+	}
+
+	return &schedule, nil
+}
+
+func (s *TicketScheduleStore) All(ctx context.Context) ([]TicketSchedule, error) {
+
+	const query = `
+	SELECT * FROM ticket_schedules ORDER BY name ASC`
+
+	schedules := []TicketSchedule{}
+	if err := s.db.SelectContext(ctx, &schedules, query); err != nil {
+		return nil, fmt.Errorf("failed to get all ticket schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// Runs returns the latest runs of the schedule, newest first
+func (s *TicketScheduleStore) Runs(ctx context.Context, scheduleId uuid.UUID, limit int) ([]TicketScheduleRun, error) {
+
+	const query = `
+	SELECT * FROM ticket_schedule_runs WHERE schedule_id = $1 ORDER BY run_at DESC LIMIT $2`
+
+	runs := []TicketScheduleRun{}
+	if err := s.db.SelectContext(ctx, &runs, query, scheduleId, limit); err != nil {
+		return nil, fmt.Errorf("failed to get runs of ticket schedule %v: %w", scheduleId, err)
+	}
+
+	return runs, nil
+}
+
+// RunDue claims one due schedule, opens the tickets of its due runs and moves it to its
+// next run, all in one transaction. The schedule is locked while it runs and every run is
+// recorded once, so any number of schedulers can call it. Runs that fail to open their ticket
+// are recorded as failed. It returns nil when nothing is due.
+func (s *TicketScheduleStore) RunDue(ctx context.Context, now time.Time) (*TicketSchedule, []TicketScheduleRun, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const claim = `
+	SELECT * FROM ticket_schedules WHERE enabled AND next_run_at <= $1
+	ORDER BY next_run_at ASC LIMIT 1 FOR UPDATE SKIP LOCKED`
+
+	var schedule TicketSchedule
+	if err := tx.GetContext(ctx, &schedule, claim, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to claim due ticket schedule: %w", err)
+	}
+
+	var due []time.Time
+	var next time.Time
+
+	recurrence, parseErr := schedule.Recurrence()
+	if parseErr == nil {
+		due, next = DueRuns(recurrence, *schedule.NextRunAt, now, ScheduleMaxCatchUp)
+
+		// the schedule stays due at the first run left out, so the runs beyond the ones made
+		// up for at once are recorded by the next calls. The one ticket of CatchUpOnce stands
+		// for all of them, it moves on past them.
+		if schedule.CatchUp == CatchUpOnce && !next.IsZero() && !next.After(now) {
+			next = recurrence.Next(now)
+		}
+	} else {
+		// the schedule can only have become invalid through the database, it is stopped
+		due = []time.Time{*schedule.NextRunAt}
+	}
+
+	open, skip := CatchUp(schedule.CatchUp, due, now)
+
+	var runs []TicketScheduleRun
+	for _, runAt := range skip {
+		run, err := recordScheduleRun(ctx, tx, schedule.Id, runAt, ScheduleRunSkipped, "missed")
+		if err != nil {
+			return nil, nil, err
+		}
+		if run != nil {
+			runs = append(runs, *run)
+		}
+	}
+
+	for _, runAt := range open {
+		// the run is recorded first, a run that has been recorded before opens no ticket
+		run, err := recordScheduleRun(ctx, tx, schedule.Id, runAt, ScheduleRunCreated, "")
+		if err != nil {
+			return nil, nil, err
+		}
+		if run == nil {
+			continue
+		}
+
+		if parseErr != nil {
+			run.Outcome, run.Error = ScheduleRunFailed, parseErr.Error()
+		} else {
+			ticketId, failure, err := openScheduledTicket(ctx, tx, &schedule, time.Now().In(s.location))
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if failure != "" {
+				run.Outcome, run.Error = ScheduleRunFailed, failure
+			} else {
+				run.TicketId = uuid.NullUUID{UUID: ticketId, Valid: true}
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE ticket_schedule_runs SET ticket_id = $2, outcome = $3, error = $4 WHERE id = $1`,
+			run.Id, run.TicketId, run.Outcome, run.Error); err != nil {
+			return nil, nil, fmt.Errorf("failed to record run of ticket schedule %v at %v: %w", schedule.Id, runAt, err)
+		}
+
+		runs = append(runs, *run)
+	}
+
+	const advance = `
+	UPDATE ticket_schedules SET next_run_at = $2, last_run_at = $3 WHERE id = $1 RETURNING *`
+
+	nextRun := sql.NullTime{Time: next, Valid: !next.IsZero()}
+	lastRun := schedule.LastRunAt
+	if len(due) > 0 {
+		lastRun = &due[len(due)-1]
+	}
+
+	if err := tx.GetContext(ctx, &schedule, advance, schedule.Id, nextRun, lastRun); err != nil {
+		return nil, nil, fmt.Errorf("failed to advance ticket schedule %v: %w", schedule.Id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit runs of ticket schedule %v: %w", schedule.Id, err)
+	}
+
+	return &schedule, runs, nil
+}
+
+// openScheduledTicket opens the ticket of a run of the schedule. A run whose ticket fails to open
+// fails on its own with the returned failure, what it wrote is undone and the schedule still moves
+// to its next run, so a broken schedule does not hold up the others. Only errors of the
+// transaction itself are returned.
+func openScheduledTicket(ctx context.Context, tx *sqlx.Tx, schedule *TicketSchedule, now time.Time) (uuid.UUID, string, error) {
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT scheduled_ticket`); err != nil {
+		return uuid.Nil, "", fmt.Errorf("failed to run ticket schedule %v: %w", schedule.Id, err)
+	}
+
+	ticket, err := scheduledTicket(ctx, tx, schedule, now)
+	if err != nil {
+		if _, rollbackErr := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT scheduled_ticket`); rollbackErr != nil {
+			return uuid.Nil, "", fmt.Errorf("failed to undo run of ticket schedule %v: %w", schedule.Id, rollbackErr)
+		}
+		return uuid.Nil, err.Error(), nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT scheduled_ticket`); err != nil {
+		return uuid.Nil, "", fmt.Errorf("failed to run ticket schedule %v: %w", schedule.Id, err)
+	}
+
+	return ticket.Id, "", nil
+}
+
+// scheduledTicket opens the ticket of a run of the schedule from its template. Templates that
+// no longer fit the variables of the schedule fail with ErrValidation.
+func scheduledTicket(ctx context.Context, tx *sqlx.Tx, schedule *TicketSchedule, now time.Time) (*Ticket, error) {
+	var template TicketTemplate
+	if err := tx.GetContext(ctx, &template, `SELECT * FROM ticket_templates WHERE id = $1`, schedule.TemplateId); err != nil {
+		return nil, fmt.Errorf("failed to get template %v of ticket schedule %v: %w", schedule.TemplateId, schedule.Id, err)
+	}
+
+	title, description, err := template.Render(schedule.Variables)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+
+	if utf8.RuneCountInString(title) > 100 {
+		return nil, fmt.Errorf("%w: rendered title is longer than 100 characters", ErrValidation)
+	}
+
+	// the priority and team of the schedule are what the rules see and what the ticket gets
+	params := CreateTicketParams{
+		Title:       title,
+		Description: description,
+		Category:    template.Category,
+		Creator:     schedule.Creator,
+		Priority:    template.Priority,
+		TeamId:      schedule.TeamId.UUID,
+		Assignee:    schedule.Assignee.UUID,
+		FixedTeam:   schedule.TeamId.UUID,
+	}
+
+	if schedule.Priority != nil {
+		params.Priority = *schedule.Priority
+		params.FixedPriority = schedule.Priority
+	}
+
+	return createTicket(ctx, tx, params, now)
+}
+
+// recordScheduleRun records the run unless it has been recorded before, then it returns nil
+func recordScheduleRun(ctx context.Context, tx *sqlx.Tx, scheduleId uuid.UUID, runAt time.Time, outcome, errMessage string) (*TicketScheduleRun, error) {
+
+	const query = `
+	INSERT INTO ticket_schedule_runs (schedule_id, run_at, outcome, error) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (schedule_id, run_at) DO NOTHING RETURNING *`
+
+	var run TicketScheduleRun
+	if err := tx.GetContext(ctx, &run, query, scheduleId, runAt, outcome, errMessage); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to record run of ticket schedule %v at %v: %w", scheduleId, runAt, err)
+	}
+
+	return &run, nil
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
@@ -0,0 +1,216 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, berlin)
+
+	cron, err := store.ParseRecurrence(store.ScheduleCron, "0 9 * * MON", "Europe/Berlin", start)
+	require.NoError(t, err)
+	require.True(t, time.Date(2024, 3, 4, 9, 0, 0, 0, berlin).Equal(store.FirstRun(cron, start)))
+
+	rule, err := store.ParseRecurrence(store.ScheduleRRule, "FREQ=MONTHLY;BYMONTHDAY=15;BYHOUR=7;BYMINUTE=30;BYSECOND=0", "Europe/Berlin", start)
+	require.NoError(t, err)
+
+	first := store.FirstRun(rule, start)
+	require.True(t, time.Date(2024, 3, 15, 7, 30, 0, 0, berlin).Equal(first))
+	require.True(t, time.Date(2024, 4, 15, 7, 30, 0, 0, berlin).Equal(rule.Next(first)))
+
+	once, err := store.ParseRecurrence(store.ScheduleRRule, "FREQ=DAILY;COUNT=1", "UTC", start)
+	require.NoError(t, err)
+	require.True(t, once.Next(store.FirstRun(once, start)).IsZero())
+
+	_, err = store.ParseRecurrence(store.ScheduleCron, "every day", "UTC", start)
+	require.Error(t, err)
+
+	_, err = store.ParseRecurrence(store.ScheduleCron, "CRON_TZ=Asia/Tokyo 0 9 * * *", "UTC", start)
+	require.Error(t, err)
+
+	_, err = store.ParseRecurrence(store.ScheduleRRule, "FREQ=DAILY", "Mars/Olympus", start)
+	require.Error(t, err)
+}
+
+func TestDueRunsAndCatchUp(t *testing.T) {
+	hourly, err := store.ParseRecurrence(store.ScheduleCron, "0 * * * *", "UTC", time.Time{})
+	require.NoError(t, err)
+
+	from := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+	now := time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC)
+
+	runs, next := store.DueRuns(hourly, from, now, 10)
+	require.Len(t, runs, 4)
+	require.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), next)
+
+	runs, next = store.DueRuns(hourly, from, now, 2)
+	require.Len(t, runs, 2)
+	require.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), next)
+
+	runs, _ = store.DueRuns(hourly, from, now, 10)
+
+	// 9:00 is on time, the others are missed
+	open, skip := store.CatchUp(store.CatchUpSkip, runs, now)
+	require.Equal(t, runs[3:], open)
+	require.Len(t, skip, 3)
+
+	open, skip = store.CatchUp(store.CatchUpOnce, runs, now)
+	require.Equal(t, runs[3:], open)
+	require.Len(t, skip, 3)
+
+	open, skip = store.CatchUp(store.CatchUpAll, runs, now)
+	require.Len(t, open, 4)
+	require.Empty(t, skip)
+
+	// when every run is missed, once makes up for the latest
+	late := now.Add(time.Hour)
+	open, skip = store.CatchUp(store.CatchUpOnce, runs, late)
+	require.Equal(t, runs[3:], open)
+	require.Len(t, skip, 3)
+
+	open, _ = store.CatchUp(store.CatchUpSkip, runs, late)
+	require.Empty(t, open)
+}
+
+func TestTicketScheduleStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	templateStore := store.NewTicketTemplateStore(env.Db)
+	scheduleStore := store.NewTicketScheduleStore(env.Db, time.UTC)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	teamStore := store.NewTeamStore(env.Db)
+	ruleStore := store.NewRoutingRuleStore(env.Db)
+
+	admin, err := userStore.CreateUser(ctx, "admin@test.com", "test")
+	require.NoError(t, err)
+
+	staff, err := userStore.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+
+	template, err := templateStore.Create(ctx, admin.Id, store.TicketTemplateParams{
+		Name:         "inspection",
+		TitlePattern: "Inspect fire extinguishers on floor {{floor}}",
+		Description:  "Check the pressure gauge and the seal",
+		Priority:     store.TicketPriorityMedium,
+		Variables:    []string{"floor"},
+	})
+	require.NoError(t, err)
+
+	maintenance, err := teamStore.Create(ctx, "Maintenance", "Repairs and equipment")
+	require.NoError(t, err)
+	housekeeping, err := teamStore.Create(ctx, "Housekeeping", "Rooms and laundry")
+	require.NoError(t, err)
+
+	// the schedule's priority and team win over a rule that sets others
+	low := store.TicketPriorityLow
+	_, err = ruleStore.Create(ctx, store.RoutingRuleParams{
+		Name:       "extinguishers to housekeeping",
+		Enabled:    true,
+		Conditions: store.RuleConditions{Keywords: []string{"extinguishers"}},
+		Actions:    store.RuleActions{AssignTeam: &housekeeping.Id, SetPriority: &low},
+	})
+	require.NoError(t, err)
+
+	high := store.TicketPriorityHigh
+	startsAt := time.Now().Add(-3 * time.Hour).Truncate(time.Hour)
+
+	schedule, err := scheduleStore.Create(ctx, admin.Id, store.TicketScheduleParams{
+		Name:       "floor 3 extinguishers",
+		TemplateId: template.Id,
+		Variables:  store.TemplateValues{"floor": "3"},
+		Kind:       store.ScheduleCron,
+		Expression: "0 * * * *",
+		Timezone:   "UTC",
+		StartsAt:   startsAt,
+		CatchUp:    store.CatchUpOnce,
+		Priority:   &high,
+		Assignee:   staff.Id,
+		TeamId:     maintenance.Id,
+		Enabled:    true,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, schedule.NextRunAt)
+	require.True(t, schedule.NextRunAt.After(time.Now()))
+
+	// the scheduler was down for three hours
+	now := schedule.NextRunAt.Add(3*time.Hour + time.Minute)
+
+	ran, runs, err := scheduleStore.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.NotNil(t, ran)
+	require.Len(t, runs, 4)
+	require.True(t, ran.NextRunAt.After(now))
+
+	var created []store.TicketScheduleRun
+	for _, run := range runs {
+		if run.Outcome == store.ScheduleRunCreated {
+			created = append(created, run)
+		}
+	}
+	require.Len(t, created, 1)
+
+	ticket, err := ticketStore.ById(ctx, created[0].TicketId.UUID)
+	require.NoError(t, err)
+	require.Equal(t, "Inspect fire extinguishers on floor 3", ticket.Title)
+	require.Equal(t, store.TicketPriorityHigh, ticket.Priority)
+	require.Equal(t, maintenance.Id, ticket.TeamId)
+	require.Equal(t, staff.Id, ticket.CurrentAssignee)
+	require.Equal(t, admin.Id, ticket.Creator)
+
+	// nothing is due twice
+	ran, _, err = scheduleStore.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.Nil(t, ran)
+
+	history, err := scheduleStore.Runs(ctx, schedule.Id, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	// every missed run of a minutely schedule is made up for, over several calls
+	minutely, err := scheduleStore.Create(ctx, admin.Id, store.TicketScheduleParams{
+		Name:       "minutely extinguishers",
+		TemplateId: template.Id,
+		Variables:  store.TemplateValues{"floor": "4"},
+		Kind:       store.ScheduleCron,
+		Expression: "* * * * *",
+		Timezone:   "UTC",
+		StartsAt:   time.Now(),
+		CatchUp:    store.CatchUpAll,
+		Enabled:    true,
+	})
+	require.NoError(t, err)
+
+	now = minutely.NextRunAt.Add(time.Hour + 30*time.Second)
+
+	ran, runs, err = scheduleStore.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, minutely.Id, ran.Id)
+	require.Len(t, runs, store.ScheduleMaxCatchUp)
+	require.False(t, ran.NextRunAt.After(now))
+
+	ran, runs, err = scheduleStore.RunDue(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, minutely.Id, ran.Id)
+	require.Len(t, runs, 61-store.ScheduleMaxCatchUp)
+	require.True(t, ran.NextRunAt.After(now))
+
+	history, err = scheduleStore.Runs(ctx, minutely.Id, 100)
+	require.NoError(t, err)
+	require.Len(t, history, 61)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type TicketTemplateStore struct {
+	db *sqlx.DB
+}
+
+func NewTicketTemplateStore(db *sql.DB) *TicketTemplateStore {
+	return &TicketTemplateStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+type TicketTemplate struct {
+	Id           uuid.UUID      `db:"id"`
+	Name         string         `db:"name"`
+	TitlePattern string         `db:"title_pattern"`
+	Description  string         `db:"description"`
+	Priority     TicketPriority `db:"priority"`
+	Category     string         `db:"category"`
+	Variables    pq.StringArray `db:"variables"`
+	Creator      uuid.UUID      `db:"creator"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+type TicketTemplateParams struct {
+	Name         string
+	TitlePattern string
+	Description  string
+	Priority     TicketPriority
+	Category     string
+	Variables    []string
+}
+
+func (p TicketTemplateParams) variables() pq.StringArray {
+	if p.Variables == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(p.Variables)
+}
+
+// placeholders look like {{room}} and may contain letters, digits and underscores
+var templatePlaceholder = regexp.MustCompile(`{{\s*([a-zA-Z0-9_]+)\s*}}`)
+
+// TemplatePlaceholders returns the distinct placeholder names used in the text, in order of appearance.
+func TemplatePlaceholders(text string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render fills in the title pattern and description with the given values.
+// Every declared variable has to be provided.
+func (t *TicketTemplate) Render(values map[string]string) (string, string, error) {
+	for _, name := range t.Variables {
+		if strings.TrimSpace(values[name]) == "" {
+			return "", "", fmt.Errorf("value for template variable %q is required", name)
+		}
+	}
+
+	return Interpolate(t.TitlePattern, values), Interpolate(t.Description, values), nil
+}
+
+// Interpolate replaces the placeholders in text with their values, unknown placeholders are left as is
+func Interpolate(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := templatePlaceholder.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+func (s *TicketTemplateStore) Create(ctx context.Context, creatorId uuid.UUID, params TicketTemplateParams) (*TicketTemplate, error) {
+
+	const query = `
+	INSERT INTO ticket_templates (name, title_pattern, description, priority, category, variables, creator)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *`
+
+	var template TicketTemplate
+	if err := s.db.GetContext(ctx, &template, query, params.Name, params.TitlePattern, params.Description,
+		params.Priority, params.Category, params.variables(), creatorId); err != nil {
+		return nil, fmt.Errorf("failed to create ticket template: %w", err)
+	}
+
+	return &template, nil
+}
+
+func (s *TicketTemplateStore) Update(ctx context.Context, templateId uuid.UUID, params TicketTemplateParams) (*TicketTemplate, error) {
+
+	const query = `
+	UPDATE ticket_templates SET name = $2, title_pattern = $3, description = $4, priority = $5,
+	category = $6, variables = $7, updated_at = $8 WHERE id = $1 RETURNING *`
+
+	var template TicketTemplate
+	if err := s.db.GetContext(ctx, &template, query, templateId, params.Name, params.TitlePattern, params.Description,
+		params.Priority, params.Category, params.variables(), time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update ticket template with id %v: %w", templateId, err)
+	}
+
+	return &template, nil
+}
+
+func (s *TicketTemplateStore) Delete(ctx context.Context, templateId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM ticket_templates WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, templateId); err != nil {
+		return fmt.Errorf("failed to delete ticket template with id %v: %w", templateId, err)
+	}
+
+	return nil
+}
+
+func (s *TicketTemplateStore) ById(ctx context.Context, templateId uuid.UUID) (*TicketTemplate, error) {
+
+	const query = `
+	SELECT * FROM ticket_templates WHERE id = $1`
+
+	var template TicketTemplate
+	if err := s.db.GetContext(ctx, &template, query, templateId); err != nil {
+		return nil, fmt.Errorf("failed to get ticket template with id %v: %w", templateId, err)
+	}
+
+	return &template, nil
+}
+
+func (s *TicketTemplateStore) All(ctx context.Context) ([]TicketTemplate, error) {
+
+	const query = `
+	SELECT * FROM ticket_templates ORDER BY name ASC`
+
+	var templates []TicketTemplate
+	if err := s.db.SelectContext(ctx, &templates, query); err != nil {
+		return nil, fmt.Errorf("failed to get all ticket templates: %w", err)
+	}
+
+	return templates, nil
+}
@@ -0,0 +1,77 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestTicketTemplateRender(t *testing.T) {
+	template := store.TicketTemplate{
+		TitlePattern: "Extra towels for room {{room}}",
+		Description:  "Bring {{ count }} towels to room {{room}}",
+		Variables:    []string{"room", "count"},
+	}
+
+	require.Equal(t, []string{"room", "count"}, store.TemplatePlaceholders(template.TitlePattern+template.Description))
+
+	title, description, err := template.Render(map[string]string{"room": "204", "count": "3"})
+	require.NoError(t, err)
+	require.Equal(t, "Extra towels for room 204", title)
+	require.Equal(t, "Bring 3 towels to room 204", description)
+
+	_, _, err = template.Render(map[string]string{"room": "204"})
+	require.Error(t, err)
+}
+
+func TestTicketTemplateStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	templateStore := store.NewTicketTemplateStore(env.Db)
+
+	user, err := userStore.CreateUser(ctx, "admin@test.com", "test")
+	require.NoError(t, err)
+
+	template, err := templateStore.Create(ctx, user.Id, store.TicketTemplateParams{
+		Name:         "Extra towels",
+		TitlePattern: "Extra towels for room {{room}}",
+		Description:  "Guest asked for extra towels",
+		Priority:     store.TicketPriorityMedium,
+		Category:     "housekeeping",
+		Variables:    []string{"room"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Extra towels", template.Name)
+	require.Equal(t, user.Id, template.Creator)
+	require.Equal(t, []string{"room"}, []string(template.Variables))
+
+	template, err = templateStore.Update(ctx, template.Id, store.TicketTemplateParams{
+		Name:         "Extra towels",
+		TitlePattern: "Towels for room {{room}}",
+		Description:  "Guest asked for extra towels",
+		Priority:     store.TicketPriorityLow,
+		Category:     "housekeeping",
+	})
+	require.NoError(t, err)
+	require.Equal(t, store.TicketPriorityLow, template.Priority)
+	require.Empty(t, template.Variables)
+
+	templates, err := templateStore.All(ctx)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+
+	require.NoError(t, templateStore.Delete(ctx, template.Id))
+
+	_, err = templateStore.ById(ctx, template.Id)
+	require.Error(t, err)
+}
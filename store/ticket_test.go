@@ -22,12 +22,18 @@ func TestTicketStore(t *testing.T) {
 	})
 
 	userStore := store.NewUserStore(env.Db)
-	ticketStore := store.NewTicketStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
 
 	user, err := userStore.CreateUser(ctx, "test@test.com", "test")
 	require.NoError(t, err)
 
-	ticket, err := ticketStore.Create(ctx, "test ticket", "test description", user.Id, store.TicketPriorityUrgent)
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "test ticket",
+		Description: "test description",
+		Category:    "housekeeping",
+		Creator:     user.Id,
+		Priority:    store.TicketPriorityUrgent,
+	})
 	require.NoError(t, err)
 
 	require.NotNil(t, ticket.Id)
@@ -35,12 +41,28 @@ func TestTicketStore(t *testing.T) {
 
 	require.Equal(t, "test ticket", ticket.Title)
 	require.Equal(t, user.Id, ticket.Creator)
+	require.Equal(t, "housekeeping", ticket.Category)
 	require.True(t, now.After(ticket.CreatedAt))
 
 	require.NoError(t, err)
 
-	err = ticketStore.Update(ctx, ticket.Id, store.TicketPriorityUrgent, store.TicketStatusCreated)
+	err = ticketStore.Update(ctx, ticket.Id, store.UpdateTicketParams{
+		Priority:     store.TicketPriorityUrgent,
+		Status:       store.TicketStatusCreated,
+		CustomFields: store.CustomFields{"room_number": "204"},
+	})
+	require.NoError(t, err)
+
+	tickets, err := ticketStore.All(ctx, store.TicketFilter{
+		Category:     "housekeeping",
+		CustomFields: map[string]string{"room_number": "204"},
+	})
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+
+	tickets, err = ticketStore.All(ctx, store.TicketFilter{CustomFields: map[string]string{"room_number": "305"}})
 	require.NoError(t, err)
+	require.Empty(t, tickets)
 
 	ticket, err = ticketStore.ById(ctx, ticket.Id)
 	require.NoError(t, err)
@@ -36,6 +36,7 @@ type User struct {
 	HashedPassword string    `db:"hashed_password"`
 	CreatedAt      time.Time `db:"created_at"`
 	Roles          UserRole  `db:"roles"`
+	Available      bool      `db:"available"`
 }
 
 func (u *User) ComparePasswordHash(password string) error {
@@ -119,3 +120,16 @@ func (s *UserStore) ByEmail(ctx context.Context, email string) (*User, error) {
 
 	return &user, nil
 }
+
+// SetAvailability marks a staff member as on or off shift for auto-assignment
+func (s *UserStore) SetAvailability(ctx context.Context, userId uuid.UUID, available bool) (*User, error) {
+	const query = `
+	UPDATE users SET available = $1 WHERE id = $2 RETURNING *`
+
+	var user User
+	if err := s.db.GetContext(ctx, &user, query, available, userId); err != nil {
+		return nil, fmt.Errorf("failed to update availability of user %s: %w", userId, err)
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,357 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type WebhookStore struct {
+	db *sqlx.DB
+}
+
+func NewWebhookStore(db *sql.DB) *WebhookStore {
+	return &WebhookStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+// WebhookEventTypes are the events webhooks can subscribe to
+var WebhookEventTypes = []string{
+	EventTicketCreated,
+	EventTicketUpdated,
+	EventTicketReplied,
+	EventTicketAssigned,
+	EventTicketClosed,
+	EventTicketDueSoon,
+	EventTicketOverdue,
+}
+
+const (
+	// WebhookMaxAttempts is how often a delivery is tried before it is given up
+	WebhookMaxAttempts = 10
+	// WebhookDisableAfter is how many failed attempts in a row disable a webhook
+	WebhookDisableAfter = 25
+
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// WebhookBackoff is how long to wait before the next try after the given number of attempts
+func WebhookBackoff(attempts int) time.Duration {
+	return backoff(webhookBaseBackoff, webhookMaxBackoff, attempts)
+}
+
+// backoff doubles base for every attempt after the first, up to max
+func backoff(base, max time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	return min(wait, max)
+}
+
+type Webhook struct {
+	Id           uuid.UUID      `db:"id"`
+	Url          string         `db:"url"`
+	Secret       string         `db:"secret"`
+	EventTypes   pq.StringArray `db:"event_types"`
+	Enabled      bool           `db:"enabled"`
+	FailureCount int            `db:"failure_count"`
+	DisabledAt   *time.Time     `db:"disabled_at"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+}
+
+type WebhookParams struct {
+	Url        string
+	EventTypes []string
+	Enabled    bool
+}
+
+func (p WebhookParams) eventTypes() pq.StringArray {
+	if p.EventTypes == nil {
+		return pq.StringArray{}
+	}
+	return pq.StringArray(p.EventTypes)
+}
+
+// WebhookPayload is the raw json body of a delivery
+type WebhookPayload json.RawMessage
+
+func (p WebhookPayload) Value() (driver.Value, error) {
+	return []byte(p), nil
+}
+
+func (p *WebhookPayload) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		*p = append(WebhookPayload{}, v...)
+	case string:
+		*p = WebhookPayload(v)
+	default:
+		return fmt.Errorf("unsupported payload type %T", src)
+	}
+	return nil
+}
+
+func (p WebhookPayload) MarshalJSON() ([]byte, error) {
+	if len(p) == 0 {
+		return []byte("null"), nil
+	}
+	return p, nil
+}
+
+type WebhookDelivery struct {
+	Id             uuid.UUID      `db:"id"`
+	WebhookId      uuid.UUID      `db:"webhook_id"`
+	EventType      string         `db:"event_type"`
+	Payload        WebhookPayload `db:"payload"`
+	Status         DeliveryStatus `db:"status"`
+	Attempts       int            `db:"attempts"`
+	NextAttemptAt  time.Time      `db:"next_attempt_at"`
+	LastStatusCode *int           `db:"last_status_code"`
+	LastError      string         `db:"last_error"`
+	CreatedAt      time.Time      `db:"created_at"`
+	DeliveredAt    *time.Time     `db:"delivered_at"`
+}
+
+// DueDelivery is a claimed delivery together with where it goes
+type DueDelivery struct {
+	WebhookDelivery
+	Url    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// queueWebhookDeliveries queues the event for every enabled webhook subscribed to it
+func queueWebhookDeliveries(ctx context.Context, q sqlx.ExecerContext, eventType string, payload []byte) error {
+	if !slices.Contains(WebhookEventTypes, eventType) {
+		return nil
+	}
+
+	const query = `
+	INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+	SELECT id, $1, $2 FROM webhooks WHERE enabled AND $1 = ANY(event_types)`
+
+	if _, err := q.ExecContext(ctx, query, eventType, string(payload)); err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries for %s: %w", eventType, err)
+	}
+
+	return nil
+}
+
+// Create registers a webhook with a newly generated signing secret
+func (s *WebhookStore) Create(ctx context.Context, params WebhookParams) (*Webhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	const query = `
+	INSERT INTO webhooks (url, secret, event_types, enabled) VALUES ($1, $2, $3, $4) RETURNING *`
+
+	var webhook Webhook
+	if err := s.db.GetContext(ctx, &webhook, query, params.Url, secret, params.eventTypes(), params.Enabled); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+// Update changes the webhook, enabling it again also forgets the failures that disabled it
+func (s *WebhookStore) Update(ctx context.Context, webhookId uuid.UUID, params WebhookParams) (*Webhook, error) {
+
+	const query = `
+	UPDATE webhooks SET url = $2, event_types = $3, enabled = $4, updated_at = $5,
+	failure_count = CASE WHEN $4 AND NOT enabled THEN 0 ELSE failure_count END,
+	disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END
+	WHERE id = $1 RETURNING *`
+
+	var webhook Webhook
+	if err := s.db.GetContext(ctx, &webhook, query, webhookId, params.Url, params.eventTypes(),
+		params.Enabled, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to update webhook with id %v: %w", webhookId, err)
+	}
+
+	return &webhook, nil
+}
+
+func (s *WebhookStore) Delete(ctx context.Context, webhookId uuid.UUID) error {
+
+	const query = `
+	DELETE FROM webhooks WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, webhookId); err != nil {
+		return fmt.Errorf("failed to delete webhook with id %v: %w", webhookId, err)
+	}
+
+	return nil
+}
+
+func (s *WebhookStore) ById(ctx context.Context, webhookId uuid.UUID) (*Webhook, error) {
+
+	const query = `
+	SELECT * FROM webhooks WHERE id = $1`
+
+	var webhook Webhook
+	if err := s.db.GetContext(ctx, &webhook, query, webhookId); err != nil {
+		return nil, fmt.Errorf("failed to get webhook with id %v: %w", webhookId, err)
+	}
+
+	return &webhook, nil
+}
+
+func (s *WebhookStore) All(ctx context.Context) ([]Webhook, error) {
+
+	const query = `
+	SELECT * FROM webhooks ORDER BY created_at ASC`
+
+	var webhooks []Webhook
+	if err := s.db.SelectContext(ctx, &webhooks, query); err != nil {
+		return nil, fmt.Errorf("failed to get all webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// Deliveries returns the latest deliveries of the webhook, newest first
+func (s *WebhookStore) Deliveries(ctx context.Context, webhookId uuid.UUID, limit int) ([]WebhookDelivery, error) {
+
+	const query = `
+	SELECT * FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	deliveries := []WebhookDelivery{}
+	if err := s.db.SelectContext(ctx, &deliveries, query, webhookId, limit); err != nil {
+		return nil, fmt.Errorf("failed to get deliveries of webhook %v: %w", webhookId, err)
+	}
+
+	return deliveries, nil
+}
+
+// Replay queues the payload of a past delivery again as a new delivery
+func (s *WebhookStore) Replay(ctx context.Context, deliveryId uuid.UUID) (*WebhookDelivery, error) {
+
+	const query = `
+	INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+	SELECT webhook_id, event_type, payload FROM webhook_deliveries WHERE id = $1
+	RETURNING *`
+
+	var delivery WebhookDelivery
+	if err := s.db.GetContext(ctx, &delivery, query, deliveryId); err != nil {
+		return nil, fmt.Errorf("failed to replay delivery with id %v: %w", deliveryId, err)
+	}
+
+	return &delivery, nil
+}
+
+// ClaimDue hands out up to limit deliveries that are due, each is leased for the given
+// duration so other workers skip it until the attempt is recorded or the lease runs out
+func (s *WebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]DueDelivery, error) {
+
+	const query = `
+	WITH due AS (
+		SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = $1 AND d.next_attempt_at <= $2 AND w.enabled
+		ORDER BY d.next_attempt_at ASC LIMIT $3
+		FOR UPDATE OF d SKIP LOCKED
+	), claimed AS (
+		UPDATE webhook_deliveries d SET next_attempt_at = $4
+		FROM due WHERE d.id = due.id RETURNING d.*
+	)
+	SELECT claimed.*, w.url, w.secret FROM claimed JOIN webhooks w ON w.id = claimed.webhook_id`
+
+	now := time.Now()
+
+	deliveries := []DueDelivery{}
+	if err := s.db.SelectContext(ctx, &deliveries, query, DeliveryPending, now, limit, now.Add(lease)); err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt. Failed attempts are retried with
+// exponential backoff until WebhookMaxAttempts, and a webhook that fails WebhookDisableAfter
+// times in a row is disabled. A statusCode of 0 means no response was received.
+func (s *WebhookStore) RecordAttempt(ctx context.Context, delivery *WebhookDelivery, statusCode int, attemptErr error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	attempts := delivery.Attempts + 1
+
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+
+	if attemptErr == nil {
+		const deliveryQuery = `
+		UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4,
+		last_error = '', delivered_at = $5 WHERE id = $1`
+
+		if _, err := tx.ExecContext(ctx, deliveryQuery, delivery.Id, DeliverySucceeded, attempts, code, now); err != nil {
+			return fmt.Errorf("failed to record delivery %v: %w", delivery.Id, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE webhooks SET failure_count = 0 WHERE id = $1`, delivery.WebhookId); err != nil {
+			return fmt.Errorf("failed to reset failures of webhook %v: %w", delivery.WebhookId, err)
+		}
+	} else {
+		status := DeliveryPending
+		if attempts >= WebhookMaxAttempts {
+			status = DeliveryFailed
+		}
+
+		const deliveryQuery = `
+		UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4,
+		last_error = $5, next_attempt_at = $6 WHERE id = $1`
+
+		if _, err := tx.ExecContext(ctx, deliveryQuery, delivery.Id, status, attempts, code,
+			attemptErr.Error(), now.Add(WebhookBackoff(attempts))); err != nil {
+			return fmt.Errorf("failed to record delivery %v: %w", delivery.Id, err)
+		}
+
+		const webhookQuery = `
+		UPDATE webhooks SET failure_count = failure_count + 1,
+		enabled = enabled AND failure_count + 1 < $2,
+		disabled_at = CASE WHEN enabled AND failure_count + 1 >= $2 THEN $3 ELSE disabled_at END
+		WHERE id = $1`
+
+		if _, err := tx.ExecContext(ctx, webhookQuery, delivery.WebhookId, WebhookDisableAfter, now); err != nil {
+			return fmt.Errorf("failed to record failure of webhook %v: %w", delivery.WebhookId, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delivery attempt: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	require.Equal(t, 30*time.Second, store.WebhookBackoff(1))
+	require.Equal(t, time.Minute, store.WebhookBackoff(2))
+	require.Equal(t, 4*time.Minute, store.WebhookBackoff(4))
+	require.Equal(t, 6*time.Hour, store.WebhookBackoff(store.WebhookMaxAttempts+5))
+}
+
+func TestWebhookStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	webhookStore := store.NewWebhookStore(env.Db)
+
+	webhook, err := webhookStore.Create(ctx, store.WebhookParams{
+		Url:        "https://pms.example.com/hooks",
+		EventTypes: []string{store.EventTicketCreated},
+		Enabled:    true,
+	})
+	require.NoError(t, err)
+	require.Len(t, webhook.Secret, 64)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+
+	// only the subscribed event is queued, the update is not
+	require.NoError(t, ticketStore.Update(ctx, ticket.Id, store.UpdateTicketParams{
+		Priority: store.TicketPriorityHigh,
+		Status:   store.TicketStatusInProgress,
+	}))
+
+	deliveries, err := webhookStore.Deliveries(ctx, webhook.Id, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, store.EventTicketCreated, deliveries[0].EventType)
+	require.Contains(t, string(deliveries[0].Payload), ticket.Id.String())
+
+	due, err := webhookStore.ClaimDue(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, webhook.Url, due[0].Url)
+
+	// claimed deliveries are leased
+	again, err := webhookStore.ClaimDue(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Empty(t, again)
+
+	require.NoError(t, webhookStore.RecordAttempt(ctx, &due[0].WebhookDelivery, 500, errors.New("unexpected response status 500")))
+
+	deliveries, err = webhookStore.Deliveries(ctx, webhook.Id, 10)
+	require.NoError(t, err)
+	require.Equal(t, store.DeliveryPending, deliveries[0].Status)
+	require.Equal(t, 1, deliveries[0].Attempts)
+
+	webhook, err = webhookStore.ById(ctx, webhook.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1, webhook.FailureCount)
+
+	replayed, err := webhookStore.Replay(ctx, deliveries[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, store.DeliveryPending, replayed.Status)
+	require.Zero(t, replayed.Attempts)
+
+	for range store.WebhookDisableAfter {
+		require.NoError(t, webhookStore.RecordAttempt(ctx, &due[0].WebhookDelivery, 0, errors.New("connection refused")))
+	}
+
+	webhook, err = webhookStore.ById(ctx, webhook.Id)
+	require.NoError(t, err)
+	require.False(t, webhook.Enabled)
+	require.NotNil(t, webhook.DisabledAt)
+
+	webhook, err = webhookStore.Update(ctx, webhook.Id, store.WebhookParams{
+		Url:        webhook.Url,
+		EventTypes: webhook.EventTypes,
+		Enabled:    true,
+	})
+	require.NoError(t, err)
+	require.True(t, webhook.Enabled)
+	require.Zero(t, webhook.FailureCount)
+}
@@ -0,0 +1,277 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// WorkLogStore keeps the time staff spent on tickets. Work logs outlive their ticket, each
+// keeps the title and category of its ticket for the reports.
+type WorkLogStore struct {
+	db *sqlx.DB
+}
+
+func NewWorkLogStore(db *sql.DB) *WorkLogStore {
+	return &WorkLogStore{
+		db: sqlx.NewDb(db, "postgres"),
+	}
+}
+
+var ErrTimerRunning = errors.New("a timer is already running")
+
+type WorkLog struct {
+	Id          uuid.UUID `db:"id"`
+	TicketId    uuid.UUID `db:"ticket_id"`
+	TicketTitle string    `db:"ticket_title"`
+	Category    string    `db:"category"`
+	UserId      uuid.UUID `db:"user_id"`
+	Seconds     int       `db:"seconds"`
+	Note        string    `db:"note"`
+	// WorkedOn is the day the work was done, at midnight UTC
+	WorkedOn  time.Time `db:"worked_on"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (l WorkLog) Duration() time.Duration {
+	return time.Duration(l.Seconds) * time.Second
+}
+
+type WorkLogParams struct {
+	TicketId uuid.UUID
+	UserId   uuid.UUID
+	Duration time.Duration
+	Note     string
+	WorkedOn time.Time
+}
+
+// WorkTimer is a running timer of a user on a ticket
+type WorkTimer struct {
+	UserId    uuid.UUID `db:"user_id"`
+	TicketId  uuid.UUID `db:"ticket_id"`
+	StartedAt time.Time `db:"started_at"`
+}
+
+// WorkGroup is what work totals are split by
+type WorkGroup string
+
+const (
+	WorkGroupTicket   WorkGroup = "ticket"
+	WorkGroupUser     WorkGroup = "user"
+	WorkGroupCategory WorkGroup = "category"
+)
+
+// workGroupColumns are the key, display name and join used to split work logs aliased as l
+var workGroupColumns = map[WorkGroup]struct {
+	key  string
+	name string
+	join string
+}{
+	WorkGroupTicket: {
+		key:  "l.ticket_id::text",
+		name: "l.ticket_title",
+	},
+	WorkGroupUser: {
+		key:  "l.user_id::text",
+		name: "u.email",
+		join: "JOIN users u ON u.id = l.user_id",
+	},
+	WorkGroupCategory: {
+		key:  "l.category",
+		name: "l.category",
+	},
+}
+
+func (g WorkGroup) Valid() bool {
+	_, ok := workGroupColumns[g]
+	return ok
+}
+
+// WorkTotal is the time logged for a group
+type WorkTotal struct {
+	Key     string `db:"key"`
+	Name    string `db:"name"`
+	Entries int    `db:"entries"`
+	Seconds int    `db:"seconds"`
+}
+
+// Log records work on a ticket, durations are kept to the second
+func (s *WorkLogStore) Log(ctx context.Context, params WorkLogParams) (*WorkLog, error) {
+	return logWork(ctx, s.db, params)
+}
+
+// logWork records the work with the title and category of its ticket, which has to exist
+func logWork(ctx context.Context, q sqlx.QueryerContext, params WorkLogParams) (*WorkLog, error) {
+
+	const query = `
+	INSERT INTO work_logs (ticket_id, user_id, seconds, note, worked_on, ticket_title, category)
+	SELECT t.id, $2::uuid, $3::int, $4::text, $5::date, t.title, t.category FROM tickets t WHERE t.id = $1
+	RETURNING *`
+
+	seconds := int(params.Duration.Round(time.Second) / time.Second)
+	workedOn := params.WorkedOn.UTC().Format(time.DateOnly)
+
+	var log WorkLog
+	if err := sqlx.GetContext(ctx, q, &log, query, params.TicketId, params.UserId, seconds, params.Note, workedOn); err != nil {
+		return nil, fmt.Errorf("failed to log work on ticket %v: %w", params.TicketId, err)
+	}
+
+	return &log, nil
+}
+
+// ByTicketId lists the work logged on a ticket, oldest first
+func (s *WorkLogStore) ByTicketId(ctx context.Context, ticketId uuid.UUID) ([]WorkLog, error) {
+
+	const query = `
+	SELECT * FROM work_logs WHERE ticket_id = $1 ORDER BY worked_on ASC, created_at ASC`
+
+	logs := []WorkLog{}
+	if err := s.db.SelectContext(ctx, &logs, query, ticketId); err != nil {
+		return nil, fmt.Errorf("failed to get work logs of ticket %v: %w", ticketId, err)
+	}
+
+	return logs, nil
+}
+
+func (s *WorkLogStore) ById(ctx context.Context, id uuid.UUID) (*WorkLog, error) {
+
+	const query = `
+	SELECT * FROM work_logs WHERE id = $1`
+
+	var log WorkLog
+	if err := s.db.GetContext(ctx, &log, query, id); err != nil {
+		return nil, fmt.Errorf("failed to get work log %v: %w", id, err)
+	}
+
+	return &log, nil
+}
+
+func (s *WorkLogStore) Delete(ctx context.Context, id uuid.UUID) error {
+
+	const query = `
+	DELETE FROM work_logs WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to delete work log %v: %w", id, err)
+	}
+
+	return nil
+}
+
+// Timer returns the running timer of the user, sql.ErrNoRows when there is none
+func (s *WorkLogStore) Timer(ctx context.Context, userId uuid.UUID) (*WorkTimer, error) {
+
+	const query = `
+	SELECT * FROM work_timers WHERE user_id = $1`
+
+	var timer WorkTimer
+	if err := s.db.GetContext(ctx, &timer, query, userId); err != nil {
+		return nil, fmt.Errorf("failed to get timer of user %v: %w", userId, err)
+	}
+
+	return &timer, nil
+}
+
+// StartTimer starts the timer of the user on a ticket, a user runs one timer at a time
+// so ErrTimerRunning is returned while another one runs
+func (s *WorkLogStore) StartTimer(ctx context.Context, userId, ticketId uuid.UUID, now time.Time) (*WorkTimer, error) {
+
+	const query = `
+	INSERT INTO work_timers (user_id, ticket_id, started_at) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id) DO NOTHING RETURNING *`
+
+	var timer WorkTimer
+	if err := s.db.GetContext(ctx, &timer, query, userId, ticketId, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTimerRunning
+		}
+		return nil, fmt.Errorf("failed to start timer of user %v: %w", userId, err)
+	}
+
+	return &timer, nil
+}
+
+// StopTimer stops the running timer of the user and logs the time on its ticket,
+// dated the day it was started. It returns sql.ErrNoRows when no timer runs.
+func (s *WorkLogStore) StopTimer(ctx context.Context, userId uuid.UUID, note string, now time.Time) (*WorkLog, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const query = `
+	DELETE FROM work_timers WHERE user_id = $1 RETURNING *`
+
+	var timer WorkTimer
+	if err := tx.GetContext(ctx, &timer, query, userId); err != nil {
+		return nil, fmt.Errorf("failed to stop timer of user %v: %w", userId, err)
+	}
+
+	log, err := logTimer(ctx, tx, timer, note, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit timer of user %v: %w", userId, err)
+	}
+
+	return log, nil
+}
+
+// logTimer logs the time the stopped timer ran, dated the day it was started
+func logTimer(ctx context.Context, q sqlx.QueryerContext, timer WorkTimer, note string, now time.Time) (*WorkLog, error) {
+	// a timer stopped right away still counts for a second
+	duration := max(now.Sub(timer.StartedAt), time.Second)
+
+	return logWork(ctx, q, WorkLogParams{
+		TicketId: timer.TicketId,
+		UserId:   timer.UserId,
+		Duration: duration,
+		Note:     note,
+		WorkedOn: timer.StartedAt,
+	})
+}
+
+// stopTicketTimers stops the timers running on the ticket and logs their time
+func stopTicketTimers(ctx context.Context, tx *sqlx.Tx, ticketId uuid.UUID, note string, now time.Time) error {
+	var timers []WorkTimer
+	if err := tx.SelectContext(ctx, &timers, `DELETE FROM work_timers WHERE ticket_id = $1 RETURNING *`, ticketId); err != nil {
+		return fmt.Errorf("failed to stop timers on ticket %v: %w", ticketId, err)
+	}
+
+	for _, timer := range timers {
+		if _, err := logTimer(ctx, tx, timer, note, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Totals sums the work done on the days from from through to (UTC) per group, most time first
+func (s *WorkLogStore) Totals(ctx context.Context, group WorkGroup, from, to time.Time) ([]WorkTotal, error) {
+	columns, ok := workGroupColumns[group]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown work group %q", ErrValidation, group)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT %[1]s AS key, %[2]s AS name, COUNT(*) AS entries, SUM(l.seconds)::int AS seconds
+	FROM work_logs l %[3]s
+	WHERE l.worked_on >= $1::date AND l.worked_on <= $2::date
+	GROUP BY 1, 2 ORDER BY seconds DESC, 1 ASC`, columns.key, columns.name, columns.join)
+
+	totals := []WorkTotal{}
+	if err := s.db.SelectContext(ctx, &totals, query, from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly)); err != nil {
+		return nil, fmt.Errorf("failed to get work totals: %w", err)
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,130 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+func TestWorkLogStore(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	ctx := context.Background()
+	userStore := store.NewUserStore(env.Db)
+	ticketStore := store.NewTicketStore(env.Db, time.UTC)
+	workLogStore := store.NewWorkLogStore(env.Db)
+
+	guest, err := userStore.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+
+	plumber, err := userStore.CreateUser(ctx, "plumber@test.com", "test")
+	require.NoError(t, err)
+
+	electrician, err := userStore.CreateUser(ctx, "electrician@test.com", "test")
+	require.NoError(t, err)
+
+	create := func(title, category string) *store.Ticket {
+		ticket, err := ticketStore.Create(ctx, store.CreateTicketParams{
+			Title:       title,
+			Description: "test description",
+			Category:    category,
+			Creator:     guest.Id,
+			Priority:    store.TicketPriorityMedium,
+		})
+		require.NoError(t, err)
+		return ticket
+	}
+
+	leak := create("leaking tap", "maintenance")
+	light := create("broken light", "maintenance")
+	towels := create("fresh towels", "housekeeping")
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err = workLogStore.Log(ctx, store.WorkLogParams{TicketId: leak.Id, UserId: plumber.Id, Duration: 90 * time.Minute, Note: "replaced washer", WorkedOn: day})
+	require.NoError(t, err)
+
+	_, err = workLogStore.Log(ctx, store.WorkLogParams{TicketId: light.Id, UserId: electrician.Id, Duration: 30 * time.Minute, WorkedOn: day})
+	require.NoError(t, err)
+
+	_, err = workLogStore.Log(ctx, store.WorkLogParams{TicketId: towels.Id, UserId: plumber.Id, Duration: 5 * time.Minute, WorkedOn: day.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+
+	// the timer logs the time it ran on the day it started
+	started := day.Add(10 * time.Hour)
+	_, err = workLogStore.StartTimer(ctx, electrician.Id, leak.Id, started)
+	require.NoError(t, err)
+
+	_, err = workLogStore.StartTimer(ctx, electrician.Id, light.Id, started)
+	require.ErrorIs(t, err, store.ErrTimerRunning)
+
+	timer, err := workLogStore.Timer(ctx, electrician.Id)
+	require.NoError(t, err)
+	require.Equal(t, leak.Id, timer.TicketId)
+
+	log, err := workLogStore.StopTimer(ctx, electrician.Id, "checked the wiring", started.Add(45*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, leak.Id, log.TicketId)
+	require.Equal(t, 45*time.Minute, log.Duration())
+	require.True(t, day.Equal(log.WorkedOn))
+
+	_, err = workLogStore.StopTimer(ctx, electrician.Id, "", started.Add(time.Hour))
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	logs, err := workLogStore.ByTicketId(ctx, leak.Id)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+
+	totals, err := workLogStore.Totals(ctx, store.WorkGroupCategory, day, day)
+	require.NoError(t, err)
+	require.Equal(t, []store.WorkTotal{
+		{Key: "maintenance", Name: "maintenance", Entries: 3, Seconds: int((165 * time.Minute).Seconds())},
+	}, totals)
+
+	totals, err = workLogStore.Totals(ctx, store.WorkGroupUser, day, day.AddDate(0, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+	require.Equal(t, plumber.Id.String(), totals[0].Key)
+	require.Equal(t, int((95 * time.Minute).Seconds()), totals[0].Seconds)
+
+	totals, err = workLogStore.Totals(ctx, store.WorkGroupTicket, day, day)
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+	require.Equal(t, leak.Title, totals[0].Name)
+
+	require.NoError(t, workLogStore.Delete(ctx, log.Id))
+
+	// closing the ticket logs the time of the timers still running on it and keeps its work logs
+	_, err = workLogStore.StartTimer(ctx, plumber.Id, leak.Id, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, ticketStore.Close(ctx, leak.Id, plumber.Id))
+
+	_, err = workLogStore.Timer(ctx, plumber.Id)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	logs, err = workLogStore.ByTicketId(ctx, leak.Id)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.Equal(t, "leaking tap", logs[1].TicketTitle)
+	require.InDelta(t, time.Hour.Seconds(), float64(logs[1].Seconds), 5)
+
+	totals, err = workLogStore.Totals(ctx, store.WorkGroupTicket, day, day)
+	require.NoError(t, err)
+	require.Len(t, totals, 2)
+	require.Equal(t, leak.Title, totals[0].Name)
+
+	totals, err = workLogStore.Totals(ctx, store.WorkGroupCategory, day, day)
+	require.NoError(t, err)
+	require.Equal(t, []store.WorkTotal{
+		{Key: "maintenance", Name: "maintenance", Entries: 2, Seconds: int((120 * time.Minute).Seconds())},
+	}, totals)
+}
@@ -0,0 +1,115 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	chatPollInterval = 5 * time.Second
+	chatBatchSize    = 20
+	chatTimeout      = 10 * time.Second
+	chatLease        = 2 * chatTimeout
+)
+
+// ChatWorker posts the queued chat notifications to the incoming webhooks users configured,
+// any number of instances can run it
+type ChatWorker struct {
+	notifications *store.NotificationStore
+	client        *http.Client
+	logger        *slog.Logger
+}
+
+func NewChatWorker(notifications *store.NotificationStore, logger *slog.Logger) *ChatWorker {
+	return &ChatWorker{
+		notifications: notifications,
+		client:        &http.Client{Timeout: chatTimeout},
+		logger:        logger,
+	}
+}
+
+// Run posts due chat notifications until ctx is done
+func (w *ChatWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(chatPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.postDue(ctx)
+		}
+	}
+}
+
+func (w *ChatWorker) postDue(ctx context.Context) {
+	for {
+		chats, err := w.notifications.ClaimDueChats(ctx, chatBatchSize, chatLease)
+		if err != nil {
+			w.logger.Error("failed to claim chat notifications", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, chat := range chats {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				err := w.Post(ctx, &chat)
+				if err != nil {
+					w.logger.Info("posting chat notification failed", "chat", chat.Id, "error", err)
+				}
+
+				if err := w.notifications.RecordChatAttempt(ctx, &chat, err); err != nil {
+					w.logger.Error("failed to record chat attempt", "chat", chat.Id, "error", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(chats) < chatBatchSize {
+			return
+		}
+	}
+}
+
+// Post sends the notification as {"text": "..."}, which slack, mattermost, rocket.chat
+// and most other incoming webhooks understand
+func (w *ChatWorker) Post(ctx context.Context, chat *store.ChatNotification) error {
+	body, err := json.Marshal(map[string]string{"text": chat.Text})
+	if err != nil {
+		return fmt.Errorf("failed to encode chat notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, chat.Url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ticketr-notifications")
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+
+	return nil
+}
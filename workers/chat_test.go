@@ -0,0 +1,34 @@
+package workers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/store"
+	"github.com/tatucosmin/hotel-system/workers"
+)
+
+func TestChatPost(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "Ticket assigned: broken lamp", body["text"])
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	worker := workers.NewChatWorker(nil, nil)
+	chat := &store.ChatNotification{Url: srv.URL, Text: "Ticket assigned: broken lamp"}
+
+	require.NoError(t, worker.Post(context.Background(), chat))
+
+	status = http.StatusNotFound
+	require.Error(t, worker.Post(context.Background(), chat))
+}
@@ -0,0 +1,122 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/mail"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	mailPollInterval = 5 * time.Second
+	mailBatchSize    = 20
+	mailTimeout      = 30 * time.Second
+	mailLease        = 2 * mailTimeout
+)
+
+// MailWorker sends the queued emails through the transport, any number of instances can run it
+type MailWorker struct {
+	notifications *store.NotificationStore
+	transport     mail.Transport
+	from          string
+	replyTo       string
+	replySecret   string
+	logger        *slog.Logger
+}
+
+// NewMailWorker sends the emails from the address, replies go to replyTo when it is set,
+// typically the mailbox piped into the inbound email endpoint. With replySecret the reply
+// address of each email carries a token for its user and ticket signed with it.
+func NewMailWorker(notifications *store.NotificationStore, transport mail.Transport, from, replyTo, replySecret string, logger *slog.Logger) *MailWorker {
+	return &MailWorker{
+		notifications: notifications,
+		transport:     transport,
+		from:          from,
+		replyTo:       replyTo,
+		replySecret:   replySecret,
+		logger:        logger,
+	}
+}
+
+// Run sends due emails until ctx is done
+func (w *MailWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(mailPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.sendDue(ctx)
+		}
+	}
+}
+
+func (w *MailWorker) sendDue(ctx context.Context) {
+	for {
+		emails, err := w.notifications.ClaimDueEmails(ctx, mailBatchSize, mailLease)
+		if err != nil {
+			w.logger.Error("failed to claim emails", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, email := range emails {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				err := w.Send(ctx, &email)
+				if err != nil {
+					w.logger.Info("sending email failed", "email", email.Id, "error", err)
+				}
+
+				if err := w.notifications.RecordEmailAttempt(ctx, &email, err); err != nil {
+					w.logger.Error("failed to record email attempt", "email", email.Id, "error", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(emails) < mailBatchSize {
+			return
+		}
+	}
+}
+
+// Send hands the email to the transport. Emails about a ticket reference it in their headers
+// so that mail clients keep the thread together and replies find their way back to it.
+func (w *MailWorker) Send(ctx context.Context, email *store.Email) error {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	headers := map[string]string{}
+	if w.replyTo != "" {
+		replyTo := w.replyTo
+		if w.replySecret != "" && email.UserId.Valid {
+			var err error
+			replyTo, err = mail.ReplyAddress(w.replyTo, w.replySecret, email.TicketId.UUID, email.UserId.UUID)
+			if err != nil {
+				return err
+			}
+		}
+		headers["Reply-To"] = replyTo
+	}
+	if email.TicketId.Valid {
+		headers["References"] = mail.TicketMessageId(email.TicketId.UUID, w.from)
+		headers["X-Ticketr-Ticket"] = email.TicketId.UUID.String()
+	}
+
+	return w.transport.Send(ctx, &mail.Message{
+		From:    w.from,
+		To:      []string{email.ToAddress},
+		Subject: email.Subject,
+		Text:    email.TextBody,
+		HTML:    email.HtmlBody,
+		Headers: headers,
+	})
+}
@@ -0,0 +1,53 @@
+package workers_test
+
+import (
+	"context"
+	netmail "net/mail"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/mail"
+	"github.com/tatucosmin/hotel-system/store"
+	"github.com/tatucosmin/hotel-system/workers"
+)
+
+func TestMailSend(t *testing.T) {
+	transport := mail.NewMemoryTransport()
+	worker := workers.NewMailWorker(nil, transport, "Ticketr <no-reply@ticketr.local>", "frontdesk@ticketr.local", "secret", nil)
+	ticketId := uuid.New()
+	userId := uuid.New()
+
+	require.NoError(t, worker.Send(context.Background(), &store.Email{
+		UserId:    uuid.NullUUID{UUID: userId, Valid: true},
+		TicketId:  uuid.NullUUID{UUID: ticketId, Valid: true},
+		ToAddress: "guest@test.com",
+		Subject:   "Re: broken lamp",
+		TextBody:  "fixed",
+		HtmlBody:  "<p>fixed</p>",
+	}))
+
+	require.Error(t, worker.Send(context.Background(), &store.Email{ToAddress: "not an address"}))
+
+	messages := transport.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, []string{"guest@test.com"}, messages[0].To)
+	require.Equal(t, "Ticketr <no-reply@ticketr.local>", messages[0].From)
+	require.Equal(t, "<p>fixed</p>", messages[0].HTML)
+
+	// replies to the email refer back to the ticket and are signed for its recipient
+	replyTo, err := netmail.ParseAddress(messages[0].Headers["Reply-To"])
+	require.NoError(t, err)
+	require.Regexp(t, `^frontdesk\+[0-9a-v]+@ticketr\.local$`, replyTo.Address)
+	reply := &mail.InboundMessage{
+		Recipients: []string{replyTo.Address},
+		References: "<abc@mail.example.com> " + messages[0].Headers["References"],
+	}
+	referenced, ok := reply.TicketReference()
+	require.True(t, ok)
+	require.Equal(t, ticketId, referenced)
+
+	replyUser, ok := reply.ReplyUser("secret", referenced)
+	require.True(t, ok)
+	require.Equal(t, userId, replyUser)
+}
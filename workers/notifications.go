@@ -0,0 +1,487 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tatucosmin/hotel-system/mail"
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	notificationPollInterval = 5 * time.Second
+	notificationBatchSize    = 50
+	notificationLease        = time.Minute
+	digestPollInterval       = time.Minute
+)
+
+const (
+	ReasonCreator  = "creator"
+	ReasonAssignee = "assignee"
+	ReasonWatcher  = "watcher"
+	ReasonMention  = "mention"
+	ReasonDigest   = "digest"
+)
+
+// NotificationData is what the mail templates are rendered with
+type NotificationData struct {
+	// Recipient is the address the email goes to and Reason why they get it
+	Recipient string
+	Reason    string
+	// Actor is who caused the event, empty when the system did
+	Actor    string
+	Ticket   *store.Ticket
+	Assignee string
+	Reply    string
+	Details  store.HistoryDetails
+}
+
+// DigestData is what the digest template is rendered with
+type DigestData struct {
+	Recipient string
+	Reason    string
+	Items     []DigestItem
+}
+
+type DigestItem struct {
+	Subject string
+	Text    string
+}
+
+type recipient struct {
+	user   *store.User
+	reason string
+}
+
+// NotificationWorker turns ticket events into notifications to the creator, the assignee
+// and the watchers of the ticket, leaving out whoever caused the event. Each recipient gets
+// them on the channels their preferences pick for the kind of event, delayed until their
+// quiet hours end, and emails are held for a daily digest if they chose one. It only
+// queues them, the MailWorker and ChatWorker send them.
+type NotificationWorker struct {
+	store     *store.Store
+	templates *mail.Templates
+	logger    *slog.Logger
+}
+
+func NewNotificationWorker(store *store.Store, templates *mail.Templates, logger *slog.Logger) *NotificationWorker {
+	return &NotificationWorker{
+		store:     store,
+		templates: templates,
+		logger:    logger,
+	}
+}
+
+// Run processes queued events until ctx is done
+func (w *NotificationWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(notificationPollInterval)
+	defer ticker.Stop()
+
+	digests := time.NewTicker(digestPollInterval)
+	defer digests.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.processEvents(ctx)
+		case <-digests.C:
+			w.sendDigests(ctx)
+		}
+	}
+}
+
+func (w *NotificationWorker) processEvents(ctx context.Context) {
+	for {
+		events, err := w.store.Notification.ClaimEvents(ctx, notificationBatchSize, notificationLease)
+		if err != nil {
+			w.logger.Error("failed to claim notification events", "error", err)
+			return
+		}
+
+		for _, event := range events {
+			notifications, err := w.Notifications(ctx, event.Event, time.Now())
+			if err != nil {
+				// the event is tried again once its lease runs out
+				w.logger.Error("failed to prepare notifications", "event", event.Id, "error", err)
+				continue
+			}
+
+			if err := w.store.Notification.ProcessEvent(ctx, event.Id, *notifications); err != nil {
+				w.logger.Error("failed to queue notifications", "event", event.Id, "error", err)
+			}
+		}
+
+		if len(events) < notificationBatchSize {
+			return
+		}
+	}
+}
+
+// Notifications renders the notifications the event causes at the given time
+func (w *NotificationWorker) Notifications(ctx context.Context, event store.Event, now time.Time) (*store.Notifications, error) {
+	notifications := &store.Notifications{}
+
+	kind, ok := notificationKind(event)
+	if !ok {
+		return notifications, nil
+	}
+
+	ticket := event.Ticket
+	if ticket == nil {
+		var err error
+		ticket, err = w.store.Ticket.ById(ctx, event.TicketId)
+		if err != nil {
+			// the ticket has been closed since, that event notifies instead
+			if errors.Is(err, sql.ErrNoRows) {
+				return notifications, nil
+			}
+			return nil, err
+		}
+	}
+
+	// the due date may have been cleared, moved or met since the reminder was queued
+	if (kind == store.NotifyDueSoon || kind == store.NotifySlaBreach) && !stillDue(ticket, event.Details) {
+		return notifications, nil
+	}
+
+	recipients, err := w.recipients(ctx, ticket, event, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(recipients) == 0 {
+		return notifications, nil
+	}
+
+	data := NotificationData{
+		Ticket:  ticket,
+		Details: event.Details,
+	}
+
+	if data.Actor, err = w.email(ctx, event.Actor); err != nil {
+		return nil, err
+	}
+
+	if data.Assignee, err = w.email(ctx, ticket.CurrentAssignee); err != nil {
+		return nil, err
+	}
+
+	if event.Type == store.EventTicketReplied || event.Action == store.HistoryReplyEdited {
+		replyId, err := uuid.Parse(fmt.Sprint(event.Details["reply_id"]))
+		if err != nil {
+			return nil, fmt.Errorf("replied event without reply: %w", err)
+		}
+
+		reply, err := w.store.TicketReply.ById(ctx, replyId)
+		if err != nil {
+			return nil, err
+		}
+
+		// the reply has been deleted since, its message is gone
+		if reply.DeletedAt != nil {
+			return notifications, nil
+		}
+		data.Reply = reply.Message
+	}
+
+	for _, recipient := range recipients {
+		prefs, err := w.store.Notification.Preferences(ctx, recipient.user.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		// a mention is a reply of its own kind, with its own preferences and template
+		kind := kind
+		if recipient.reason == ReasonMention {
+			kind = store.NotifyMentioned
+		}
+
+		channels := prefs.ChannelsFor(kind)
+		if len(channels) == 0 {
+			continue
+		}
+
+		data.Recipient, data.Reason = recipient.user.Email, recipient.reason
+
+		var msg mail.Message
+		if err := w.templates.Render(kind, data, &msg); err != nil {
+			return nil, err
+		}
+
+		quietUntil := prefs.QuietUntil(now)
+
+		if slices.Contains(channels, store.ChannelEmail) {
+			notifications.Emails = append(notifications.Emails, store.EmailParams{
+				UserId:    recipient.user.Id,
+				TicketId:  ticket.Id,
+				To:        recipient.user.Email,
+				Subject:   msg.Subject + " " + mail.TicketToken(ticket.Id),
+				TextBody:  msg.Text,
+				HtmlBody:  msg.HTML,
+				Digest:    prefs.Digest,
+				NotBefore: quietUntil,
+			})
+		}
+
+		if slices.Contains(channels, store.ChannelChat) && prefs.ChatWebhookUrl != "" {
+			notifications.Chats = append(notifications.Chats, store.ChatParams{
+				UserId:    recipient.user.Id,
+				Url:       prefs.ChatWebhookUrl,
+				Text:      msg.Subject + "\n\n" + withoutFooter(msg.Text),
+				NotBefore: quietUntil,
+			})
+		}
+
+		// the inbox does not interrupt, so quiet hours and digests do not apply to it
+		if slices.Contains(channels, store.ChannelInApp) {
+			notifications.Inbox = append(notifications.Inbox, store.InboxParams{
+				UserId:   recipient.user.Id,
+				TicketId: ticket.Id,
+				Actor:    event.Actor,
+				Kind:     kind,
+				Title:    msg.Subject,
+				Body:     withoutFooter(msg.Text),
+			})
+		}
+	}
+
+	return notifications, nil
+}
+
+// sendDigests replaces the held emails of every user whose digest is due with their digest.
+// Users who have turned the digest off since get what is still held right away.
+func (w *NotificationWorker) sendDigests(ctx context.Context) {
+	prefs, err := w.store.Notification.HeldPreferences(ctx)
+	if err != nil {
+		w.logger.Error("failed to get held emails", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, p := range prefs {
+		if p.Digest && !p.DigestDue(now) {
+			continue
+		}
+
+		held, err := w.store.Notification.HeldEmails(ctx, p.UserId)
+		if err != nil {
+			w.logger.Error("failed to get held emails", "user", p.UserId, "error", err)
+			continue
+		}
+
+		if len(held) == 0 {
+			continue
+		}
+
+		digest, err := w.Digest(held)
+		if err != nil {
+			w.logger.Error("failed to render digest", "user", p.UserId, "error", err)
+			continue
+		}
+
+		if err := w.store.Notification.SendDigest(ctx, p.UserId, held, *digest); err != nil && !errors.Is(err, store.ErrDigestTaken) {
+			w.logger.Error("failed to queue digest", "user", p.UserId, "error", err)
+		}
+	}
+}
+
+// Digest renders one email out of the held emails of a user
+func (w *NotificationWorker) Digest(held []store.Email) (*store.EmailParams, error) {
+	data := DigestData{
+		Recipient: held[0].ToAddress,
+		Reason:    ReasonDigest,
+		Items:     make([]DigestItem, len(held)),
+	}
+
+	for i, email := range held {
+		data.Items[i] = DigestItem{Subject: email.Subject, Text: withoutFooter(email.TextBody)}
+	}
+
+	var msg mail.Message
+	if err := w.templates.Render("digest", data, &msg); err != nil {
+		return nil, err
+	}
+
+	return &store.EmailParams{
+		UserId:   held[0].UserId.UUID,
+		To:       held[0].ToAddress,
+		Subject:  msg.Subject,
+		TextBody: msg.Text,
+		HtmlBody: msg.HTML,
+	}, nil
+}
+
+// withoutFooter drops the "why you get this" footer the layout adds to the text of emails
+func withoutFooter(text string) string {
+	body, _, _ := strings.Cut(text, "\n\n--\n")
+	return strings.TrimSpace(body)
+}
+
+// recipients are the creator, assignee and watchers of the ticket without the actor, each once.
+// Users mentioned in a reply come first so they hear of it as a mention, an edited reply only
+// goes to the users it mentions for the first time. Due date reminders
+// only go to the assignee, breaches of the due date leave out the creator. Closed tickets take
+// their watchers with them, their event has them.
+func (w *NotificationWorker) recipients(ctx context.Context, ticket *store.Ticket, event store.Event, kind string) ([]recipient, error) {
+	var recipients []recipient
+	seen := map[uuid.UUID]bool{event.Actor: true, uuid.Nil: true}
+
+	add := func(userId uuid.UUID, reason string) error {
+		if seen[userId] {
+			return nil
+		}
+		seen[userId] = true
+
+		user, err := w.store.User.ById(ctx, userId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+
+		recipients = append(recipients, recipient{user: user, reason: reason})
+		return nil
+	}
+
+	if kind == store.NotifyDueSoon {
+		err := add(ticket.CurrentAssignee, ReasonAssignee)
+		return recipients, err
+	}
+
+	if kind == store.NotifyReplied || kind == store.NotifyMentioned {
+		for _, userId := range detailIds(event.Details, "mentioned") {
+			if err := add(userId, ReasonMention); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// an edited reply is only news to the users it now mentions
+	if kind == store.NotifyMentioned {
+		return recipients, nil
+	}
+
+	// guests are not told that their ticket is late
+	if kind != store.NotifySlaBreach {
+		if err := add(ticket.Creator, ReasonCreator); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := add(ticket.CurrentAssignee, ReasonAssignee); err != nil {
+		return nil, err
+	}
+
+	watchers := detailIds(event.Details, "watchers")
+	if kind != store.NotifyClosed {
+		ticketWatchers, err := w.store.Notification.Watchers(ctx, ticket.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, watcher := range ticketWatchers {
+			watchers = append(watchers, watcher.UserId)
+		}
+	}
+
+	for _, userId := range watchers {
+		if err := add(userId, ReasonWatcher); err != nil {
+			return nil, err
+		}
+	}
+
+	return recipients, nil
+}
+
+// detailIds reads a list of users, such as the ones mentioned in a reply, from the details
+// of an event, which hold uuids until the event has been through json
+func detailIds(details store.HistoryDetails, key string) []uuid.UUID {
+	switch ids := details[key].(type) {
+	case []uuid.UUID:
+		return ids
+	case []any:
+		var userIds []uuid.UUID
+		for _, id := range ids {
+			if userId, err := uuid.Parse(fmt.Sprint(id)); err == nil {
+				userIds = append(userIds, userId)
+			}
+		}
+		return userIds
+	}
+	return nil
+}
+
+// stillDue reports whether the ticket is not done and still due at the due date in the
+// details, which hold a time until the event has been through json
+func stillDue(ticket *store.Ticket, details store.HistoryDetails) bool {
+	var dueAt time.Time
+	switch value := details["due_at"].(type) {
+	case time.Time:
+		dueAt = value
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return false
+		}
+		dueAt = parsed
+	default:
+		return false
+	}
+
+	return ticket.DueAt != nil && ticket.DueAt.Equal(dueAt) && ticket.Status < store.TicketStatusDone
+}
+
+// email returns the address of the user, empty for uuid.Nil or users that are gone
+func (w *NotificationWorker) email(ctx context.Context, userId uuid.UUID) (string, error) {
+	if userId == uuid.Nil {
+		return "", nil
+	}
+
+	user, err := w.store.User.ById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return user.Email, nil
+}
+
+// notificationKind is the kind of notification the event causes, the
+// mail template of each kind is named after it
+func notificationKind(event store.Event) (string, bool) {
+	switch event.Type {
+	case store.EventTicketClosed:
+		return store.NotifyClosed, true
+	case store.EventTicketDueSoon:
+		return store.NotifyDueSoon, true
+	case store.EventTicketOverdue:
+		return store.NotifySlaBreach, true
+	}
+
+	switch event.Action {
+	case store.HistoryCreated:
+		return store.NotifyCreated, true
+	case store.HistoryReplied:
+		return store.NotifyReplied, true
+	case store.HistoryReplyEdited:
+		return store.NotifyMentioned, true
+	case store.HistoryAssigned, store.HistoryAutoAssigned:
+		return store.NotifyAssigned, true
+	case store.HistoryStatusChanged:
+		return store.NotifyStatusChanged, true
+	}
+
+	return "", false
+}
@@ -0,0 +1,152 @@
+package workers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/fixtures"
+	"github.com/tatucosmin/hotel-system/mail"
+	"github.com/tatucosmin/hotel-system/store"
+	"github.com/tatucosmin/hotel-system/workers"
+)
+
+// queuedEvent claims the queued notification events and returns the last one of the type
+func queuedEvent(t *testing.T, st *store.Store, eventType string) store.Event {
+	t.Helper()
+
+	events, err := st.Notification.ClaimEvents(context.Background(), 50, time.Minute)
+	require.NoError(t, err)
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Event.Type == eventType {
+			return events[i].Event
+		}
+	}
+
+	t.Fatalf("no %s event was queued", eventType)
+	return store.Event{}
+}
+
+func emailRecipients(notifications *store.Notifications) []string {
+	var to []string
+	for _, email := range notifications.Emails {
+		to = append(to, email.To)
+	}
+	return to
+}
+
+func TestNotificationWorker(t *testing.T) {
+	env := fixtures.NewTestEnv(t)
+	ctx := context.Background()
+
+	cleanup := env.SetupDb(t)
+	t.Cleanup(func() {
+		cleanup(t)
+	})
+
+	st := store.New(env.Db, time.UTC)
+	templates, err := mail.LoadTemplates()
+	require.NoError(t, err)
+	worker := workers.NewNotificationWorker(st, templates, nil)
+
+	guest, err := st.User.CreateUser(ctx, "guest@test.com", "test")
+	require.NoError(t, err)
+	staff, err := st.User.CreateUser(ctx, "staff@test.com", "test")
+	require.NoError(t, err)
+	manager, err := st.User.CreateUser(ctx, "manager@test.com", "test")
+	require.NoError(t, err)
+
+	ticket, err := st.Ticket.Create(ctx, store.CreateTicketParams{
+		Title:       "broken lamp",
+		Description: "room 101",
+		Creator:     guest.Id,
+		Priority:    store.TicketPriorityMedium,
+	})
+	require.NoError(t, err)
+	require.NoError(t, st.Notification.Watch(ctx, ticket.Id, manager.Id))
+
+	t.Run("assigned", func(t *testing.T) {
+		_, err := st.Ticket.Assign(ctx, ticket.Id, manager.Id, staff.Id, ticket.TeamId, false)
+		require.NoError(t, err)
+
+		notifications, err := worker.Notifications(ctx, queuedEvent(t, st, store.EventTicketAssigned), time.Now())
+		require.NoError(t, err)
+
+		// the manager assigned it and isn't told about it
+		require.ElementsMatch(t, []string{"guest@test.com", "staff@test.com"}, emailRecipients(notifications))
+		require.Len(t, notifications.Inbox, 2)
+	})
+
+	t.Run("replied", func(t *testing.T) {
+		_, err := st.TicketReply.Create(ctx, ticket.Id, staff.Id, "a new bulb is on its way")
+		require.NoError(t, err)
+
+		notifications, err := worker.Notifications(ctx, queuedEvent(t, st, store.EventTicketReplied), time.Now())
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []string{"guest@test.com", "manager@test.com"}, emailRecipients(notifications))
+		for _, email := range notifications.Emails {
+			require.Contains(t, email.TextBody, "a new bulb is on its way")
+		}
+	})
+
+	t.Run("due date cleared", func(t *testing.T) {
+		dueAt := time.Now().Add(30 * time.Minute)
+		require.NoError(t, st.Ticket.Update(ctx, ticket.Id, store.UpdateTicketParams{
+			Actor:    staff.Id,
+			Priority: store.TicketPriorityMedium,
+			Status:   store.TicketStatusInProgress,
+			DueAt:    &dueAt,
+		}))
+
+		queued, err := st.Ticket.QueueDueReminders(ctx, time.Now(), []time.Duration{time.Hour})
+		require.NoError(t, err)
+		require.Equal(t, 1, queued)
+		event := queuedEvent(t, st, store.EventTicketDueSoon)
+
+		notifications, err := worker.Notifications(ctx, event, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, []string{"staff@test.com"}, emailRecipients(notifications))
+
+		// a reminder of a due date that is gone is dropped
+		require.NoError(t, st.Ticket.Update(ctx, ticket.Id, store.UpdateTicketParams{
+			Actor:      staff.Id,
+			Priority:   store.TicketPriorityMedium,
+			Status:     store.TicketStatusInProgress,
+			ClearDueAt: true,
+		}))
+
+		notifications, err = worker.Notifications(ctx, event, time.Now())
+		require.NoError(t, err)
+		require.Empty(t, notifications.Emails)
+		require.Empty(t, notifications.Inbox)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		require.NoError(t, st.Ticket.Close(ctx, ticket.Id, staff.Id))
+
+		// the watchers are deleted with the ticket, the event still has them
+		watchers, err := st.Notification.Watchers(ctx, ticket.Id)
+		require.NoError(t, err)
+		require.Empty(t, watchers)
+
+		notifications, err := worker.Notifications(ctx, queuedEvent(t, st, store.EventTicketClosed), time.Now())
+		require.NoError(t, err)
+
+		require.ElementsMatch(t, []string{"guest@test.com", "manager@test.com"}, emailRecipients(notifications))
+		for _, email := range notifications.Emails {
+			require.Equal(t, ticket.Id, email.TicketId)
+		}
+
+		// the closure reaches the inbox and stays in it
+		require.Len(t, notifications.Inbox, 2)
+		require.NoError(t, st.Notification.ProcessEvent(ctx, 0, store.Notifications{Inbox: notifications.Inbox}))
+
+		inbox, err := st.Notification.Inbox(ctx, guest, store.InboxQuery{Limit: 10})
+		require.NoError(t, err)
+		require.NotEmpty(t, inbox)
+		require.Equal(t, store.NotifyClosed, inbox[0].Kind)
+	})
+}
@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const reminderPollInterval = time.Minute
+
+// ReminderWorker reminds assignees of tickets that are due soon and reports the tickets that
+// breach their due date, any number of instances can run it
+type ReminderWorker struct {
+	tickets *store.TicketStore
+	offsets []time.Duration
+	logger  *slog.Logger
+}
+
+// NewReminderWorker reminds assignees at each of the offsets before a ticket is due, there
+// are no reminders without offsets
+func NewReminderWorker(tickets *store.TicketStore, offsets []time.Duration, logger *slog.Logger) *ReminderWorker {
+	return &ReminderWorker{
+		tickets: tickets,
+		offsets: offsets,
+		logger:  logger,
+	}
+}
+
+// Run queues due date reminders and breaches until ctx is done
+func (w *ReminderWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(reminderPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.queue(ctx, time.Now())
+		}
+	}
+}
+
+// queue publishes the reminders and breaches that are due, the ones that fail to be queued
+// are picked up on the next tick
+func (w *ReminderWorker) queue(ctx context.Context, now time.Time) {
+	if len(w.offsets) > 0 {
+		queued, err := w.tickets.QueueDueReminders(ctx, now, w.offsets)
+		if err != nil {
+			w.logger.Error("failed to queue due date reminders", "error", err)
+		} else if queued > 0 {
+			w.logger.Info("queued due date reminders", "count", queued)
+		}
+	}
+
+	overdue, err := w.tickets.QueueOverdue(ctx, now)
+	if err != nil {
+		w.logger.Error("failed to queue overdue tickets", "error", err)
+	} else if overdue > 0 {
+		w.logger.Info("queued overdue tickets", "count", overdue)
+	}
+}
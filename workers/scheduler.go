@@ -0,0 +1,61 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const schedulerPollInterval = 30 * time.Second
+
+// SchedulerWorker opens the tickets of due ticket schedules, any number of instances can run it
+type SchedulerWorker struct {
+	schedules *store.TicketScheduleStore
+	logger    *slog.Logger
+}
+
+func NewSchedulerWorker(schedules *store.TicketScheduleStore, logger *slog.Logger) *SchedulerWorker {
+	return &SchedulerWorker{
+		schedules: schedules,
+		logger:    logger,
+	}
+}
+
+// Run opens scheduled tickets until ctx is done
+func (w *SchedulerWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(schedulerPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.runDue(ctx)
+		}
+	}
+}
+
+func (w *SchedulerWorker) runDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		schedule, runs, err := w.schedules.RunDue(ctx, time.Now())
+		if err != nil {
+			// runs that fail to open their ticket are recorded as failed and do not end up
+			// here, what does is the database failing, which is tried again on the next tick
+			w.logger.Error("failed to run ticket schedule", "error", err)
+			return
+		}
+
+		if schedule == nil {
+			return
+		}
+
+		for _, run := range runs {
+			if run.Outcome == store.ScheduleRunFailed {
+				w.logger.Warn("scheduled ticket was not opened", "schedule", schedule.Id, "run_at", run.RunAt, "error", run.Error)
+			}
+		}
+	}
+}
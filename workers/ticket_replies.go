@@ -4,28 +4,68 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/google/uuid"
 	"github.com/tatucosmin/hotel-system/config"
+	"github.com/tatucosmin/hotel-system/markdown"
 	"github.com/tatucosmin/hotel-system/store"
 )
 
-func SaveTicketToS3(ctx context.Context, ticketId uuid.UUID, ticketReplyStore *store.TicketReplyStore, cfg *config.Config) error {
+// SaveTicketToS3 archives the ticket with its replies, work logs and attachments, the description
+// and replies are kept both as written and rendered from markdown. The content of attachments stays
+// where it is in s3, the archive lists their keys as their rows are deleted with the ticket.
+func SaveTicketToS3(ctx context.Context, ticket *store.Ticket, ticketReplyStore *store.TicketReplyStore, workLogStore *store.WorkLogStore, attachmentStore *store.TicketAttachmentStore, cfg *config.Config) error {
+	ticketId := ticket.Id
+
 	ticketReplies, err := ticketReplyStore.ByTicketId(ctx, ticketId)
 	if err != nil {
 		return fmt.Errorf("failed to get ticket replies: %w", err)
 	}
 
+	workLogs, err := workLogStore.ByTicketId(ctx, ticketId)
+	if err != nil {
+		return fmt.Errorf("failed to get work logs: %w", err)
+	}
+
+	attachments, err := attachmentStore.ByTicketId(ctx, ticketId)
+	if err != nil {
+		return fmt.Errorf("failed to get attachments: %w", err)
+	}
+
 	buf := bytes.NewBuffer(nil)
+	buf.WriteString(fmt.Sprintf("Title: %s\nDescription: %s\nDescription HTML: %s\n\n",
+		ticket.Title, ticket.Description, markdown.Render(ticket.Description)))
+
 	for _, reply := range *ticketReplies {
-		buf.WriteString(fmt.Sprintf("Creator: %s\nMessage: %s\n\n", reply.Creator, reply.Message))
+		if reply.DeletedAt != nil {
+			buf.WriteString(fmt.Sprintf("Creator: %s\nDeleted: %s\n\n", reply.Creator, reply.DeletedAt.Format(time.RFC3339)))
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf("Creator: %s\nMessage: %s\nMessage HTML: %s\n\n", reply.Creator, reply.Message, markdown.Render(reply.Message)))
 	}
 
-	s3FilePath := fmt.Sprintf("tickets/ticket_%s.txt", ticketId)
+	if len(workLogs) > 0 {
+		var total time.Duration
+		buf.WriteString("Work log:\n")
+		for _, log := range workLogs {
+			buf.WriteString(fmt.Sprintf("%s %s %s %s\n", log.WorkedOn.Format(time.DateOnly), log.UserId, log.Duration(), log.Note))
+			total += log.Duration()
+		}
+		buf.WriteString(fmt.Sprintf("Total: %s\n\n", total))
+	}
 
-	fmt.Println(buf.String())
+	if len(attachments) > 0 {
+		buf.WriteString("Attachments:\n")
+		for _, attachment := range attachments {
+			buf.WriteString(fmt.Sprintf("%s %s %d %s\n", attachment.Filename, attachment.ContentType, attachment.Size, attachment.S3Key))
+		}
+		buf.WriteString("\n")
+	}
+
+	s3FilePath := fmt.Sprintf("tickets/ticket_%s.txt", ticketId)
 
 	_, err = cfg.S3Client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket: aws.String(cfg.S3Bucket),
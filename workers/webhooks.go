@@ -0,0 +1,129 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tatucosmin/hotel-system/store"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	// the lease outlives an attempt so a delivery is never sent twice at once
+	webhookLease = 2 * webhookTimeout
+)
+
+// SignWebhook returns the X-Ticketr-Signature of a body sent at the unix timestamp: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret, prefixed with "sha256="
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// WebhookWorker sends the queued webhook deliveries, any number of instances can run it
+type WebhookWorker struct {
+	webhooks *store.WebhookStore
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+func NewWebhookWorker(webhooks *store.WebhookStore, logger *slog.Logger) *WebhookWorker {
+	return &WebhookWorker{
+		webhooks: webhooks,
+		client:   &http.Client{Timeout: webhookTimeout},
+		logger:   logger,
+	}
+}
+
+// Run delivers due webhooks until ctx is done
+func (w *WebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.deliverDue(ctx)
+		}
+	}
+}
+
+func (w *WebhookWorker) deliverDue(ctx context.Context) {
+	for {
+		deliveries, err := w.webhooks.ClaimDue(ctx, webhookBatchSize, webhookLease)
+		if err != nil {
+			w.logger.Error("failed to claim webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				statusCode, err := w.Deliver(ctx, &delivery)
+				if err != nil {
+					w.logger.Info("webhook delivery failed", "delivery", delivery.Id, "webhook", delivery.WebhookId, "error", err)
+				}
+
+				if err := w.webhooks.RecordAttempt(ctx, &delivery.WebhookDelivery, statusCode, err); err != nil {
+					w.logger.Error("failed to record webhook attempt", "delivery", delivery.Id, "error", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// Deliver posts the delivery to its webhook and returns the response status, 0 when there was no response
+func (w *WebhookWorker) Deliver(ctx context.Context, delivery *store.DueDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ticketr-webhooks")
+	req.Header.Set("X-Ticketr-Event", delivery.EventType)
+	req.Header.Set("X-Ticketr-Delivery", delivery.Id.String())
+	req.Header.Set("X-Ticketr-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Ticketr-Signature", SignWebhook(delivery.Secret, timestamp, body))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,63 @@
+package workers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tatucosmin/hotel-system/store"
+	"github.com/tatucosmin/hotel-system/workers"
+)
+
+func TestWebhookDeliver(t *testing.T) {
+	const secret = "s3cret"
+	payload := `{"type":"ticket.created"}`
+
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(body))
+
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Ticketr-Timestamp"), 10, 64)
+		require.NoError(t, err)
+		require.Equal(t, workers.SignWebhook(secret, timestamp, body), r.Header.Get("X-Ticketr-Signature"))
+		require.Equal(t, "ticket.created", r.Header.Get("X-Ticketr-Event"))
+
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	worker := workers.NewWebhookWorker(nil, nil)
+	delivery := &store.DueDelivery{
+		WebhookDelivery: store.WebhookDelivery{
+			Id:        uuid.New(),
+			EventType: "ticket.created",
+			Payload:   store.WebhookPayload(payload),
+		},
+		Url:    srv.URL,
+		Secret: secret,
+	}
+
+	code, err := worker.Deliver(context.Background(), delivery)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, code)
+
+	status = http.StatusInternalServerError
+	code, err = worker.Deliver(context.Background(), delivery)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, code)
+}
+
+func TestSignWebhook(t *testing.T) {
+	signature := workers.SignWebhook("secret", 1700000000, []byte(`{}`))
+	require.Equal(t, signature, workers.SignWebhook("secret", 1700000000, []byte(`{}`)))
+	require.NotEqual(t, signature, workers.SignWebhook("other", 1700000000, []byte(`{}`)))
+	require.NotEqual(t, signature, workers.SignWebhook("secret", 1700000001, []byte(`{}`)))
+	require.Len(t, signature, len("sha256=")+64)
+}